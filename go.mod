@@ -2,7 +2,10 @@ module github.com/yanodintsovmercuryo/cursor-rules-syncer
 
 go 1.21
 
-require github.com/urfave/cli/v2 v2.27.1
+require (
+	github.com/urfave/cli/v2 v2.27.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -12,10 +13,18 @@ import (
 // version
 const version = "v1.0.0"
 
+// Exit codes
+const (
+	exitError          = 1 // Any other error, drift found by status and lint errors
+	exitConflict       = 2 // Sync refused because of conflicts or unrelated changes
+	exitPartialFailure = 3 // Sync finished but some files failed
+	exitCommitFailure  = 4 // Files synced but the commit or push failed
+)
+
 func main() {
 	// Initialize services
 	outputService := service.NewOutputService()
-	syncService := service.NewSyncService(outputService)
+	syncService := service.NewSyncService(outputService, service.NewExecGitClient(), service.NewOSFileSystem())
 
 	app := &cli.App{
 		Name:  "cursor-rules-syncer",
@@ -24,67 +33,226 @@ func main() {
 			{
 				Name:  "pull",
 				Usage: "Pulls rules from the source directory to the current git project's .cursor/rules directory, deleting extra files in the project.",
-				Flags: []cli.Flag{
+				Flags: append(append(sourceFlags(), syncFlags()...),
+					&cli.BoolFlag{
+						Name:  "locked",
+						Usage: "Pull the rules at the commit pinned in .cursor/rules.lock instead of the working tree",
+					},
+				),
+				Action: func(c *cli.Context) error {
+					if err := outputService.SetFormat(c.String("output")); err != nil {
+						return commandError(outputService, err)
+					}
+
+					options, err := newSyncOptions(c, syncService)
+					if err != nil {
+						return commandError(outputService, err)
+					}
+
+					result, err := syncService.PullRules(options)
+					if result != nil {
+						outputService.PrintResult(result)
+					}
+					return commandError(outputService, err)
+				},
+			},
+			{
+				Name:  "update",
+				Usage: "Moves the pin in .cursor/rules.lock to the latest commit of the rules repository and pulls that commit.",
+				Flags: append(sourceFlags(), syncFlags()...),
+				Action: func(c *cli.Context) error {
+					if err := outputService.SetFormat(c.String("output")); err != nil {
+						return commandError(outputService, err)
+					}
+
+					options, err := newSyncOptions(c, syncService)
+					if err != nil {
+						return commandError(outputService, err)
+					}
+
+					result, err := syncService.UpdateRules(options)
+					if result != nil {
+						outputService.PrintResult(result)
+					}
+					return commandError(outputService, err)
+				},
+			},
+			{
+				Name:  "push",
+				Usage: "Pushes rules from the current git project's .cursor/rules directory to the source directory, deleting extra files in the source, and commits changes",
+				Flags: append(append(sourceFlags(), syncFlags()...),
+					&cli.BoolFlag{
+						Name:  "git-without-push",
+						Usage: "Commit changes but don't push to remote",
+					},
 					&cli.StringFlag{
-						Name:  "rules-dir",
-						Usage: "Path to rules directory (overrides CURSOR_RULES_DIR env var)",
+						Name:  "branch",
+						Usage: "Commit to a new branch created from the current one and push it with upstream tracking, leaving the current branch untouched. Supports <project>, <date> and <time> (e.g., 'rules/<project>/<date>')",
+					},
+					&cli.StringFlag{
+						Name:    "message",
+						Aliases: []string{"m"},
+						Usage:   "Commit message as a Go text/template with .Project, .ProjectRemote, .ProjectCommit, .Operations, .Added, .Updated, .Deleted and .Summary",
+					},
+					&cli.StringFlag{
+						Name:  "author",
+						Usage: "Override the commit author (format: 'Name <email>')",
+					},
+					&cli.StringFlag{
+						Name:  "committer",
+						Usage: "Override the committer (format: 'Name <email>')",
 					},
 					&cli.BoolFlag{
-						Name:  "overwrite-headers",
-						Usage: "Overwrite headers instead of preserving them",
+						Name:  "signoff",
+						Usage: "Add a Signed-off-by trailer to the commit",
+					},
+					&cli.BoolFlag{
+						Name:  "gpg-sign",
+						Usage: "GPG-sign the commit with the default key",
 					},
 					&cli.StringFlag{
-						Name:  "file-patterns",
-						Usage: "Comma-separated file patterns to sync (e.g., 'local_*.mdc,translate/*.md') (overrides CURSOR_RULES_PATTERNS env var)",
+						Name:  "gpg-key",
+						Usage: "GPG-sign the commit with the given key ID",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Push even when the rules repository has uncommitted changes outside the synced files, those changes are left uncommitted",
+					},
+					&cli.BoolFlag{
+						Name:  "lint",
+						Usage: "Lint the project rules first and don't push when any rule has errors",
+					},
+					&cli.IntFlag{
+						Name:  "max-lines",
+						Usage: "Lines above which --lint reports a rule as oversized",
+						Value: 500,
 					},
+				),
+				Action: func(c *cli.Context) error {
+					if err := outputService.SetFormat(c.String("output")); err != nil {
+						return commandError(outputService, err)
+					}
+
+					options, err := newSyncOptions(c, syncService)
+					if err != nil {
+						return commandError(outputService, err)
+					}
+
+					result, err := syncService.PushRules(options)
+					if result != nil {
+						outputService.PrintResult(result)
+					}
+					return commandError(outputService, err)
 				},
+			},
+			{
+				Name:  "status",
+				Usage: "Shows drift between the project's .cursor/rules directory and the source directory. Exits with 1 when they differ.",
+				Flags: sourceFlags(),
 				Action: func(c *cli.Context) error {
-					options := &models.SyncOptions{
-						RulesDir:         c.String("rules-dir"),
-						GitWithoutPush:   false, // Not used in pull
-						OverwriteHeaders: c.Bool("overwrite-headers"),
-						FilePatterns:     c.String("file-patterns"),
+					if err := outputService.SetFormat(c.String("output")); err != nil {
+						return commandError(outputService, err)
+					}
+
+					options, err := newSyncOptions(c, syncService)
+					if err != nil {
+						return commandError(outputService, err)
 					}
 
-					_, err := syncService.PullRules(options)
+					report, err := syncService.Status(options)
 					if err != nil {
-						outputService.PrintFatalf("Error: %v", err)
+						return commandError(outputService, err)
+					}
+					outputService.PrintStatus(report)
+					if !report.InSync {
+						return cli.Exit("", exitError)
 					}
 					return nil
 				},
 			},
 			{
-				Name:  "push",
-				Usage: "Pushes rules from the current git project's .cursor/rules directory to the source directory, deleting extra files in the source, and commits changes",
+				Name:  "diff",
+				Usage: "Shows unified diffs of the changes pull would make to the project, or push would make to the source directory with --push. Nothing is written.",
+				Flags: append(sourceFlags(),
+					&cli.BoolFlag{
+						Name:  "push",
+						Usage: "Show the changes push would make instead of pull",
+					},
+					&cli.BoolFlag{
+						Name:  "no-merge",
+						Usage: "Ignore the sync state manifest and always overwrite the destination",
+					},
+					&cli.BoolFlag{
+						Name:  "conflict-markers",
+						Usage: "Show conflict markers for files changed on both sides instead of skipping them",
+					},
+				),
+				Action: func(c *cli.Context) error {
+					if err := outputService.SetFormat(c.String("output")); err != nil {
+						return commandError(outputService, err)
+					}
+
+					direction := models.DirectionPull
+					if c.Bool("push") {
+						direction = models.DirectionPush
+					}
+
+					options, err := newSyncOptions(c, syncService)
+					if err != nil {
+						return commandError(outputService, err)
+					}
+
+					result, err := syncService.Diff(direction, options)
+					if result != nil {
+						outputService.PrintResult(result)
+					}
+					return commandError(outputService, err)
+				},
+			},
+			{
+				Name:  "lint",
+				Usage: "Checks the .mdc rules in the project's .cursor/rules directory, or the source directory with --central. Exits with 1 when any rule has errors.",
 				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "central",
+						Usage: "Lint the rules in the source directory instead of the project",
+					},
 					&cli.StringFlag{
 						Name:  "rules-dir",
 						Usage: "Path to rules directory (overrides CURSOR_RULES_DIR env var)",
 					},
 					&cli.BoolFlag{
-						Name:  "git-without-push",
-						Usage: "Commit changes but don't push to remote",
+						Name:  "no-fetch",
+						Usage: "Don't fetch the rules repository when it is a git URL, for offline use",
 					},
-					&cli.BoolFlag{
-						Name:  "overwrite-headers",
-						Usage: "Overwrite headers instead of preserving them",
+					&cli.IntFlag{
+						Name:  "max-lines",
+						Usage: "Lines above which a rule is reported as oversized",
+						Value: 500,
 					},
 					&cli.StringFlag{
-						Name:  "file-patterns",
-						Usage: "Comma-separated file patterns to sync (e.g., 'local_*.mdc,translate/*.md') (overrides CURSOR_RULES_PATTERNS env var)",
+						Name:  "output",
+						Usage: "Output format: text, json, ndjson or sarif",
+						Value: "text",
 					},
 				},
 				Action: func(c *cli.Context) error {
-					options := &models.SyncOptions{
-						RulesDir:         c.String("rules-dir"),
-						GitWithoutPush:   c.Bool("git-without-push"),
-						OverwriteHeaders: c.Bool("overwrite-headers"),
-						FilePatterns:     c.String("file-patterns"),
+					if err := outputService.SetLintFormat(c.String("output")); err != nil {
+						return commandError(outputService, err)
 					}
 
-					_, err := syncService.PushRules(options)
+					options, err := newSyncOptions(c, syncService)
 					if err != nil {
-						outputService.PrintFatalf("Error: %v", err)
+						return commandError(outputService, err)
+					}
+
+					report, err := syncService.Lint(options, c.Bool("central"))
+					if err != nil {
+						return commandError(outputService, err)
+					}
+					outputService.PrintLintReport(report)
+					if report.Errors > 0 {
+						return cli.Exit("", exitError)
 					}
 					return nil
 				},
@@ -104,3 +272,142 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// commandError prints err unless the printed result already reports it and returns the matching exit code to urfave/cli
+func commandError(outputService *service.OutputService, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var syncErr *service.SyncError
+	if !errors.As(err, &syncErr) {
+		outputService.PrintCommandError(err)
+	}
+	return cli.Exit("", exitCode(err))
+}
+
+// exitCode maps an error to the exit code of the command
+func exitCode(err error) int {
+	var ignoreConflictErr *service.IgnoreConflictError
+	var rebaseConflictErr *service.RebaseConflictError
+	var unrelatedChangesErr *service.UnrelatedChangesError
+	var syncErr *service.SyncError
+	switch {
+	case errors.As(err, &ignoreConflictErr), errors.As(err, &rebaseConflictErr), errors.As(err, &unrelatedChangesErr):
+		return exitConflict
+	case errors.As(err, &syncErr) && len(syncErr.Files) == 0:
+		return exitCommitFailure
+	case errors.As(err, &syncErr):
+		return exitPartialFailure
+	default:
+		return exitError
+	}
+}
+
+// sourceFlags returns flags selecting which rules are compared and how
+func sourceFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "rules-dir",
+			Usage: "Path to rules directory (overrides CURSOR_RULES_DIR env var)",
+		},
+		&cli.BoolFlag{
+			Name:  "overwrite-headers",
+			Usage: "Overwrite headers instead of preserving them",
+		},
+		&cli.BoolFlag{
+			Name:  "merge-headers",
+			Usage: "Merge headers key by key instead of preserving them: descriptions are taken from the source, other keys are kept",
+		},
+		&cli.StringFlag{
+			Name:  "header-policy",
+			Usage: "Comma-separated key=policy pairs for merged headers, policies are keep-local, take-source and union (e.g., 'globs=union,alwaysApply=take-source'), implies --merge-headers",
+		},
+		&cli.StringFlag{
+			Name:  "line-endings",
+			Usage: "Line endings of synced files: lf, crlf, preserve (as in the source) or auto (as set by .gitattributes or .editorconfig of the destination) (default: lf for .mdc rules, other files unchanged)",
+		},
+		&cli.StringFlag{
+			Name:  "file-patterns",
+			Usage: "Comma-separated file patterns to sync (e.g., 'local_*.mdc,translate/*.md') (overrides CURSOR_RULES_PATTERNS env var)",
+		},
+		&cli.StringFlag{
+			Name:  "ignore-files",
+			Usage: "Comma-separated gitignore-style patterns to exclude from sync (e.g., 'secret.mdc,drafts/') (overrides CURSOR_RULES_IGNORE env var)",
+		},
+		&cli.BoolFlag{
+			Name:  "no-fetch",
+			Usage: "Don't fetch and fast-forward the rules repository before syncing, for offline use",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "Output format: text, json or ndjson",
+			Value: "text",
+		},
+	}
+}
+
+// syncFlags returns flags controlling how pull and push modify files
+func syncFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Print planned changes without writing, deleting or committing anything",
+		},
+		&cli.BoolFlag{
+			Name:  "no-merge",
+			Usage: "Ignore the sync state manifest and always overwrite the destination",
+		},
+		&cli.BoolFlag{
+			Name:  "conflict-markers",
+			Usage: "Write conflict markers into files changed on both sides instead of skipping them",
+		},
+		&cli.BoolFlag{
+			Name:  "show-diff",
+			Usage: "Print a unified diff below every changed file",
+		},
+	}
+}
+
+// newSyncOptions builds sync options from command flags, flags a command doesn't define are left empty.
+// Options not set by flags or environment variables are taken from the project and user config files.
+func newSyncOptions(c *cli.Context, syncService *service.SyncService) (*models.SyncOptions, error) {
+	options := &models.SyncOptions{
+		RulesDir:         c.String("rules-dir"),
+		GitWithoutPush:   c.Bool("git-without-push"),
+		OverwriteHeaders: c.Bool("overwrite-headers"),
+		FilePatterns:     c.String("file-patterns"),
+		IgnoreFiles:      c.String("ignore-files"),
+		DryRun:           c.Bool("dry-run"),
+		NoMerge:          c.Bool("no-merge"),
+		ConflictMarkers:  c.Bool("conflict-markers"),
+		ShowDiff:         c.Bool("show-diff"),
+		NoFetch:          c.Bool("no-fetch"),
+		Locked:           c.Bool("locked"),
+		Branch:           c.String("branch"),
+		CommitMessage:    c.String("message"),
+		Author:           c.String("author"),
+		Committer:        c.String("committer"),
+		Signoff:          c.Bool("signoff"),
+		GPGSign:          c.Bool("gpg-sign"),
+		GPGKey:           c.String("gpg-key"),
+		Force:            c.Bool("force"),
+		Lint:             c.Bool("lint"),
+		MaxRuleLines:     c.Int("max-lines"),
+		LineEndings:      models.LineEndingPolicy(c.String("line-endings")),
+		MergeHeaders:     c.Bool("merge-headers") || c.IsSet("header-policy"),
+	}
+	if c.IsSet("header-policy") {
+		var err error
+		if options.HeaderPolicy.Fields, err = syncService.ParseHeaderPolicy(c.String("header-policy")); err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := syncService.LoadConfig(options.ProjectDir)
+	if err != nil {
+		return nil, err
+	}
+	syncService.ApplyConfig(options, config, c.IsSet)
+	return options, nil
+}
@@ -9,18 +9,90 @@ const (
 	OperationUpdate OperationType = "update"
 )
 
+// SyncDirection represents the direction of a sync operation
+type SyncDirection string
+
+const (
+	DirectionPull SyncDirection = "pull"
+	DirectionPush SyncDirection = "push"
+)
+
 // FileOperation represents a file operation with metadata
 type FileOperation struct {
-	Type         OperationType `json:"type"`
-	SourcePath   string        `json:"source_path"`
-	TargetPath   string        `json:"target_path"`
+	Type            OperationType `json:"type"`
+	SourcePath      string        `json:"source_path"`
+	TargetPath      string        `json:"target_path"`
+	RelativePath    string        `json:"relative_path"`
+	Reason          string        `json:"reason,omitempty"`
+	Merged          bool          `json:"merged,omitempty"`           // Body is the result of a three-way merge
+	MergedBody      string        `json:"-"`                          // Merged body written instead of the source body
+	Diff            string        `json:"diff,omitempty"`             // Unified diff of the body, or of the whole file for non-.mdc files
+	HeaderDiff      string        `json:"header_diff,omitempty"`      // Unified diff of the .mdc header when it will be written
+	HeaderPreserved bool          `json:"header_preserved,omitempty"` // The .mdc header differs but the destination header is kept
+	FormatOnly      bool          `json:"format_only,omitempty"`      // Only line endings or trailing whitespace differ, so the diffs are empty
+}
+
+// SyncPlan represents the operations a sync would perform, computed without touching disk
+type SyncPlan struct {
+	Direction   SyncDirection   `json:"direction"`
+	SourceDir   string          `json:"source_dir"`
+	TargetDir   string          `json:"target_dir"`
+	TargetName  string          `json:"target_name,omitempty"` // Name of the target in output, the base name of TargetDir when empty
+	ProjectRoot string          `json:"project_root"`
+	Operations  []FileOperation `json:"operations"`
+	Errors      []FileError     `json:"errors"`
+	Options     SyncOptions     `json:"options"`
+	NextState   *SyncState      `json:"-"` // State manifest to save once the plan is applied
+	// SourceCommit and SourceHashes describe the pulled rules, they are recorded in rules.lock
+	SourceCommit string            `json:"source_commit,omitempty"`
+	SourceHashes map[string]string `json:"source_hashes,omitempty"`
+}
+
+// SyncStateEntry records a file as it was after the last successful sync
+type SyncStateEntry struct {
+	Hash string `json:"hash"` // SHA-256 of the synced body
+	Base string `json:"base"` // Synced body, used as the common ancestor for three-way merges
+}
+
+// SyncState is the manifest stored in the project rules directory, keyed by relative path
+type SyncState struct {
+	Version int                       `json:"version"`
+	Files   map[string]SyncStateEntry `json:"files"`
+}
+
+// RulesLock represents .cursor/rules.lock, pinning a project to a commit of the central rules
+type RulesLock struct {
+	Version int               `json:"version"`
+	Commit  string            `json:"commit"` // Commit of the rules repository the project was synced from
+	Files   map[string]string `json:"files"`  // SHA-256 of every pulled file by relative path
+}
+
+// SyncSummary counts operations by type
+type SyncSummary struct {
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// FileError represents a failure to plan or apply an operation on a single file
+type FileError struct {
+	Type         OperationType `json:"type,omitempty"`
 	RelativePath string        `json:"relative_path"`
+	Error        string        `json:"error"`
 }
 
 // SyncResult represents the result of a sync operation
 type SyncResult struct {
-	Operations []FileOperation `json:"operations"`
-	HasChanges bool            `json:"has_changes"`
+	Direction   SyncDirection   `json:"direction"`
+	DryRun      bool            `json:"dry_run"`
+	Operations  []FileOperation `json:"operations"`
+	Errors      []FileError     `json:"errors"`
+	HasChanges  bool            `json:"has_changes"`
+	Summary     SyncSummary     `json:"summary"`
+	CommitSHA   string          `json:"commit_sha,omitempty"`
+	Pushed      bool            `json:"pushed"`
+	CommitError string          `json:"commit_error,omitempty"`
+	Branch      string          `json:"branch,omitempty"` // New branch the push was committed to
 }
 
 // IgnorePattern represents a compiled ignore pattern
@@ -32,8 +104,212 @@ type IgnorePattern struct {
 
 // SyncOptions contains configuration for sync operations
 type SyncOptions struct {
-	RulesDir         string
-	GitWithoutPush   bool
-	OverwriteHeaders bool
-	FilePatterns     string // Comma-separated file patterns to sync (e.g., "local_*.mdc,translate/*.md")
+	RulesDir         string `json:"rules_dir"`
+	ProjectDir       string `json:"project_dir"` // Directory inside the project to sync, defaults to the working directory
+	GitWithoutPush   bool   `json:"git_without_push"`
+	OverwriteHeaders bool   `json:"overwrite_headers"`
+	FilePatterns     string `json:"file_patterns"`    // Comma-separated file patterns to sync (e.g., "local_*.mdc,translate/*.md")
+	IgnoreFiles      string `json:"ignore_files"`     // Comma-separated gitignore-style patterns to exclude from sync (e.g., "secret.mdc,drafts/")
+	DryRun           bool   `json:"dry_run"`          // Compute and print operations without writing, deleting or committing
+	NoMerge          bool   `json:"no_merge"`         // Ignore the sync state manifest, the source always wins
+	ConflictMarkers  bool   `json:"conflict_markers"` // Write conflict markers instead of refusing conflicting files
+	ShowDiff         bool   `json:"show_diff"`        // Attach unified diffs to every planned operation
+	NoFetch          bool   `json:"no_fetch"`         // Don't fetch and update the rules repository before syncing
+	Locked           bool   `json:"locked"`           // Pull the revision pinned in rules.lock instead of the working tree
+	Branch           string `json:"branch"`           // Commit pushed rules to this new branch, may contain <project>, <date> and <time>
+	CommitMessage    string `json:"commit_message"`   // Go text/template for the commit message, see CommitMessageData
+	Author           string `json:"author"`           // Commit author as "Name <email>"
+	Committer        string `json:"committer"`        // Committer as "Name <email>"
+	Signoff          bool   `json:"signoff"`          // Add a Signed-off-by trailer
+	GPGSign          bool   `json:"gpg_sign"`         // GPG-sign the commit
+	GPGKey           string `json:"gpg_key"`          // Key ID to sign with, implies GPGSign
+	Force            bool   `json:"force"`            // Push even when the rules repository has unrelated uncommitted changes
+	MergeHeaders     bool   `json:"merge_headers"`    // Merge .mdc headers key by key following HeaderPolicy instead of keeping them whole
+	Lint             bool   `json:"lint"`             // Lint the project rules before pushing, lint errors abort the push
+	MaxRuleLines     int    `json:"max_rule_lines"`   // Lines above which lint reports a rule as oversized, 0 for the default
+
+	LineEndings LineEndingPolicy `json:"line_endings"` // Line endings of synced files, empty for LF in .mdc rules and unchanged other files
+
+	HeaderPolicy  HeaderMergePolicy       `json:"header_policy"` // Per-key policies of the header merge
+	RuleOverrides map[string]RuleOverride `json:"-"`             // Header fields set by the project's rules-overrides.yaml on pulled rules, by relative path
+}
+
+// CommitMessageData is the data available to commit message templates
+type CommitMessageData struct {
+	Project       string          // Name of the project the rules were pushed from
+	ProjectRemote string          // URL of the project's origin remote, empty without one
+	ProjectCommit string          // HEAD commit of the project, empty without commits
+	Operations    []FileOperation // Every applied operation
+	Added         []string        // Relative paths of added files
+	Updated       []string        // Relative paths of updated files
+	Deleted       []string        // Relative paths of deleted files
+	Summary       SyncSummary
+}
+
+// CommitOptions contains the git commit settings of a push
+type CommitOptions struct {
+	Author    string // Commit author as "Name <email>"
+	Committer string // Committer as "Name <email>"
+	Signoff   bool   // Add a Signed-off-by trailer
+	GPGSign   bool   // GPG-sign the commit
+	GPGKey    string // Key ID to sign with, implies GPGSign
+}
+
+// LineEndingPolicy represents which line endings synced files are written with
+type LineEndingPolicy string
+
+const (
+	LineEndingsLF       LineEndingPolicy = "lf"       // LF, adding a missing final newline
+	LineEndingsCRLF     LineEndingPolicy = "crlf"     // CRLF, adding a missing final newline
+	LineEndingsPreserve LineEndingPolicy = "preserve" // The line endings and final newline of the source file
+	LineEndingsAuto     LineEndingPolicy = "auto"     // As set for the file by .gitattributes or .editorconfig of the destination, otherwise preserve
+)
+
+// OutputFormat represents how command results are printed
+type OutputFormat string
+
+const (
+	OutputText   OutputFormat = "text"
+	OutputJSON   OutputFormat = "json"
+	OutputNDJSON OutputFormat = "ndjson"
+	OutputSARIF  OutputFormat = "sarif" // Only supported by lint
+)
+
+// EventType represents the kind of a streamed NDJSON event
+type EventType string
+
+const (
+	EventOperation EventType = "operation"
+	EventResult    EventType = "result"
+	EventError     EventType = "error"
+)
+
+// OutputEvent is a single line of NDJSON output
+type OutputEvent struct {
+	Event     EventType      `json:"event"`
+	Operation *FileOperation `json:"operation,omitempty"`
+	Result    *SyncResult    `json:"result,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// DriftType represents how a file differs between the project and the central rules
+type DriftType string
+
+const (
+	DriftOnlyInProject DriftType = "only_in_project"
+	DriftOnlyInCentral DriftType = "only_in_central"
+	DriftModified      DriftType = "modified"
+	DriftHeaderOnly    DriftType = "header_only"
+)
+
+// FileStatus represents the drift of a single file
+type FileStatus struct {
+	RelativePath string    `json:"relative_path"`
+	Drift        DriftType `json:"drift"`
+}
+
+// StatusReport represents the drift between the project and the central rules
+type StatusReport struct {
+	ProjectDir string       `json:"project_dir"`
+	CentralDir string       `json:"central_dir"`
+	InSync     bool         `json:"in_sync"`
+	Files      []FileStatus `json:"files"`
+}
+
+// LintSeverity represents how serious a lint diagnostic is, only errors fail the lint
+type LintSeverity string
+
+const (
+	SeverityError   LintSeverity = "error"
+	SeverityWarning LintSeverity = "warning"
+)
+
+// LintDiagnostic represents a problem found in an .mdc rule
+type LintDiagnostic struct {
+	Path         string       `json:"path"`
+	RelativePath string       `json:"relative_path"` // Path relative to the linted directory
+	Line         int          `json:"line"`          // Line in the file, starting at 1
+	Severity     LintSeverity `json:"severity"`
+	Rule         string       `json:"rule"` // Check that found the problem, such as unknown-key
+	Message      string       `json:"message"`
+}
+
+// LintReport represents the result of linting the rules of a directory
+type LintReport struct {
+	Dir         string           `json:"dir"`
+	Files       int              `json:"files"` // Number of linted .mdc files
+	Errors      int              `json:"errors"`
+	Warnings    int              `json:"warnings"`
+	Diagnostics []LintDiagnostic `json:"diagnostics"`
+}
+
+// RuleHeader represents the YAML frontmatter of an .mdc rule
+type RuleHeader struct {
+	Description string                 `json:"description,omitempty"`  // When the rule applies, used by agent-requested rules
+	Globs       []string               `json:"globs,omitempty"`        // File patterns that attach the rule
+	AlwaysApply bool                   `json:"always_apply"`           // Whether the rule is always included
+	Unknown     map[string]interface{} `json:"unknown_keys,omitempty"` // Keys Cursor doesn't define, as decoded
+}
+
+// RuleOverrides represents the .cursor/rules-overrides.yaml file of a project
+type RuleOverrides struct {
+	Rules map[string]RuleOverride `yaml:"rules"` // Overrides by rule path relative to .cursor/rules
+}
+
+// RuleOverride represents header fields a project sets on a pulled rule, unset fields are synced as usual
+type RuleOverride struct {
+	Globs       []string `yaml:"globs" json:"globs,omitempty"`              // Replaces the globs, an empty list removes them
+	AlwaysApply *bool    `yaml:"alwaysApply" json:"always_apply,omitempty"` // Replaces alwaysApply
+}
+
+// HeaderFieldPolicy represents how a frontmatter key is merged, local is the destination of the sync
+type HeaderFieldPolicy string
+
+const (
+	HeaderKeepLocal  HeaderFieldPolicy = "keep-local"  // Keep the destination value, a missing key stays missing
+	HeaderTakeSource HeaderFieldPolicy = "take-source" // Take the source value, a key missing in the source is removed
+	HeaderUnion      HeaderFieldPolicy = "union"       // Destination globs followed by the source globs they lack
+)
+
+// HeaderMergePolicy represents the policies of a field-level header merge
+type HeaderMergePolicy struct {
+	Default HeaderFieldPolicy            `yaml:"default" json:"default,omitempty"` // Policy of keys without their own
+	Fields  map[string]HeaderFieldPolicy `yaml:"fields" json:"fields,omitempty"`   // Policy by frontmatter key
+	Files   []HeaderFilePolicy           `yaml:"files" json:"files,omitempty"`     // Policies of files matching a pattern, later entries win
+}
+
+// HeaderFilePolicy overrides the header merge policies for the files matching a pattern
+type HeaderFilePolicy struct {
+	Pattern string                       `yaml:"pattern" json:"pattern"`           // File pattern as used by file_patterns
+	Default HeaderFieldPolicy            `yaml:"default" json:"default,omitempty"` // Policy of keys without their own
+	Fields  map[string]HeaderFieldPolicy `yaml:"fields" json:"fields,omitempty"`   // Policy by frontmatter key
+}
+
+// Config represents a project or user configuration file, unset fields fall through to the next source
+type Config struct {
+	RulesDir     string        `yaml:"rules_dir"`     // Path to the central rules directory
+	FilePatterns []string      `yaml:"file_patterns"` // File patterns to sync
+	IgnoreFiles  []string      `yaml:"ignore_files"`  // Gitignore-style patterns to exclude from sync
+	LineEndings  string        `yaml:"line_endings"`  // Line ending policy: lf, crlf, preserve or auto
+	Headers      HeadersConfig `yaml:"headers"`
+	Git          GitConfig     `yaml:"git"`
+}
+
+// HeadersConfig represents the header policy of a configuration file
+type HeadersConfig struct {
+	Overwrite *bool              `yaml:"overwrite"` // Overwrite headers instead of preserving them
+	Merge     *HeaderMergePolicy `yaml:"merge"`     // Merge headers key by key with these policies
+}
+
+// GitConfig represents the git behaviour of a configuration file
+type GitConfig struct {
+	WithoutPush *bool  `yaml:"without_push"` // Commit pushed rules but don't push to remote
+	Fetch       *bool  `yaml:"fetch"`        // Fetch and update the rules repository before syncing
+	Branch      string `yaml:"branch"`       // Branch name template for push
+	Message     string `yaml:"message"`      // Commit message template for push
+	Author      string `yaml:"author"`       // Commit author as "Name <email>"
+	Committer   string `yaml:"committer"`    // Committer as "Name <email>"
+	Signoff     *bool  `yaml:"signoff"`      // Add a Signed-off-by trailer
+	GPGSign     *bool  `yaml:"gpg_sign"`     // GPG-sign commits
+	GPGKey      string `yaml:"gpg_key"`      // Key ID to sign with
 }
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+// pushToBranch pushes rules to a new branch of the rules repository for review.
+// The branch is created from HEAD in a temporary worktree, so the checked out branch and its working tree
+// are left untouched. A branch that ends up without a commit is deleted again.
+func (s *SyncService) pushToBranch(options *models.SyncOptions) (*models.SyncResult, error) {
+	scope, err := s.resolveSyncScope(options, !options.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	branch, err := s.expandBranchName(options.Branch, scope, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if options.DryRun {
+		s.outputService.PrintInfo(fmt.Sprintf("Would commit to new branch %s", branch))
+		plan, err := s.Plan(models.DirectionPush, options)
+		if err != nil {
+			return nil, err
+		}
+		result, err := s.execute(plan)
+		if result != nil {
+			result.Branch = branch
+		}
+		return result, err
+	}
+
+	rulesDir, cleanup, err := s.checkoutRevision(scope.rulesDir, "HEAD", branch)
+	if err != nil {
+		return nil, err
+	}
+
+	branchOptions := *options
+	branchOptions.RulesDir = rulesDir
+	branchOptions.NoFetch = true
+	branchOptions.Branch = branch
+	plan, err := s.Plan(models.DirectionPush, &branchOptions)
+	if err != nil {
+		cleanup()
+		s.deleteBranch(scope.rulesDir, branch)
+		return nil, err
+	}
+
+	// The project state records what the checked out branch holds, a review branch doesn't change it
+	plan.NextState = nil
+	plan.TargetName = filepath.Base(scope.rulesDir)
+
+	// git refuses to delete a branch checked out in a worktree, so the worktree goes first
+	result, err := s.execute(plan)
+	cleanup()
+	if result == nil || result.CommitSHA == "" {
+		s.deleteBranch(scope.rulesDir, branch)
+		return result, err
+	}
+	result.Branch = branch
+	return result, err
+}
+
+// expandBranchName fills in the <project>, <date> and <time> placeholders and validates the result
+func (s *SyncService) expandBranchName(template string, scope *syncScope, now time.Time) (string, error) {
+	branch := strings.NewReplacer(
+		"<project>", filepath.Base(scope.projectRoot),
+		"<date>", now.Format("2006-01-02"),
+		"<time>", now.Format("150405"),
+	).Replace(template)
+
+	if !s.git.ValidBranchName(scope.rulesDir, branch) {
+		return "", fmt.Errorf("invalid branch name %q", branch)
+	}
+	if s.git.RefExists(scope.rulesDir, "refs/heads/"+branch) {
+		return "", fmt.Errorf("branch %s already exists in %s", branch, scope.rulesDir)
+	}
+	return branch, nil
+}
+
+// deleteBranch removes a branch created for a push that produced no commit
+func (s *SyncService) deleteBranch(repoDir, branch string) {
+	if err := s.git.DeleteBranch(repoDir, branch); err != nil {
+		s.outputService.PrintWarningf("Could not delete branch %s: %v", branch, err)
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestExpandBranchName(t *testing.T) {
+	repoDir := initTestRepo(t)
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	writeFileSystemFiles(t, NewOSFileSystem(), repoDir, map[string]string{"rule.mdc": "rule\n"})
+	runTestGit(t, repoDir, "add", "-A")
+	runTestGit(t, repoDir, "commit", "-q", "-m", "initial")
+	runTestGit(t, repoDir, "branch", "rules/existing")
+
+	syncService := NewSyncService(NewOutputService(), NewExecGitClient(), NewOSFileSystem())
+	scope := &syncScope{rulesDir: repoDir, projectRoot: "/projects/demo"}
+	now := time.Date(2024, 3, 9, 14, 5, 7, 0, time.UTC)
+
+	tests := []struct {
+		template    string
+		expected    string
+		expectedErr bool
+		description string
+	}{
+		{
+			template:    "rules/<project>/<date>",
+			expected:    "rules/demo/2024-03-09",
+			description: "Project and date placeholders should be expanded",
+		},
+		{
+			template:    "sync-<date>-<time>",
+			expected:    "sync-2024-03-09-140507",
+			description: "Time placeholder should be expanded",
+		},
+		{
+			template:    "bad..name",
+			expectedErr: true,
+			description: "Invalid branch names should be rejected",
+		},
+		{
+			template:    "rules/existing",
+			expectedErr: true,
+			description: "Existing branches should be rejected",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			branch, err := syncService.expandBranchName(test.template, scope, now)
+			if test.expectedErr {
+				if err == nil {
+					t.Errorf("Expected an error, got branch %q", branch)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if branch != test.expected {
+				t.Errorf("Expected branch %q, got %q", test.expected, branch)
+			}
+		})
+	}
+}
+
+func TestCommitChangesOnNewBranch(t *testing.T) {
+	seedDir := initTestRepo(t)
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	writeFileSystemFiles(t, NewOSFileSystem(), seedDir, map[string]string{"rule.mdc": "v1\n"})
+	runTestGit(t, seedDir, "add", "-A")
+	runTestGit(t, seedDir, "commit", "-q", "-m", "initial")
+
+	bareDir := filepath.Join(t.TempDir(), "rules.git")
+	runTestGit(t, seedDir, "clone", "-q", "--bare", seedDir, bareDir)
+	rulesDir := filepath.Join(t.TempDir(), "rules")
+	runTestGit(t, seedDir, "clone", "-q", bareDir, rulesDir)
+	originalBranch := runTestGit(t, rulesDir, "rev-parse", "--abbrev-ref", "HEAD")
+	originalHead := runTestGit(t, rulesDir, "rev-parse", "HEAD")
+
+	syncService := NewSyncService(NewOutputService(), NewExecGitClient(), NewOSFileSystem())
+	branchDir, cleanup, err := syncService.checkoutRevision(rulesDir, "HEAD", "rules/review")
+	if err != nil {
+		t.Fatalf("Unexpected error creating branch: %v", err)
+	}
+	defer cleanup()
+
+	writeFileSystemFiles(t, NewOSFileSystem(), branchDir, map[string]string{"rule.mdc": "v2\n"})
+	sha, pushed, err := syncService.commitChanges(branchDir, "update rule", []string{"rule.mdc"}, models.SyncOptions{})
+	if err != nil || !pushed {
+		t.Fatalf("Expected branch to be pushed, got pushed=%v err=%v", pushed, err)
+	}
+
+	if remoteHead := runTestGit(t, bareDir, "rev-parse", "rules/review"); remoteHead != sha {
+		t.Errorf("Expected remote branch at %s, got %s", sha, remoteHead)
+	}
+	if upstream := runTestGit(t, rulesDir, "rev-parse", "--abbrev-ref", "rules/review@{upstream}"); upstream != "origin/rules/review" {
+		t.Errorf("Expected upstream tracking, got %q", upstream)
+	}
+	if branch := runTestGit(t, rulesDir, "rev-parse", "--abbrev-ref", "HEAD"); branch != originalBranch {
+		t.Errorf("Expected original branch %s to stay checked out, got %s", originalBranch, branch)
+	}
+	if head := runTestGit(t, rulesDir, "rev-parse", "HEAD"); head != originalHead {
+		t.Errorf("Expected original branch to stay at %s, got %s", originalHead, head)
+	}
+	assertFileContent(t, filepath.Join(rulesDir, "rule.mdc"), "v1\n")
+}
+
+func TestPushToBranchWithFakeGit(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	projectRulesDir := filepath.Join(projectDir, cursorDirName, rulesDirName)
+	centralDir := filepath.Join(string(filepath.Separator), "central")
+	rulesDir := filepath.Join(string(filepath.Separator), "clone")
+	url := "https://example.com/rules.git"
+
+	tests := []struct {
+		projectContent string
+		expectedCommit bool
+		description    string
+	}{
+		{
+			projectContent: "v2\n",
+			expectedCommit: true,
+			description:    "Changed rules should be committed and pushed to a new branch",
+		},
+		{
+			projectContent: "v1\n",
+			description:    "Branch without a commit should be deleted",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			fileSystem := NewMemoryFileSystem()
+			writeFileSystemFiles(t, fileSystem, projectRulesDir, map[string]string{"rule.mdc": test.projectContent})
+			writeFileSystemFiles(t, fileSystem, centralDir, map[string]string{"rule.mdc": "v1\n"})
+			git, err := newFakeGitClient(fileSystem, projectDir, centralDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			git.serve(url, centralDir)
+			if err := git.Clone(url, rulesDir); err != nil {
+				t.Fatal(err)
+			}
+			rules := git.repos[rulesDir]
+			originalHead := rules.headSHA()
+
+			var stdout bytes.Buffer
+			syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), git, fileSystem)
+			options := models.SyncOptions{RulesDir: rulesDir, ProjectDir: projectDir, Branch: "rules/<project>"}
+			result, err := syncService.PushRules(&options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			remoteHead, onRemote := git.repos[centralDir].refs["refs/heads/rules/project"]
+			if test.expectedCommit {
+				if result.Branch != "rules/project" || !result.Pushed {
+					t.Errorf("Expected a push to rules/project, got %+v", result)
+				}
+				if !onRemote || remoteHead != result.CommitSHA {
+					t.Errorf("Expected remote branch at %s, got %q", result.CommitSHA, remoteHead)
+				}
+				if upstream := rules.upstreams["rules/project"]; upstream != "origin/rules/project" {
+					t.Errorf("Expected upstream tracking, got %q", upstream)
+				}
+				if !strings.Contains(stdout.String(), "rule.mdc (to clone)") {
+					t.Errorf("Expected the rules directory to be named in the output, got %q", stdout.String())
+				}
+			} else {
+				if onRemote || git.RefExists(rulesDir, "refs/heads/rules/project") {
+					t.Errorf("Expected the branch to be deleted")
+				}
+			}
+
+			if rules.branch != "main" || rules.headSHA() != originalHead {
+				t.Errorf("Expected main to stay checked out at %s, got %s at %s", originalHead, rules.branch, rules.headSHA())
+			}
+			assertFiles(t, "rules", map[string]string{"rule.mdc": "v1\n"}, readFileSystemFiles(t, fileSystem, rulesDir))
+			if len(rules.worktrees) != 1 || !rules.worktrees[0].removed {
+				t.Errorf("Expected the worktree to be removed, got %+v", rules.worktrees)
+			}
+			if _, err := fileSystem.Stat(filepath.Join(projectRulesDir, syncStateFileName)); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Expected no sync state for a branch push, got %v", err)
+			}
+
+			// The checked out branch still differs, so a plain push commits the change there
+			options.Branch = ""
+			result, err = syncService.PushRules(&options)
+			if err != nil {
+				t.Fatalf("Unexpected error pushing: %v", err)
+			}
+			if test.expectedCommit != (result.CommitSHA != "") {
+				t.Errorf("Expected a commit on main: %v, got %+v", test.expectedCommit, result)
+			}
+			assertFiles(t, "rules", map[string]string{"rule.mdc": test.projectContent}, readFileSystemFiles(t, fileSystem, rulesDir))
+		})
+	}
+}
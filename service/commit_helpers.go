@@ -0,0 +1,160 @@
+package service
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+const defaultCommitMessageTemplate = "Sync cursor rules: updated from project {{.Project}}"
+
+// identityRegex matches a git identity of the form "Name <email>"
+var identityRegex = regexp.MustCompile(`^\s*([^<>]*[^<>\s])\s*<([^<>\s]+)>\s*$`)
+
+// sampleCommitMessageData is rendered to validate commit message templates, with one operation of each type
+// so templates indexing into the file lists are accepted
+var sampleCommitMessageData = models.CommitMessageData{
+	Project:       "project",
+	ProjectRemote: "git@example.com:org/project.git",
+	ProjectCommit: "0000000000000000000000000000000000000000",
+	Operations: []models.FileOperation{
+		{Type: models.OperationAdd, RelativePath: "added.mdc"},
+		{Type: models.OperationUpdate, RelativePath: "updated.mdc"},
+		{Type: models.OperationDelete, RelativePath: "deleted.mdc"},
+	},
+	Added:   []string{"added.mdc"},
+	Updated: []string{"updated.mdc"},
+	Deleted: []string{"deleted.mdc"},
+	Summary: models.SyncSummary{Added: 1, Updated: 1, Deleted: 1},
+}
+
+// validateCommitOptions reports invalid commit settings before any file is touched
+func validateCommitOptions(options *models.SyncOptions) error {
+	if _, err := renderCommitMessage(options.CommitMessage, sampleCommitMessageData); err != nil {
+		return err
+	}
+	for _, identity := range []string{options.Author, options.Committer} {
+		if identity == "" {
+			continue
+		}
+		if _, _, err := parseIdentity(identity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildCommitMessage renders the commit message for the operations applied by a push
+func (s *SyncService) buildCommitMessage(plan *models.SyncPlan, operations []models.FileOperation) (string, error) {
+	data := models.CommitMessageData{
+		Project:    filepath.Base(plan.ProjectRoot),
+		Operations: operations,
+	}
+
+	// Projects without a remote or without commits yet get empty values
+	data.ProjectRemote, _ = s.git.RemoteURL(plan.ProjectRoot)
+	data.ProjectCommit, _ = s.git.HeadSHA(plan.ProjectRoot)
+
+	for _, operation := range operations {
+		relativePath := filepath.ToSlash(operation.RelativePath)
+		switch operation.Type {
+		case models.OperationAdd:
+			data.Added = append(data.Added, relativePath)
+			data.Summary.Added++
+		case models.OperationUpdate:
+			data.Updated = append(data.Updated, relativePath)
+			data.Summary.Updated++
+		case models.OperationDelete:
+			data.Deleted = append(data.Deleted, relativePath)
+			data.Summary.Deleted++
+		}
+	}
+
+	return renderCommitMessage(plan.Options.CommitMessage, data)
+}
+
+// renderCommitMessage executes a commit message template, an empty template yields the default message
+func renderCommitMessage(messageTemplate string, data models.CommitMessageData) (string, error) {
+	if messageTemplate == "" {
+		messageTemplate = defaultCommitMessageTemplate
+	}
+
+	tmpl, err := template.New("commit message").Parse(messageTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid commit message template: %w", err)
+	}
+
+	var message strings.Builder
+	if err := tmpl.Execute(&message, data); err != nil {
+		return "", fmt.Errorf("invalid commit message template: %w", err)
+	}
+	return strings.TrimSpace(message.String()), nil
+}
+
+// parseIdentity splits "Name <email>" into name and email
+func parseIdentity(identity string) (string, string, error) {
+	match := identityRegex.FindStringSubmatch(identity)
+	if match == nil {
+		return "", "", fmt.Errorf("invalid identity %q: use \"Name <email>\"", identity)
+	}
+	return match[1], match[2], nil
+}
+
+// commitOptions returns the git commit settings of the sync options
+func commitOptions(options models.SyncOptions) models.CommitOptions {
+	return models.CommitOptions{
+		Author:    options.Author,
+		Committer: options.Committer,
+		Signoff:   options.Signoff,
+		GPGSign:   options.GPGSign,
+		GPGKey:    options.GPGKey,
+	}
+}
+
+// syncedPaths returns the slash-separated relative paths of the given operations, as passed to git
+func syncedPaths(operations []models.FileOperation) []string {
+	paths := make([]string, 0, len(operations))
+	for _, operation := range operations {
+		paths = append(paths, filepath.ToSlash(operation.RelativePath))
+	}
+	return paths
+}
+
+// checkUnrelatedChanges returns an UnrelatedChangesError when the repository of the plan target has
+// uncommitted changes, staged or not, to files the plan does not write. Uncommitted or untracked files the plan
+// would delete count as unrelated too, since deleting them loses work git can't restore.
+// Targets outside git are not checked.
+func (s *SyncService) checkUnrelatedChanges(plan *models.SyncPlan) error {
+	prefix, err := s.git.Prefix(plan.TargetDir)
+	if err != nil {
+		return nil
+	}
+
+	changedPaths, err := s.git.Status(plan.TargetDir)
+	if err != nil {
+		return err
+	}
+
+	synced := make(map[string]bool, len(plan.Operations))
+	for _, operation := range plan.Operations {
+		if operation.Type != models.OperationDelete {
+			synced[path.Join(prefix, filepath.ToSlash(operation.RelativePath))] = true
+		}
+	}
+
+	var unrelated []string
+	for _, changedPath := range changedPaths {
+		if !synced[changedPath] {
+			unrelated = append(unrelated, changedPath)
+		}
+	}
+	if len(unrelated) > 0 {
+		return &UnrelatedChangesError{RepoDir: plan.TargetDir, Files: unrelated}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestRenderCommitMessage(t *testing.T) {
+	data := models.CommitMessageData{
+		Project:       "demo",
+		ProjectCommit: "abc123",
+		Added:         []string{"a.mdc", "b.mdc"},
+		Deleted:       []string{"old.mdc"},
+		Summary:       models.SyncSummary{Added: 2, Deleted: 1},
+	}
+
+	tests := []struct {
+		template    string
+		expected    string
+		expectedErr bool
+		description string
+	}{
+		{
+			template:    "",
+			expected:    "Sync cursor rules: updated from project demo",
+			description: "Empty template should yield the default message",
+		},
+		{
+			template:    "rules({{.Project}}): {{.Summary.Added}} added, {{.Summary.Deleted}} deleted\n\nFrom {{.ProjectCommit}}\n{{range .Added}}\n+ {{.}}{{end}}\n",
+			expected:    "rules(demo): 2 added, 1 deleted\n\nFrom abc123\n\n+ a.mdc\n+ b.mdc",
+			description: "Template should have access to project data and file lists",
+		},
+		{
+			template:    "{{.Project",
+			expectedErr: true,
+			description: "Unparsable template should be rejected",
+		},
+		{
+			template:    "{{.Unknown}}",
+			expectedErr: true,
+			description: "Unknown fields should be rejected",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			message, err := renderCommitMessage(test.template, data)
+			if test.expectedErr {
+				if err == nil {
+					t.Errorf("Expected an error, got message %q", message)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if message != test.expected {
+				t.Errorf("Expected message %q, got %q", test.expected, message)
+			}
+		})
+	}
+}
+
+func TestCommitChangesWithIdentityAndSignoff(t *testing.T) {
+	repoDir := initTestRepo(t)
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	writeFileSystemFiles(t, NewOSFileSystem(), repoDir, map[string]string{"rule.mdc": "rule\n"})
+
+	options := models.SyncOptions{
+		GitWithoutPush: true,
+		Author:         "Rules Bot <bot@example.com>",
+		Committer:      "Release Team <release@example.com>",
+		Signoff:        true,
+	}
+	syncService := NewSyncService(NewOutputService(), NewExecGitClient(), NewOSFileSystem())
+	sha, _, err := syncService.commitChanges(repoDir, "add rule", []string{"rule.mdc"}, options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if author := runTestGit(t, repoDir, "log", "-1", "--format=%an <%ae>", sha); author != options.Author {
+		t.Errorf("Expected author %q, got %q", options.Author, author)
+	}
+	if committer := runTestGit(t, repoDir, "log", "-1", "--format=%cn <%ce>", sha); committer != options.Committer {
+		t.Errorf("Expected committer %q, got %q", options.Committer, committer)
+	}
+	if body := runTestGit(t, repoDir, "log", "-1", "--format=%B", sha); !strings.Contains(body, "Signed-off-by: Release Team <release@example.com>") {
+		t.Errorf("Expected a Signed-off-by trailer for the committer, got %q", body)
+	}
+}
+
+func TestValidateCommitOptions(t *testing.T) {
+	tests := []struct {
+		options     models.SyncOptions
+		expectedErr bool
+		description string
+	}{
+		{
+			options:     models.SyncOptions{Author: "Rules Bot <bot@example.com>", CommitMessage: "{{.Project}}"},
+			description: "Valid identity and template should be accepted",
+		},
+		{
+			options:     models.SyncOptions{Author: "bot@example.com"},
+			expectedErr: true,
+			description: "Identity without name should be rejected",
+		},
+		{
+			options:     models.SyncOptions{Committer: "Release Team"},
+			expectedErr: true,
+			description: "Identity without email should be rejected",
+		},
+		{
+			options:     models.SyncOptions{CommitMessage: "rules: {{index .Added 0}}\n\n{{(index .Operations 0).RelativePath}}"},
+			description: "Template indexing into the operations should be accepted",
+		},
+		{
+			options:     models.SyncOptions{CommitMessage: "{{.Unknown}}"},
+			expectedErr: true,
+			description: "Template with unknown fields should be rejected before syncing",
+		},
+		{
+			options:     models.SyncOptions{CommitMessage: "{{range .Operations}}"},
+			expectedErr: true,
+			description: "Invalid template should be rejected before syncing",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := validateCommitOptions(&test.options)
+			if test.expectedErr && err == nil {
+				t.Error("Expected an error")
+			}
+			if !test.expectedErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckUnrelatedChanges(t *testing.T) {
+	repoDir := initTestRepo(t)
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	rulesDir := filepath.Join(repoDir, "rules")
+	writeFileSystemFiles(t, NewOSFileSystem(), rulesDir, map[string]string{"rule.mdc": "v1\n"})
+	writeFileSystemFiles(t, NewOSFileSystem(), repoDir, map[string]string{"README.md": "readme\n"})
+	runTestGit(t, repoDir, "add", "-A")
+	runTestGit(t, repoDir, "commit", "-q", "-m", "initial")
+
+	syncService := NewSyncService(NewOutputService(), NewExecGitClient(), NewOSFileSystem())
+	plan := &models.SyncPlan{
+		TargetDir: rulesDir,
+		Operations: []models.FileOperation{
+			{Type: models.OperationUpdate, RelativePath: "rule.mdc"},
+			{Type: models.OperationAdd, RelativePath: "new.mdc"},
+		},
+	}
+
+	// Changes to the synced files themselves are expected
+	writeFileSystemFiles(t, NewOSFileSystem(), rulesDir, map[string]string{
+		"rule.mdc": "v2\n",
+		"new.mdc":  "new\n",
+	})
+	if err := syncService.checkUnrelatedChanges(plan); err != nil {
+		t.Fatalf("Expected synced changes to be accepted, got %v", err)
+	}
+
+	writeFileSystemFiles(t, NewOSFileSystem(), repoDir, map[string]string{"README.md": "edited\n"})
+	writeFileSystemFiles(t, NewOSFileSystem(), rulesDir, map[string]string{"drafts/junk.mdc": "junk\n"})
+	err := syncService.checkUnrelatedChanges(plan)
+	var unrelatedErr *UnrelatedChangesError
+	if !errors.As(err, &unrelatedErr) {
+		t.Fatalf("Expected UnrelatedChangesError, got %v", err)
+	}
+	expected := []string{"README.md", "rules/drafts/junk.mdc"}
+	if strings.Join(unrelatedErr.Files, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected unrelated files %v, got %v", expected, unrelatedErr.Files)
+	}
+}
+
+func TestCommitChangesOnlyCommitsSyncedPaths(t *testing.T) {
+	repoDir := initTestRepo(t)
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	writeFileSystemFiles(t, NewOSFileSystem(), repoDir, map[string]string{
+		"rule.mdc":  "v1\n",
+		"stale.mdc": "stale\n",
+		"notes.txt": "notes\n",
+	})
+	runTestGit(t, repoDir, "add", "-A")
+	runTestGit(t, repoDir, "commit", "-q", "-m", "initial")
+
+	writeFileSystemFiles(t, NewOSFileSystem(), repoDir, map[string]string{"rule.mdc": "v2\n"})
+	if err := os.Remove(filepath.Join(repoDir, "stale.mdc")); err != nil {
+		t.Fatal(err)
+	}
+	writeFileSystemFiles(t, NewOSFileSystem(), repoDir, map[string]string{"notes.txt": "staged edit\n"})
+	runTestGit(t, repoDir, "add", "notes.txt")
+	writeFileSystemFiles(t, NewOSFileSystem(), repoDir, map[string]string{"junk.tmp": "junk\n"})
+
+	syncService := NewSyncService(NewOutputService(), NewExecGitClient(), NewOSFileSystem())
+	paths := []string{"rule.mdc", "stale.mdc", "gone.mdc"}
+	if _, _, err := syncService.commitChanges(repoDir, "sync", paths, models.SyncOptions{GitWithoutPush: true}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	committed := runTestGit(t, repoDir, "show", "--name-status", "--format=", "HEAD")
+	if committed != "M\trule.mdc\nD\tstale.mdc" {
+		t.Errorf("Expected only synced paths to be committed, got %q", committed)
+	}
+	if status := runTestGit(t, repoDir, "status", "--porcelain"); status != "M  notes.txt\n?? junk.tmp" {
+		t.Errorf("Expected unrelated changes to stay uncommitted, got %q", status)
+	}
+}
+
+func TestCheckUnrelatedChangesWithFakeGitClient(t *testing.T) {
+	repoDir := t.TempDir()
+	rulesDir := filepath.Join(repoDir, "rules")
+	writeFileSystemFiles(t, NewOSFileSystem(), rulesDir, map[string]string{"rule.mdc": "v1\n"})
+
+	fileSystem := NewOSFileSystem()
+	git, err := newFakeGitClient(fileSystem, repoDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncService := NewSyncService(NewOutputService(), git, fileSystem)
+	plan := &models.SyncPlan{
+		TargetDir:  rulesDir,
+		Operations: []models.FileOperation{{Type: models.OperationUpdate, RelativePath: "rule.mdc"}},
+	}
+
+	writeFileSystemFiles(t, NewOSFileSystem(), rulesDir, map[string]string{"rule.mdc": "v2\n"})
+	if err := syncService.checkUnrelatedChanges(plan); err != nil {
+		t.Fatalf("Expected synced changes to be accepted, got %v", err)
+	}
+
+	writeFileSystemFiles(t, NewOSFileSystem(), repoDir, map[string]string{"draft.mdc": "draft\n"})
+	var unrelatedErr *UnrelatedChangesError
+	if err := syncService.checkUnrelatedChanges(plan); !errors.As(err, &unrelatedErr) || strings.Join(unrelatedErr.Files, ",") != "draft.mdc" {
+		t.Errorf("Expected draft.mdc to be reported as unrelated, got %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	projectConfigFileName = "rules-syncer.yaml"
+	userConfigDirName     = "cursor-rules-syncer"
+	userConfigFileName    = "config.yaml"
+	xdgConfigHomeEnvVar   = "XDG_CONFIG_HOME"
+)
+
+// LoadConfig reads the user config and the project config at the git root of projectDir and merges them,
+// the project config wins. An empty projectDir means the working directory.
+// Missing files are skipped, outside a git repository only the user config is read.
+func (s *SyncService) LoadConfig(projectDir string) (*models.Config, error) {
+	config := &models.Config{}
+
+	if userConfigPath, err := getUserConfigPath(); err == nil {
+		homeDir, _ := os.UserHomeDir()
+		userConfig, err := s.loadConfigFile(userConfigPath, homeDir)
+		if err != nil {
+			return nil, err
+		}
+		mergeConfig(config, userConfig)
+	}
+
+	currentDir := projectDir
+	if currentDir == "" {
+		var err error
+		if currentDir, err = os.Getwd(); err != nil {
+			return nil, fmt.Errorf("failed to get current directory: %w", err)
+		}
+	}
+	projectRoot, err := s.git.Root(currentDir)
+	if err != nil {
+		return config, nil
+	}
+
+	projectConfig, err := s.loadConfigFile(filepath.Join(projectRoot, cursorDirName, projectConfigFileName), projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	mergeConfig(config, projectConfig)
+
+	return config, nil
+}
+
+// ApplyConfig fills options that were not given on the command line from the config.
+// Environment variables take precedence over the config, so options they cover are left for later resolution.
+func (s *SyncService) ApplyConfig(options *models.SyncOptions, config *models.Config, isSet func(flagName string) bool) {
+	if !isSet("rules-dir") && os.Getenv(cursorRulesDirEnvVar) == "" && config.RulesDir != "" {
+		options.RulesDir = config.RulesDir
+	}
+	if !isSet("file-patterns") && os.Getenv(cursorRulesPatternsEnvVar) == "" && len(config.FilePatterns) > 0 {
+		options.FilePatterns = strings.Join(config.FilePatterns, ",")
+	}
+	if !isSet("ignore-files") && os.Getenv(cursorRulesIgnoreEnvVar) == "" && len(config.IgnoreFiles) > 0 {
+		options.IgnoreFiles = strings.Join(config.IgnoreFiles, ",")
+	}
+	if !isSet("line-endings") && config.LineEndings != "" {
+		options.LineEndings = models.LineEndingPolicy(config.LineEndings)
+	}
+	if !isSet("overwrite-headers") && config.Headers.Overwrite != nil {
+		options.OverwriteHeaders = *config.Headers.Overwrite
+	}
+	if config.Headers.Merge != nil {
+		s.applyHeaderMergeConfig(options, *config.Headers.Merge, isSet)
+	}
+	if !isSet("git-without-push") && config.Git.WithoutPush != nil {
+		options.GitWithoutPush = *config.Git.WithoutPush
+	}
+	if !isSet("no-fetch") && config.Git.Fetch != nil {
+		options.NoFetch = !*config.Git.Fetch
+	}
+	if !isSet("branch") && config.Git.Branch != "" {
+		options.Branch = config.Git.Branch
+	}
+	if !isSet("message") && config.Git.Message != "" {
+		options.CommitMessage = config.Git.Message
+	}
+	if !isSet("author") && config.Git.Author != "" {
+		options.Author = config.Git.Author
+	}
+	if !isSet("committer") && config.Git.Committer != "" {
+		options.Committer = config.Git.Committer
+	}
+	if !isSet("signoff") && config.Git.Signoff != nil {
+		options.Signoff = *config.Git.Signoff
+	}
+	if !isSet("gpg-sign") && config.Git.GPGSign != nil {
+		options.GPGSign = *config.Git.GPGSign
+	}
+	if !isSet("gpg-key") && config.Git.GPGKey != "" {
+		options.GPGKey = config.Git.GPGKey
+	}
+}
+
+// applyHeaderMergeConfig enables header merging with the configured policy unless headers are overwritten by flag.
+// Key policies given by flag take precedence over configured ones.
+func (s *SyncService) applyHeaderMergeConfig(options *models.SyncOptions, policy models.HeaderMergePolicy, isSet func(flagName string) bool) {
+	fields := make(map[string]models.HeaderFieldPolicy, len(policy.Fields)+len(options.HeaderPolicy.Fields))
+	for key, fieldPolicy := range policy.Fields {
+		fields[key] = fieldPolicy
+	}
+	for key, fieldPolicy := range options.HeaderPolicy.Fields {
+		fields[key] = fieldPolicy
+	}
+	policy.Fields = fields
+	options.HeaderPolicy = policy
+
+	if !isSet("merge-headers") && !isSet("overwrite-headers") {
+		options.MergeHeaders = true
+	}
+}
+
+// getUserConfigPath returns the user config path, honouring XDG_CONFIG_HOME
+func getUserConfigPath() (string, error) {
+	configDir := os.Getenv(xdgConfigHomeEnvVar)
+	if configDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configDir = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(configDir, userConfigDirName, userConfigFileName), nil
+}
+
+// loadConfigFile reads a single config file, a missing or empty file yields an empty config.
+// Unknown keys are rejected and a relative rules_dir is resolved against baseDir.
+func (s *SyncService) loadConfigFile(path, baseDir string) (*models.Config, error) {
+	content, err := s.fileSystem.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &models.Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	config := &models.Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	config.RulesDir = resolveConfigPath(config.RulesDir, baseDir)
+	return config, nil
+}
+
+// resolveConfigPath expands a leading ~ and makes relative paths absolute against baseDir, git URLs are kept as is
+func resolveConfigPath(path, baseDir string) string {
+	if _, _, isURL := parseGitURL(path); path == "" || isURL {
+		return path
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+		}
+	}
+	if !filepath.IsAbs(path) && baseDir != "" {
+		path = filepath.Join(baseDir, path)
+	}
+	return path
+}
+
+// mergeConfig overrides fields of config with the fields set in override
+func mergeConfig(config, override *models.Config) {
+	if override.RulesDir != "" {
+		config.RulesDir = override.RulesDir
+	}
+	if len(override.FilePatterns) > 0 {
+		config.FilePatterns = override.FilePatterns
+	}
+	if len(override.IgnoreFiles) > 0 {
+		config.IgnoreFiles = override.IgnoreFiles
+	}
+	if override.LineEndings != "" {
+		config.LineEndings = override.LineEndings
+	}
+	if override.Headers.Overwrite != nil {
+		config.Headers.Overwrite = override.Headers.Overwrite
+	}
+	if override.Headers.Merge != nil {
+		config.Headers.Merge = override.Headers.Merge
+	}
+	if override.Git.WithoutPush != nil {
+		config.Git.WithoutPush = override.Git.WithoutPush
+	}
+	if override.Git.Fetch != nil {
+		config.Git.Fetch = override.Git.Fetch
+	}
+	if override.Git.Branch != "" {
+		config.Git.Branch = override.Git.Branch
+	}
+	if override.Git.Message != "" {
+		config.Git.Message = override.Git.Message
+	}
+	if override.Git.Author != "" {
+		config.Git.Author = override.Git.Author
+	}
+	if override.Git.Committer != "" {
+		config.Git.Committer = override.Git.Committer
+	}
+	if override.Git.Signoff != nil {
+		config.Git.Signoff = override.Git.Signoff
+	}
+	if override.Git.GPGSign != nil {
+		config.Git.GPGSign = override.Git.GPGSign
+	}
+	if override.Git.GPGKey != "" {
+		config.Git.GPGKey = override.Git.GPGKey
+	}
+}
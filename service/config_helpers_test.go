@@ -0,0 +1,257 @@
+package service
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	baseDir := filepath.Join(string(filepath.Separator), "project")
+	configDir := filepath.Join(string(filepath.Separator), "config")
+
+	tests := []struct {
+		content          string
+		expectedRulesDir string
+		expectedErr      string
+		description      string
+	}{
+		{
+			content:          "rules_dir: shared/rules\n",
+			expectedRulesDir: filepath.Join(baseDir, "shared/rules"),
+			description:      "Relative rules_dir should be resolved against the base directory",
+		},
+		{
+			content:          "rules_dir: /abs/rules\n",
+			expectedRulesDir: "/abs/rules",
+			description:      "Absolute rules_dir should be kept",
+		},
+		{
+			content:     "",
+			description: "Empty file should yield an empty config",
+		},
+		{
+			content:     "rules_dri: /abs/rules\n",
+			expectedErr: "field rules_dri not found",
+			description: "Unknown keys should be rejected",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			fileSystem := NewMemoryFileSystem()
+			writeFileSystemFiles(t, fileSystem, configDir, map[string]string{projectConfigFileName: test.content})
+			syncService := NewSyncService(NewOutputService(), NewExecGitClient(), fileSystem)
+
+			config, err := syncService.loadConfigFile(filepath.Join(configDir, projectConfigFileName), baseDir)
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("Expected error containing %q, got %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if config.RulesDir != test.expectedRulesDir {
+				t.Errorf("Expected rules dir %q, got %q", test.expectedRulesDir, config.RulesDir)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	configHome := filepath.Join(string(filepath.Separator), "config")
+
+	tests := []struct {
+		projectDir       string
+		expectedRulesDir string
+		description      string
+	}{
+		{
+			projectDir:       filepath.Join(projectDir, "nested"),
+			expectedRulesDir: filepath.Join(projectDir, "shared"),
+			description:      "Project config at the git root of the project dir should override the user config",
+		},
+		{
+			projectDir:       filepath.Join(string(filepath.Separator), "elsewhere"),
+			expectedRulesDir: filepath.Join(string(filepath.Separator), "user", "rules"),
+			description:      "Outside a git repository only the user config should be read",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			t.Setenv(xdgConfigHomeEnvVar, configHome)
+			fileSystem := NewMemoryFileSystem()
+			writeFileSystemFiles(t, fileSystem, configHome, map[string]string{
+				userConfigDirName + "/" + userConfigFileName: "rules_dir: /user/rules\nfile_patterns: [\"user_*.mdc\"]\n",
+			})
+			writeFileSystemFiles(t, fileSystem, projectDir, map[string]string{
+				cursorDirName + "/" + projectConfigFileName: "rules_dir: shared\n",
+				"nested/rule.mdc": "rule\n",
+			})
+			git, err := newFakeGitClient(fileSystem, projectDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			syncService := NewSyncService(NewOutputService(), git, fileSystem)
+
+			config, err := syncService.LoadConfig(test.projectDir)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if config.RulesDir != test.expectedRulesDir {
+				t.Errorf("Expected rules dir %q, got %q", test.expectedRulesDir, config.RulesDir)
+			}
+			if !reflect.DeepEqual(config.FilePatterns, []string{"user_*.mdc"}) {
+				t.Errorf("Expected file patterns from the user config, got %v", config.FilePatterns)
+			}
+		})
+	}
+}
+
+func TestApplyConfigPrecedence(t *testing.T) {
+	outputService := NewOutputService()
+	syncService := NewSyncService(outputService, NewExecGitClient(), NewOSFileSystem())
+
+	enabled, disabled := true, false
+	userConfig := &models.Config{
+		RulesDir:     "/user/rules",
+		FilePatterns: []string{"user_*.mdc"},
+		Headers:      models.HeadersConfig{Overwrite: &enabled},
+		Git:          models.GitConfig{WithoutPush: &enabled},
+	}
+	projectConfig := &models.Config{
+		RulesDir: "/project/rules",
+		Headers:  models.HeadersConfig{Overwrite: &disabled},
+	}
+	config := &models.Config{}
+	mergeConfig(config, userConfig)
+	mergeConfig(config, projectConfig)
+
+	tests := []struct {
+		setFlags         []string
+		env              map[string]string
+		options          models.SyncOptions
+		expectedRulesDir string
+		expectedPatterns string
+		expectedHeaders  bool
+		description      string
+	}{
+		{
+			expectedRulesDir: "/project/rules",
+			expectedPatterns: "user_*.mdc",
+			expectedHeaders:  false,
+			description:      "Project config should override user config",
+		},
+		{
+			env:              map[string]string{cursorRulesDirEnvVar: "/env/rules", cursorRulesPatternsEnvVar: "env_*.mdc"},
+			expectedRulesDir: "",
+			expectedPatterns: "",
+			description:      "Environment variables should take precedence over config",
+		},
+		{
+			setFlags:         []string{"rules-dir", "overwrite-headers"},
+			options:          models.SyncOptions{RulesDir: "/flag/rules", OverwriteHeaders: true},
+			expectedRulesDir: "/flag/rules",
+			expectedPatterns: "user_*.mdc",
+			expectedHeaders:  true,
+			description:      "Flags should take precedence over config",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			for _, name := range []string{cursorRulesDirEnvVar, cursorRulesPatternsEnvVar, cursorRulesIgnoreEnvVar} {
+				t.Setenv(name, test.env[name])
+			}
+			isSet := func(flagName string) bool {
+				for _, setFlag := range test.setFlags {
+					if setFlag == flagName {
+						return true
+					}
+				}
+				return false
+			}
+
+			options := test.options
+			syncService.ApplyConfig(&options, config, isSet)
+
+			if options.RulesDir != test.expectedRulesDir {
+				t.Errorf("Expected rules dir %q, got %q", test.expectedRulesDir, options.RulesDir)
+			}
+			if options.FilePatterns != test.expectedPatterns {
+				t.Errorf("Expected file patterns %q, got %q", test.expectedPatterns, options.FilePatterns)
+			}
+			if options.OverwriteHeaders != test.expectedHeaders {
+				t.Errorf("Expected overwrite headers %v, got %v", test.expectedHeaders, options.OverwriteHeaders)
+			}
+			if !options.GitWithoutPush {
+				t.Errorf("Expected git without push from user config")
+			}
+		})
+	}
+}
+
+func TestApplyHeaderMergeConfig(t *testing.T) {
+	syncService := NewSyncService(NewOutputService(), NewExecGitClient(), NewOSFileSystem())
+	config := &models.Config{Headers: models.HeadersConfig{Merge: &models.HeaderMergePolicy{
+		Fields: map[string]models.HeaderFieldPolicy{frontmatterGlobs: models.HeaderUnion, frontmatterAlwaysApply: models.HeaderTakeSource},
+	}}}
+
+	tests := []struct {
+		setFlags       []string
+		options        models.SyncOptions
+		expectedMerge  bool
+		expectedFields map[string]models.HeaderFieldPolicy
+		description    string
+	}{
+		{
+			expectedMerge:  true,
+			expectedFields: map[string]models.HeaderFieldPolicy{frontmatterGlobs: models.HeaderUnion, frontmatterAlwaysApply: models.HeaderTakeSource},
+			description:    "Configured merge policy should enable merging",
+		},
+		{
+			setFlags:       []string{"header-policy"},
+			options:        models.SyncOptions{MergeHeaders: true, HeaderPolicy: models.HeaderMergePolicy{Fields: map[string]models.HeaderFieldPolicy{frontmatterGlobs: models.HeaderKeepLocal}}},
+			expectedMerge:  true,
+			expectedFields: map[string]models.HeaderFieldPolicy{frontmatterGlobs: models.HeaderKeepLocal, frontmatterAlwaysApply: models.HeaderTakeSource},
+			description:    "Key policies given by flag should take precedence",
+		},
+		{
+			setFlags:       []string{"overwrite-headers"},
+			options:        models.SyncOptions{OverwriteHeaders: true},
+			expectedMerge:  false,
+			expectedFields: map[string]models.HeaderFieldPolicy{frontmatterGlobs: models.HeaderUnion, frontmatterAlwaysApply: models.HeaderTakeSource},
+			description:    "Overwriting headers by flag should disable configured merging",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			isSet := func(flagName string) bool {
+				for _, setFlag := range test.setFlags {
+					if setFlag == flagName {
+						return true
+					}
+				}
+				return false
+			}
+
+			options := test.options
+			syncService.ApplyConfig(&options, config, isSet)
+
+			if options.MergeHeaders != test.expectedMerge {
+				t.Errorf("Expected merge headers %v, got %v", test.expectedMerge, options.MergeHeaders)
+			}
+			if !reflect.DeepEqual(options.HeaderPolicy.Fields, test.expectedFields) {
+				t.Errorf("Expected header policies %v, got %v", test.expectedFields, options.HeaderPolicy.Fields)
+			}
+		})
+	}
+}
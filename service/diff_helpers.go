@@ -0,0 +1,179 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+const (
+	diffContextLines = 3
+	diffNullPath     = "/dev/null"
+)
+
+// Diff plans a sync in the given direction and returns its operations with unified diffs attached.
+// Nothing is written to disk.
+func (s *SyncService) Diff(direction models.SyncDirection, options *models.SyncOptions) (*models.SyncResult, error) {
+	diffOptions := *options
+	diffOptions.DryRun = true
+	diffOptions.ShowDiff = true
+
+	plan, err := s.Plan(direction, &diffOptions)
+	if err != nil {
+		return nil, err
+	}
+	return s.execute(plan)
+}
+
+// attachDiffs fills in the diffs of every operation in the plan, it must run before the plan is applied
+func (s *SyncService) attachDiffs(plan *models.SyncPlan) {
+	for i := range plan.Operations {
+		operation := &plan.Operations[i]
+		if err := s.attachDiff(plan, operation); err != nil {
+			s.outputService.PrintErrorf("Error computing diff for %s: %v\n", operation.RelativePath, err)
+			continue
+		}
+		// Diffs compare normalized content, an update they can't show only changes the formatting
+		operation.FormatOnly = operation.Type == models.OperationUpdate && operation.Diff == "" &&
+			operation.HeaderDiff == "" && !operation.HeaderPreserved
+	}
+}
+
+// attachDiff renders the change an operation makes to its target.
+// For .mdc files the body and the header are diffed separately, a header kept by the
+// header-preserving copy is not diffed and only flagged as preserved.
+func (s *SyncService) attachDiff(plan *models.SyncPlan, operation *models.FileOperation) error {
+	targetLabel, sourceLabel := mergeLabels(plan)
+	oldName := targetLabel + "/" + filepath.ToSlash(operation.RelativePath)
+	newName := sourceLabel + "/" + filepath.ToSlash(operation.RelativePath)
+
+	switch operation.Type {
+	case models.OperationAdd:
+		content, err := s.readFileNormalized(operation.SourcePath)
+		if err != nil {
+			return err
+		}
+		operation.Diff = unifiedDiff(diffNullPath, newName, "", content)
+		return nil
+	case models.OperationDelete:
+		content, err := s.readFileNormalized(operation.TargetPath)
+		if err != nil {
+			return err
+		}
+		operation.Diff = unifiedDiff(oldName, diffNullPath, content, "")
+		return nil
+	}
+
+	srcContent, err := s.readFileNormalized(operation.SourcePath)
+	if err != nil {
+		return err
+	}
+	dstContent, err := s.readFileNormalized(operation.TargetPath)
+	if err != nil {
+		return err
+	}
+
+	if filepath.Ext(operation.SourcePath) != mdcExtension {
+		newContent := srcContent
+		if operation.Merged {
+			newContent = operation.MergedBody
+		}
+		operation.Diff = unifiedDiff(oldName, newName, dstContent, newContent)
+		return nil
+	}
+
+	srcHeader, newBody, err := splitFrontmatter(srcContent)
+	if err != nil {
+		return fmt.Errorf("invalid header in %s: %w", operation.SourcePath, err)
+	}
+	dstHeader, dstBody, err := splitFrontmatter(dstContent)
+	if err != nil {
+		return fmt.Errorf("invalid header in %s: %w", operation.TargetPath, err)
+	}
+	if operation.Merged {
+		newBody = operation.MergedBody
+	}
+	operation.Diff = unifiedDiff(oldName, newName, dstBody, newBody)
+
+	// Mirrors syncedContent: the destination header survives unless headers are overwritten, merged, overridden or it has none
+	header, err := s.destinationHeader(srcHeader, dstHeader, operation.RelativePath, plan.Options)
+	if err != nil {
+		return fmt.Errorf("failed to build header of %s: %w", operation.RelativePath, err)
+	}
+	if header == dstHeader {
+		operation.HeaderPreserved = srcHeader != dstHeader
+		return nil
+	}
+	operation.HeaderDiff = unifiedDiff(oldName, newName, dstHeader, header)
+	return nil
+}
+
+// unifiedDiff renders a unified diff turning oldContent into newContent, it is empty when both are equal
+func unifiedDiff(oldName, newName, oldContent, newContent string) string {
+	oldLines := splitLines(oldContent)
+	hunks := diffHunks(oldLines, splitLines(newContent))
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var diff strings.Builder
+	fmt.Fprintf(&diff, "--- %s\n+++ %s\n", oldName, newName)
+
+	// Hunks closer than twice the context are printed as one block, offset tracks the new-side shift
+	offset := 0
+	for start := 0; start < len(hunks); {
+		end := start + 1
+		for end < len(hunks) && hunks[end].baseStart-hunks[end-1].baseEnd <= 2*diffContextLines {
+			end++
+		}
+		offset = writeUnifiedBlock(&diff, oldLines, hunks[start:end], offset)
+		start = end
+	}
+	return diff.String()
+}
+
+// writeUnifiedBlock writes one @@ block for a group of hunks and returns the updated new-side offset
+func writeUnifiedBlock(diff *strings.Builder, oldLines []string, hunks []diffHunk, offset int) int {
+	first, last := hunks[0], hunks[len(hunks)-1]
+	oldStart := max(0, first.baseStart-diffContextLines)
+	oldEnd := min(len(oldLines), last.baseEnd+diffContextLines)
+
+	newCount := oldEnd - oldStart
+	for _, hunk := range hunks {
+		newCount += len(hunk.lines) - (hunk.baseEnd - hunk.baseStart)
+	}
+	fmt.Fprintf(diff, "@@ -%s +%s @@\n", unifiedRange(oldStart, oldEnd-oldStart), unifiedRange(oldStart+offset, newCount))
+
+	index := oldStart
+	for _, hunk := range hunks {
+		for _, line := range oldLines[index:hunk.baseStart] {
+			diff.WriteString(" " + line + "\n")
+		}
+		for _, line := range oldLines[hunk.baseStart:hunk.baseEnd] {
+			diff.WriteString("-" + line + "\n")
+		}
+		for _, line := range hunk.lines {
+			diff.WriteString("+" + line + "\n")
+		}
+		index = hunk.baseEnd
+		offset += len(hunk.lines) - (hunk.baseEnd - hunk.baseStart)
+	}
+	for _, line := range oldLines[index:oldEnd] {
+		diff.WriteString(" " + line + "\n")
+	}
+	return offset
+}
+
+// unifiedRange formats a zero-based line range as used in @@ headers
+func unifiedRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
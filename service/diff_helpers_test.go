@@ -0,0 +1,144 @@
+package service
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		oldContent  string
+		newContent  string
+		expected    string
+		description string
+	}{
+		{
+			oldContent:  "one\ntwo\n",
+			newContent:  "one\ntwo\n",
+			expected:    "",
+			description: "Equal content should produce no diff",
+		},
+		{
+			oldContent:  "one\ntwo\nthree\n",
+			newContent:  "one\nTWO\nthree\n",
+			expected:    "--- old\n+++ new\n@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three\n",
+			description: "Changed line should be shown with context",
+		},
+		{
+			oldContent:  "",
+			newContent:  "one\n",
+			expected:    "--- old\n+++ new\n@@ -0,0 +1 @@\n+one\n",
+			description: "Added file should diff against empty content",
+		},
+		{
+			oldContent:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			newContent:  "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\nthirteen\n",
+			expected:    "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -10,3 +10,4 @@\n 10\n 11\n 12\n+thirteen\n",
+			description: "Distant changes should be split into separate blocks",
+		},
+		{
+			oldContent:  "1\n2\n3\n4\n5\n6\n7\n",
+			newContent:  "one\n2\n3\n4\n5\n6\nseven\n",
+			expected:    "--- old\n+++ new\n@@ -1,7 +1,7 @@\n-1\n+one\n 2\n 3\n 4\n 5\n 6\n-7\n+seven\n",
+			description: "Close changes should share one block",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			diff := unifiedDiff("old", "new", test.oldContent, test.newContent)
+			if diff != test.expected {
+				t.Errorf("Unexpected diff:\n%s\nexpected:\n%s", diff, test.expected)
+			}
+		})
+	}
+}
+
+func TestAttachDiffHeaders(t *testing.T) {
+	outputService := NewOutputService()
+	syncService := NewSyncService(outputService, NewExecGitClient(), NewOSFileSystem())
+
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	writeFileSystemFiles(t, NewOSFileSystem(), srcDir, map[string]string{"rule.mdc": "---\ndescription: central\n---\nbody\n"})
+	writeFileSystemFiles(t, NewOSFileSystem(), dstDir, map[string]string{"rule.mdc": "---\ndescription: project\n---\nbody\n"})
+
+	tests := []struct {
+		overwriteHeaders  bool
+		expectedHeader    string
+		expectedPreserved bool
+		description       string
+	}{
+		{
+			overwriteHeaders:  false,
+			expectedPreserved: true,
+			description:       "Preserved header should be flagged but not diffed",
+		},
+		{
+			overwriteHeaders: true,
+			expectedHeader:   "--- project/rule.mdc\n+++ central/rule.mdc\n@@ -1,3 +1,3 @@\n ---\n-description: project\n+description: central\n ---\n",
+			description:      "Overwritten header should be diffed separately",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			plan := &models.SyncPlan{
+				Direction: models.DirectionPull,
+				SourceDir: srcDir,
+				TargetDir: dstDir,
+				Options:   models.SyncOptions{OverwriteHeaders: test.overwriteHeaders},
+			}
+			operation := models.FileOperation{
+				Type:         models.OperationUpdate,
+				SourcePath:   filepath.Join(srcDir, "rule.mdc"),
+				TargetPath:   filepath.Join(dstDir, "rule.mdc"),
+				RelativePath: "rule.mdc",
+			}
+
+			if err := syncService.attachDiff(plan, &operation); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if operation.Diff != "" {
+				t.Errorf("Expected no body diff, got %q", operation.Diff)
+			}
+			if operation.HeaderDiff != test.expectedHeader {
+				t.Errorf("Unexpected header diff:\n%s\nexpected:\n%s", operation.HeaderDiff, test.expectedHeader)
+			}
+			if operation.HeaderPreserved != test.expectedPreserved {
+				t.Errorf("Expected HeaderPreserved=%v, got %v", test.expectedPreserved, operation.HeaderPreserved)
+			}
+		})
+	}
+}
+
+func TestDiffShowsFormatOnlyUpdates(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	projectRulesDir := filepath.Join(projectDir, cursorDirName, rulesDirName)
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+
+	fileSystem := NewMemoryFileSystem()
+	writeFileSystemFiles(t, fileSystem, projectRulesDir, map[string]string{"rule.mdc": "---\r\ndescription: Go\r\n---\r\nUse gofmt.\r\n"})
+	writeFileSystemFiles(t, fileSystem, rulesDir, map[string]string{"rule.mdc": "---\ndescription: Go\n---\nUse gofmt.\n"})
+	git, err := newFakeGitClient(fileSystem, projectDir, rulesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), git, fileSystem)
+	result, err := syncService.Diff(models.DirectionPull, &models.SyncOptions{RulesDir: rulesDir, ProjectDir: projectDir})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Operations) != 1 || !result.Operations[0].FormatOnly || result.Operations[0].Diff != "" {
+		t.Fatalf("Expected one format-only update without a diff, got %+v", result.Operations)
+	}
+	if !strings.Contains(stdout.String(), "only line endings or trailing whitespace differ") {
+		t.Errorf("Expected the format-only update to be explained, got %q", stdout.String())
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+// IgnoreConflictError is returned by pull when ignored files already exist in the destination project
+type IgnoreConflictError struct {
+	Files []string
+}
+
+// Error implements the error interface
+func (e *IgnoreConflictError) Error() string {
+	return fmt.Sprintf("ignored files exist in destination project, remove them or update .ruleignore:\n  %s",
+		strings.Join(e.Files, "\n  "))
+}
+
+// RebaseConflictError is returned when local commits in the rules repository conflict with its upstream
+type RebaseConflictError struct {
+	RepoDir  string
+	Upstream string
+}
+
+// Error implements the error interface
+func (e *RebaseConflictError) Error() string {
+	return fmt.Sprintf("local commits in %s conflict with %s, the rebase was aborted: run 'git pull --rebase' there and resolve the conflicts, or use --no-fetch",
+		e.RepoDir, e.Upstream)
+}
+
+// UnrelatedChangesError is returned by push when the rules repository has uncommitted changes outside the synced files
+type UnrelatedChangesError struct {
+	RepoDir string
+	Files   []string
+}
+
+// Error implements the error interface
+func (e *UnrelatedChangesError) Error() string {
+	return fmt.Sprintf("%s has uncommitted changes not made by the sync, commit or stash them, or use --force to push without them:\n  %s",
+		e.RepoDir, strings.Join(e.Files, "\n  "))
+}
+
+// FrontmatterError describes a malformed YAML header of an .mdc file
+type FrontmatterError struct {
+	Line    int    // Line in the file, 1 for the opening separator
+	Key     string // Key whose value is invalid, empty for syntax errors
+	Message string
+}
+
+// Error implements the error interface
+func (e *FrontmatterError) Error() string {
+	return fmt.Sprintf("frontmatter line %d: %s", e.Line, e.Message)
+}
+
+// LintError is returned by push when the project rules fail the lint run requested with --lint
+type LintError struct {
+	Report *models.LintReport
+}
+
+// Error implements the error interface
+func (e *LintError) Error() string {
+	return fmt.Sprintf("lint found %d error(s) in %s, nothing was pushed", e.Report.Errors, e.Report.Dir)
+}
+
+// FileSyncError is the failure of a single file in an otherwise completed sync
+type FileSyncError struct {
+	Type         models.OperationType
+	RelativePath string
+	Err          error
+}
+
+// Error implements the error interface
+func (e *FileSyncError) Error() string {
+	return fmt.Sprintf("%s: %v", e.RelativePath, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *FileSyncError) Unwrap() error {
+	return e.Err
+}
+
+// SyncError is returned together with the result of a sync in which some files could not be synced
+// or the commit failed. Err joins the file failures and the commit failure.
+type SyncError struct {
+	Direction models.SyncDirection
+	Files     []*FileSyncError
+	CommitErr error
+	Err       error
+}
+
+// Error implements the error interface
+func (e *SyncError) Error() string {
+	return fmt.Sprintf("%s finished with errors:\n%v", e.Direction, e.Err)
+}
+
+// Unwrap returns the joined failures
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
+
+// newSyncError returns a SyncError for the given failures, nil when there are none
+func newSyncError(direction models.SyncDirection, files []*FileSyncError, commitErr error) error {
+	if len(files) == 0 && commitErr == nil {
+		return nil
+	}
+
+	errs := make([]error, 0, len(files)+1)
+	for _, file := range files {
+		errs = append(errs, file)
+	}
+	if commitErr != nil {
+		errs = append(errs, fmt.Errorf("commit failed: %w", commitErr))
+	}
+	return &SyncError{Direction: direction, Files: files, CommitErr: commitErr, Err: errors.Join(errs...)}
+}
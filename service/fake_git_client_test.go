@@ -0,0 +1,739 @@
+package service
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+// fakeGitClient is an in-memory GitClient for a set of repositories whose working trees are read
+// through a FileSystem. The files present when it is created form the initial commits on main.
+// Repositories served under a URL can be cloned, and clones fetch from and push to them.
+type fakeGitClient struct {
+	fileSystem FileSystem
+	repos      map[string]*fakeRepo
+	urls       map[string]*fakeRepo // Repositories that can be cloned by URL
+}
+
+// fakeRepo is the state of a single repository, or of a worktree sharing refs and commits with one
+type fakeRepo struct {
+	root      string
+	originURL string
+	origin    *fakeRepo // Repository origin refers to, nil without one
+	commitErr error
+	pushErr   error
+	*fakeStore
+	branch    string             // Checked out branch, empty when detached
+	head      map[string]string  // Committed content by path relative to root
+	index     map[string]*string // Staged content by path relative to root, nil for staged deletions
+	commits   []fakeCommit       // History of HEAD, oldest first
+	worktrees []*fakeRepo        // Worktrees added through the fake, kept after removal
+	removed   bool               // Whether the worktree was removed
+	rebasing  bool
+	pushes    int
+	fetches   int
+}
+
+// fakeStore holds what worktrees of a repository share
+type fakeStore struct {
+	refs          map[string]string     // Commit SHA by fully qualified ref
+	upstreams     map[string]string     // Upstream of local branches, such as origin/main
+	objects       map[string]fakeCommit // Every known commit by SHA
+	defaultBranch string                // Branch origin/HEAD points to
+}
+
+// fakeCommit records a commit of the fake
+type fakeCommit struct {
+	sha     string
+	parent  string
+	message string
+	paths   []string
+	options models.CommitOptions
+	tree    map[string]string // Content of every file by path relative to the root
+}
+
+func newFakeGitClient(fileSystem FileSystem, roots ...string) (*fakeGitClient, error) {
+	client := &fakeGitClient{fileSystem: fileSystem, repos: make(map[string]*fakeRepo), urls: make(map[string]*fakeRepo)}
+	for _, root := range roots {
+		client.repos[root] = newFakeRepo(root, newFakeStore())
+	}
+	for _, repo := range client.repos {
+		head, err := client.workingTree(repo)
+		if err != nil {
+			return nil, err
+		}
+		repo.branch = "main"
+		repo.record(fakeCommit{sha: fakeSHA(repo.root, 1), message: "initial", tree: head})
+	}
+	return client, nil
+}
+
+func newFakeRepo(root string, store *fakeStore) *fakeRepo {
+	return &fakeRepo{root: root, fakeStore: store, head: make(map[string]string), index: make(map[string]*string)}
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{refs: make(map[string]string), upstreams: make(map[string]string), objects: make(map[string]fakeCommit)}
+}
+
+func fakeSHA(root string, n int) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(fmt.Sprintf("%s#%d", root, n))))
+}
+
+// serve makes the repository at root clonable from url
+func (c *fakeGitClient) serve(url, root string) {
+	c.repos[root].defaultBranch = c.repos[root].branch
+	c.urls[url] = c.repos[root]
+}
+
+// commitFiles commits files, keyed by path relative to the root, on top of HEAD of the repository at root,
+// as a commit made by someone else
+func (c *fakeGitClient) commitFiles(t *testing.T, root, message string, files map[string]string) string {
+	t.Helper()
+	repo := c.repos[root]
+	writeFileSystemFiles(t, c.fileSystem, root, files)
+	tree := copyTree(repo.head)
+	var paths []string
+	for path, content := range files {
+		tree[path] = content
+		paths = append(paths, path)
+	}
+	commit := fakeCommit{sha: repo.nextSHA(), parent: repo.headSHA(), message: message, paths: paths, tree: tree}
+	repo.record(commit)
+	return commit.sha
+}
+
+// snapshot describes the refs, HEAD, index, history and pushes of every repository, for comparing git state
+func (c *fakeGitClient) snapshot() map[string]string {
+	state := make(map[string]string, len(c.repos))
+	for root, repo := range c.repos {
+		openWorktrees := 0
+		for _, worktree := range repo.worktrees {
+			if !worktree.removed {
+				openWorktrees++
+			}
+		}
+		state[root] = fmt.Sprintf("branch=%s head=%s refs=%v upstreams=%v index=%d commits=%d pushes=%d worktrees=%d rebasing=%v",
+			repo.branch, repo.headSHA(), repo.refs, repo.upstreams, len(repo.index), len(repo.commits), repo.pushes, openWorktrees, repo.rebasing)
+	}
+	return state
+}
+
+// record makes commit the new HEAD, moving the checked out branch
+func (r *fakeRepo) record(commit fakeCommit) {
+	r.objects[commit.sha] = commit
+	r.commits = append(r.commits, commit)
+	r.head = copyTree(commit.tree)
+	if r.branch != "" {
+		r.refs["refs/heads/"+r.branch] = commit.sha
+	}
+}
+
+func (r *fakeRepo) nextSHA() string {
+	return fakeSHA(r.root, len(r.objects)+1)
+}
+
+func (r *fakeRepo) headSHA() string {
+	if len(r.commits) == 0 {
+		return ""
+	}
+	return r.commits[len(r.commits)-1].sha
+}
+
+// resolve returns the commit rev names: HEAD, a branch, a tag, a remote branch, a ref or a SHA
+func (r *fakeRepo) resolve(rev string) (fakeCommit, bool) {
+	if rev == "HEAD" {
+		rev = r.headSHA()
+	}
+	for _, ref := range []string{rev, "refs/heads/" + rev, "refs/tags/" + rev, "refs/remotes/" + rev} {
+		if sha, ok := r.refs[ref]; ok {
+			rev = sha
+			break
+		}
+	}
+	commit, ok := r.objects[rev]
+	return commit, ok
+}
+
+// history returns the commits leading to sha, oldest first
+func (r *fakeRepo) history(sha string) []fakeCommit {
+	var commits []fakeCommit
+	for sha != "" {
+		commit := r.objects[sha]
+		commits = append([]fakeCommit{commit}, commits...)
+		sha = commit.parent
+	}
+	return commits
+}
+
+func (r *fakeRepo) ancestors(sha string) map[string]bool {
+	ancestors := make(map[string]bool)
+	for _, commit := range r.history(sha) {
+		ancestors[commit.sha] = true
+	}
+	return ancestors
+}
+
+func copyTree(tree map[string]string) map[string]string {
+	copied := make(map[string]string, len(tree))
+	for path, content := range tree {
+		copied[path] = content
+	}
+	return copied
+}
+
+// repo returns the innermost repository containing dir
+func (c *fakeGitClient) repo(dir string) (*fakeRepo, error) {
+	var found *fakeRepo
+	for root, repo := range c.repos {
+		if _, ok := relativeToRoot(root, dir); ok && (found == nil || len(root) > len(found.root)) {
+			found = repo
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s is not in a git repository", dir)
+	}
+	return found, nil
+}
+
+// relativeToRoot returns path as a slash-separated path relative to root, false when it is outside root
+func relativeToRoot(root, path string) (string, bool) {
+	relativePath, err := filepath.Rel(root, path)
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(relativePath), true
+}
+
+// workingTree reads every file of the repository except .git and nested repositories
+func (c *fakeGitClient) workingTree(repo *fakeRepo) (map[string]string, error) {
+	files := make(map[string]string)
+	err := c.fileSystem.WalkDir(repo.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if nested := c.repos[path]; entry.Name() == gitDirName || (nested != nil && nested != repo) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		content, err := c.fileSystem.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relativePath, _ := relativeToRoot(repo.root, path)
+		files[relativePath] = string(content)
+		return nil
+	})
+	return files, err
+}
+
+// checkout replaces the working tree with the tree of commit and makes it HEAD, discarding staged changes
+func (c *fakeGitClient) checkout(repo *fakeRepo, commit fakeCommit) error {
+	tree, err := c.workingTree(repo)
+	if err != nil {
+		return err
+	}
+	for path := range tree {
+		if err := c.fileSystem.Remove(filepath.Join(repo.root, filepath.FromSlash(path))); err != nil {
+			return err
+		}
+	}
+	for path, content := range commit.tree {
+		filePath := filepath.Join(repo.root, filepath.FromSlash(path))
+		if err := c.fileSystem.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return err
+		}
+		if err := c.fileSystem.WriteFile(filePath, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	repo.commits = repo.history(commit.sha)
+	repo.head = copyTree(commit.tree)
+	repo.index = make(map[string]*string)
+	return nil
+}
+
+func (c *fakeGitClient) Root(dir string) (string, error) {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return "", err
+	}
+	return repo.root, nil
+}
+
+func (c *fakeGitClient) Prefix(dir string) (string, error) {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return "", err
+	}
+	if prefix, _ := relativeToRoot(repo.root, dir); prefix != "." {
+		return prefix + "/", nil
+	}
+	return "", nil
+}
+
+func (c *fakeGitClient) Status(dir string) ([]string, error) {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := c.workingTree(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]bool)
+	for path, content := range tree {
+		if committed, ok := repo.head[path]; !ok || committed != content {
+			changed[path] = true
+		}
+	}
+	for path := range repo.head {
+		if _, ok := tree[path]; !ok {
+			changed[path] = true
+		}
+	}
+	for path, staged := range repo.index {
+		if repo.differsFromHead(path, staged) {
+			changed[path] = true
+		}
+	}
+
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (r *fakeRepo) differsFromHead(path string, staged *string) bool {
+	committed, ok := r.head[path]
+	if staged == nil {
+		return ok
+	}
+	return !ok || committed != *staged
+}
+
+func (c *fakeGitClient) Add(dir string, paths []string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		relativePath, _ := relativeToRoot(repo.root, filepath.Join(dir, path))
+		content, err := c.fileSystem.ReadFile(filepath.Join(dir, path))
+		switch {
+		case err == nil:
+			staged := string(content)
+			repo.index[relativePath] = &staged
+		case errors.Is(err, fs.ErrNotExist):
+			if _, ok := repo.head[relativePath]; ok {
+				repo.index[relativePath] = nil
+			}
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *fakeGitClient) Unstage(dir string, paths []string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		relativePath, _ := relativeToRoot(repo.root, filepath.Join(dir, path))
+		delete(repo.index, relativePath)
+	}
+	return nil
+}
+
+func (c *fakeGitClient) Commit(dir, message string, paths []string, options models.CommitOptions) (bool, error) {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return false, err
+	}
+	if repo.commitErr != nil {
+		return false, repo.commitErr
+	}
+
+	tree := copyTree(repo.head)
+	var committed []string
+	for _, path := range paths {
+		relativePath, _ := relativeToRoot(repo.root, filepath.Join(dir, path))
+		staged, ok := repo.index[relativePath]
+		if !ok {
+			continue
+		}
+		delete(repo.index, relativePath)
+		if !repo.differsFromHead(relativePath, staged) {
+			continue
+		}
+		if staged == nil {
+			delete(tree, relativePath)
+		} else {
+			tree[relativePath] = *staged
+		}
+		committed = append(committed, relativePath)
+	}
+	if len(committed) == 0 {
+		return false, nil
+	}
+
+	repo.record(fakeCommit{
+		sha:     repo.nextSHA(),
+		parent:  repo.headSHA(),
+		message: message,
+		paths:   committed,
+		options: options,
+		tree:    tree,
+	})
+	return true, nil
+}
+
+func (c *fakeGitClient) Push(dir string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	if repo.pushErr != nil {
+		return repo.pushErr
+	}
+	repo.pushes++
+
+	if repo.origin != nil && repo.branch != "" {
+		for sha, commit := range repo.objects {
+			repo.origin.objects[sha] = commit
+		}
+		repo.origin.refs["refs/heads/"+repo.branch] = repo.headSHA()
+		repo.refs["refs/remotes/origin/"+repo.branch] = repo.headSHA()
+		repo.upstreams[repo.branch] = "origin/" + repo.branch
+	}
+	return nil
+}
+
+func (c *fakeGitClient) Fetch(dir string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	repo.fetches++
+
+	if repo.origin != nil {
+		for sha, commit := range repo.origin.objects {
+			repo.objects[sha] = commit
+		}
+		for ref, sha := range repo.origin.refs {
+			switch {
+			case strings.HasPrefix(ref, "refs/heads/"):
+				repo.refs["refs/remotes/origin/"+strings.TrimPrefix(ref, "refs/heads/")] = sha
+			case strings.HasPrefix(ref, "refs/tags/"):
+				repo.refs[ref] = sha
+			}
+		}
+	}
+	return nil
+}
+
+func (c *fakeGitClient) HeadSHA(dir string) (string, error) {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return "", err
+	}
+	return repo.headSHA(), nil
+}
+
+func (c *fakeGitClient) RemoteURL(dir string) (string, error) {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return "", nil
+	}
+	return repo.originURL, nil
+}
+
+func (c *fakeGitClient) Clone(url, dir string) error {
+	source, ok := c.urls[url]
+	if !ok {
+		return fmt.Errorf("repository %s not found", url)
+	}
+	if err := c.fileSystem.MkdirAll(filepath.Join(dir, gitDirName), 0755); err != nil {
+		return err
+	}
+
+	repo := newFakeRepo(dir, newFakeStore())
+	repo.origin, repo.originURL, repo.defaultBranch = source, url, source.defaultBranch
+	c.repos[dir] = repo
+	if err := c.Fetch(dir); err != nil {
+		return err
+	}
+	repo.fetches = 0
+	return c.CheckoutTracking(dir, repo.defaultBranch)
+}
+
+func (c *fakeGitClient) DiscardChanges(dir string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	return c.checkout(repo, repo.objects[repo.headSHA()])
+}
+
+func (c *fakeGitClient) DefaultBranch(dir string) (string, error) {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return "", err
+	}
+	if repo.defaultBranch == "" {
+		return "", fmt.Errorf("%s has no default branch", dir)
+	}
+	return repo.defaultBranch, nil
+}
+
+func (c *fakeGitClient) RefExists(dir, ref string) bool {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return false
+	}
+	_, ok := repo.refs[ref]
+	return ok
+}
+
+func (c *fakeGitClient) Checkout(dir, branch string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	sha, ok := repo.refs["refs/heads/"+branch]
+	if !ok {
+		return fmt.Errorf("branch %s not found", branch)
+	}
+	repo.branch = branch
+	return c.checkout(repo, repo.objects[sha])
+}
+
+func (c *fakeGitClient) CheckoutDetached(dir, rev string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	commit, ok := repo.resolve(rev)
+	if !ok {
+		return fmt.Errorf("revision %s not found", rev)
+	}
+	repo.branch = ""
+	return c.checkout(repo, commit)
+}
+
+func (c *fakeGitClient) CheckoutTracking(dir, branch string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	sha, ok := repo.refs["refs/remotes/origin/"+branch]
+	if !ok {
+		return fmt.Errorf("branch origin/%s not found", branch)
+	}
+	repo.refs["refs/heads/"+branch] = sha
+	repo.upstreams[branch] = "origin/" + branch
+	return c.Checkout(dir, branch)
+}
+
+func (c *fakeGitClient) Upstream(dir string) (string, error) {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return "", err
+	}
+	return repo.upstreams[repo.branch], nil
+}
+
+func (c *fakeGitClient) AheadBehind(dir, rev string) (int, int, error) {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	target, ok := repo.resolve(rev)
+	if !ok {
+		return 0, 0, fmt.Errorf("revision %s not found", rev)
+	}
+
+	local, upstream := repo.ancestors(repo.headSHA()), repo.ancestors(target.sha)
+	var ahead, behind int
+	for sha := range local {
+		if !upstream[sha] {
+			ahead++
+		}
+	}
+	for sha := range upstream {
+		if !local[sha] {
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+func (c *fakeGitClient) MergeFastForward(dir, rev string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	target, ok := repo.resolve(rev)
+	if !ok {
+		return fmt.Errorf("revision %s not found", rev)
+	}
+	if !repo.ancestors(target.sha)[repo.headSHA()] {
+		return fmt.Errorf("not possible to fast-forward to %s", rev)
+	}
+	if repo.branch != "" {
+		repo.refs["refs/heads/"+repo.branch] = target.sha
+	}
+	return c.checkout(repo, target)
+}
+
+// Rebase replays the commits only HEAD has onto rev. It fails, leaving the rebase in progress,
+// when a replayed commit changes a file that also changed on the way to rev.
+func (c *fakeGitClient) Rebase(dir, rev string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	target, ok := repo.resolve(rev)
+	if !ok {
+		return fmt.Errorf("revision %s not found", rev)
+	}
+
+	upstream := repo.ancestors(target.sha)
+	var base fakeCommit
+	var local []fakeCommit
+	for _, commit := range repo.commits {
+		if upstream[commit.sha] {
+			base = commit
+		} else {
+			local = append(local, commit)
+		}
+	}
+
+	tree := copyTree(target.tree)
+	for _, commit := range local {
+		for _, path := range commit.paths {
+			if target.tree[path] != base.tree[path] {
+				repo.rebasing = true
+				return fmt.Errorf("could not apply %s: conflict in %s", commit.sha, path)
+			}
+		}
+	}
+
+	parent := target.sha
+	for _, commit := range local {
+		for _, path := range commit.paths {
+			if content, ok := commit.tree[path]; ok {
+				tree[path] = content
+			} else {
+				delete(tree, path)
+			}
+		}
+		commit.sha, commit.parent, commit.tree = repo.nextSHA(), parent, copyTree(tree)
+		repo.objects[commit.sha] = commit
+		parent = commit.sha
+	}
+	if repo.branch != "" {
+		repo.refs["refs/heads/"+repo.branch] = parent
+	}
+	return c.checkout(repo, repo.objects[parent])
+}
+
+func (c *fakeGitClient) AbortRebase(dir string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	if !repo.rebasing {
+		return fmt.Errorf("no rebase in progress")
+	}
+	repo.rebasing = false
+	return nil
+}
+
+func (c *fakeGitClient) CommitExists(dir, rev string) bool {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return false
+	}
+	_, ok := repo.resolve(rev)
+	return ok
+}
+
+func (c *fakeGitClient) AddWorktree(dir, worktreeDir, rev, branch string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	commit, ok := repo.resolve(rev)
+	if !ok {
+		return fmt.Errorf("revision %s not found", rev)
+	}
+	if _, exists := repo.refs["refs/heads/"+branch]; branch != "" && exists {
+		return fmt.Errorf("branch %s already exists", branch)
+	}
+	if err := c.fileSystem.MkdirAll(worktreeDir, 0755); err != nil {
+		return err
+	}
+
+	worktree := newFakeRepo(worktreeDir, repo.fakeStore)
+	worktree.origin, worktree.originURL, worktree.pushErr = repo.origin, repo.originURL, repo.pushErr
+	worktree.branch = branch
+	if branch != "" {
+		repo.refs["refs/heads/"+branch] = commit.sha
+	}
+	c.repos[worktreeDir] = worktree
+	repo.worktrees = append(repo.worktrees, worktree)
+	return c.checkout(worktree, commit)
+}
+
+func (c *fakeGitClient) RemoveWorktree(dir, worktreeDir string) error {
+	worktree, ok := c.repos[worktreeDir]
+	if !ok || worktree.removed {
+		return fmt.Errorf("%s is not a worktree", worktreeDir)
+	}
+	worktree.removed = true
+	delete(c.repos, worktreeDir)
+	return c.fileSystem.RemoveAll(worktreeDir)
+}
+
+func (c *fakeGitClient) PruneWorktrees(dir string) error {
+	_, err := c.repo(dir)
+	return err
+}
+
+func (c *fakeGitClient) ValidBranchName(dir, name string) bool {
+	return name != "" && !strings.ContainsAny(name, " ~^:?*[\\") && !strings.Contains(name, "..") &&
+		!strings.HasPrefix(name, "-") && !strings.HasSuffix(name, "/") && !strings.HasSuffix(name, ".lock")
+}
+
+func (c *fakeGitClient) DeleteBranch(dir, branch string) error {
+	repo, err := c.repo(dir)
+	if err != nil {
+		return err
+	}
+	if _, ok := repo.refs["refs/heads/"+branch]; !ok {
+		return fmt.Errorf("branch %s not found", branch)
+	}
+	for _, checkedOut := range c.repos {
+		if checkedOut.fakeStore == repo.fakeStore && checkedOut.branch == branch {
+			return fmt.Errorf("branch %s is checked out in %s", branch, checkedOut.root)
+		}
+	}
+	delete(repo.refs, "refs/heads/"+branch)
+	return nil
+}
@@ -4,18 +4,23 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
 // FileFilterService handles file pattern matching and filtering
 type FileFilterService struct {
-	outputService *OutputService
+	outputService    *OutputService
+	fileSystem       FileSystem
+	ignoreRegexCache map[string]*regexp.Regexp
 }
 
-// NewFileFilterService creates a new FileFilterService
-func NewFileFilterService(outputService *OutputService) *FileFilterService {
+// NewFileFilterService creates a new FileFilterService reading files through fileSystem
+func NewFileFilterService(outputService *OutputService, fileSystem FileSystem) *FileFilterService {
 	return &FileFilterService{
-		outputService: outputService,
+		outputService:    outputService,
+		fileSystem:       fileSystem,
+		ignoreRegexCache: make(map[string]*regexp.Regexp),
 	}
 }
 
@@ -118,7 +123,7 @@ func (s *FileFilterService) MatchesPattern(filePath, pattern string) bool {
 
 // FindFilesByPatterns finds all files matching patterns in directory
 func (s *FileFilterService) FindFilesByPatterns(dir string, patterns []string) ([]string, error) {
-	allFiles, err := s.findAllFiles(dir)
+	allFiles, err := findAllFiles(s.fileSystem, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -126,73 +131,6 @@ func (s *FileFilterService) FindFilesByPatterns(dir string, patterns []string) (
 	return s.FilterFilesByPatterns(allFiles, dir, patterns), nil
 }
 
-// findAllFiles finds all files in the specified directory recursively
-func (s *FileFilterService) findAllFiles(dir string) ([]string, error) {
-	var allFiles []string
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			allFiles = append(allFiles, path)
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	return allFiles, nil
-}
-
-// CleanupExtraFilesByPatterns removes files that exist in destination but not in source, considering patterns
-func (s *FileFilterService) CleanupExtraFilesByPatterns(srcFiles []string, srcBase, dstBase string, patterns []string) error {
-	// Build map of source files by relative path (filtered by patterns)
-	srcFilesMap := make(map[string]bool)
-	for _, srcFile := range srcFiles {
-		relativePath, err := filepath.Rel(srcBase, srcFile)
-		if err != nil {
-			continue
-		}
-		srcFilesMap[relativePath] = true
-	}
-
-	// Find all destination files
-	var destFiles []string
-	err := filepath.Walk(dstBase, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			destFiles = append(destFiles, path)
-		}
-		return nil
-	})
-	if err != nil {
-		return fmt.Errorf("error walking destination directory: %w", err)
-	}
-
-	// Filter destination files by patterns
-	destFiles = s.FilterFilesByPatterns(destFiles, dstBase, patterns)
-
-	// Remove files that don't exist in source
-	for _, destFile := range destFiles {
-		relativePath, err := filepath.Rel(dstBase, destFile)
-		if err != nil {
-			continue
-		}
-
-		if !srcFilesMap[relativePath] {
-			if err := os.Remove(destFile); err != nil {
-				s.outputService.PrintErrorf("Error deleting file %s: %v\n", relativePath, err)
-			} else {
-				s.outputService.PrintOperation("delete", relativePath)
-			}
-		}
-	}
-
-	return nil
-}
-
 // GetEffectivePatterns returns effective patterns, empty slice means no filtering
 func (s *FileFilterService) GetEffectivePatterns(patterns []string) []string {
 	if len(patterns) == 0 {
@@ -6,7 +6,7 @@ import (
 
 func TestMatchesPatternGoFiles(t *testing.T) {
 	outputService := NewOutputService()
-	fileFilterService := NewFileFilterService(outputService)
+	fileFilterService := NewFileFilterService(outputService, NewOSFileSystem())
 
 	tests := []struct {
 		pattern     string
@@ -83,7 +83,7 @@ func TestMatchesPatternGoFiles(t *testing.T) {
 
 func TestFilterFilesByPatterns(t *testing.T) {
 	outputService := NewOutputService()
-	fileFilterService := NewFileFilterService(outputService)
+	fileFilterService := NewFileFilterService(outputService, NewOSFileSystem())
 
 	testFiles := []string{
 		"rules/go-concurrency.mdc",
@@ -0,0 +1,472 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileSystem is the storage rules are synced between. Paths are OS paths as used by the os package,
+// reads follow io/fs semantics so missing files yield errors matching fs.ErrNotExist.
+type FileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (fs.FileInfo, error)
+	// WalkDir walks the tree rooted at root in lexical order, as filepath.WalkDir does
+	WalkDir(root string, fn fs.WalkDirFunc) error
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	// Rename moves a file, replacing newname if it exists, as os.Rename does
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	MkdirAll(path string, perm fs.FileMode) error
+	// MkdirTemp creates a new directory in dir, the default temp directory when dir is empty, as os.MkdirTemp does
+	MkdirTemp(dir, pattern string) (string, error)
+	// RemoveAll removes path and everything it contains, a missing path is not an error
+	RemoveAll(path string) error
+}
+
+// OSFileSystem implements FileSystem on the local disk
+type OSFileSystem struct{}
+
+// NewOSFileSystem creates a new OSFileSystem
+func NewOSFileSystem() *OSFileSystem {
+	return &OSFileSystem{}
+}
+
+// ReadFile reads a file from disk
+func (f *OSFileSystem) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// Stat returns file info from disk
+func (f *OSFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// WalkDir walks a directory tree on disk
+func (f *OSFileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return filepath.WalkDir(root, fn)
+}
+
+// WriteFile writes a file to disk
+func (f *OSFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+// Rename moves a file on disk
+func (f *OSFileSystem) Rename(oldname, newname string) error {
+	return os.Rename(oldname, newname)
+}
+
+// Remove removes a file or an empty directory from disk
+func (f *OSFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// MkdirAll creates a directory and its parents on disk
+func (f *OSFileSystem) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+// MkdirTemp creates a new temporary directory on disk
+func (f *OSFileSystem) MkdirTemp(dir, pattern string) (string, error) {
+	return os.MkdirTemp(dir, pattern)
+}
+
+// RemoveAll removes a file or a directory tree from disk
+func (f *OSFileSystem) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+// MemoryFileSystem implements FileSystem in memory, for syncs that must not touch the disk
+type MemoryFileSystem struct {
+	files memoryFS
+}
+
+// NewMemoryFileSystem creates an empty MemoryFileSystem
+func NewMemoryFileSystem() *MemoryFileSystem {
+	return &MemoryFileSystem{files: memoryFS{".": newMemoryFile(".", nil, fs.ModeDir|0755)}}
+}
+
+// ReadFile reads a file from memory
+func (f *MemoryFileSystem) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(f.files, memoryPath(name))
+}
+
+// Stat returns file info from memory
+func (f *MemoryFileSystem) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.files, memoryPath(name))
+}
+
+// WalkDir walks a directory tree in memory
+func (f *MemoryFileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	return fs.WalkDir(f.files, memoryPath(root), func(name string, entry fs.DirEntry, err error) error {
+		return fn(osPath(name), entry, err)
+	})
+}
+
+// WriteFile writes a file to memory, the parent directory must exist as on disk
+func (f *MemoryFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	filePath := memoryPath(name)
+	if parent, ok := f.files[path.Dir(filePath)]; !ok || !parent.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if file, ok := f.files[filePath]; ok && file.IsDir() {
+		return &fs.PathError{Op: "open", Path: name, Err: fmt.Errorf("is a directory")}
+	}
+
+	f.files[filePath] = newMemoryFile(filePath, append([]byte(nil), data...), perm.Perm())
+	return nil
+}
+
+// Rename moves a file in memory, the parent directory of newname must exist as on disk
+func (f *MemoryFileSystem) Rename(oldname, newname string) error {
+	oldPath, newPath := memoryPath(oldname), memoryPath(newname)
+	file, ok := f.files[oldPath]
+	if !ok || file.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	if parent, ok := f.files[path.Dir(newPath)]; !ok || !parent.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrNotExist}
+	}
+	if existing, ok := f.files[newPath]; ok && existing.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fmt.Errorf("is a directory")}
+	}
+
+	f.files[newPath] = newMemoryFile(newPath, file.data, file.mode)
+	delete(f.files, oldPath)
+	return nil
+}
+
+// Remove removes a file or an empty directory from memory
+func (f *MemoryFileSystem) Remove(name string) error {
+	filePath := memoryPath(name)
+	file, ok := f.files[filePath]
+	if !ok || filePath == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if file.IsDir() && len(f.files.children(filePath)) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: fmt.Errorf("directory not empty")}
+	}
+	delete(f.files, filePath)
+	return nil
+}
+
+// MkdirAll creates a directory and its parents in memory
+func (f *MemoryFileSystem) MkdirAll(name string, perm fs.FileMode) error {
+	dirPath := memoryPath(name)
+	for dir := dirPath; dir != "."; dir = path.Dir(dir) {
+		if file, ok := f.files[dir]; ok {
+			if !file.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: osPath(dir), Err: fmt.Errorf("not a directory")}
+			}
+			continue
+		}
+		f.files[dir] = newMemoryFile(dir, nil, fs.ModeDir|perm.Perm())
+	}
+	return nil
+}
+
+// MkdirTemp creates a new temporary directory in memory. The default temp directory is created on demand,
+// since a new MemoryFileSystem is empty, an explicit dir must exist as on disk.
+func (f *MemoryFileSystem) MkdirTemp(dir, pattern string) (string, error) {
+	if dir == "" {
+		dir = os.TempDir()
+		if err := f.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+	}
+	if parent, ok := f.files[memoryPath(dir)]; !ok || !parent.IsDir() {
+		return "", &fs.PathError{Op: "mkdirtemp", Path: dir, Err: fs.ErrNotExist}
+	}
+
+	prefix, suffix := pattern, ""
+	if i := strings.LastIndex(pattern, "*"); i >= 0 {
+		prefix, suffix = pattern[:i], pattern[i+1:]
+	}
+	for n := 1; ; n++ {
+		name := filepath.Join(dir, prefix+strconv.Itoa(n)+suffix)
+		if _, exists := f.files[memoryPath(name)]; !exists {
+			f.files[memoryPath(name)] = newMemoryFile(memoryPath(name), nil, fs.ModeDir|0700)
+			return name, nil
+		}
+	}
+}
+
+// RemoveAll removes a file or a directory tree from memory
+func (f *MemoryFileSystem) RemoveAll(name string) error {
+	removedPath := memoryPath(name)
+	if removedPath == "." {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+	for filePath := range f.files {
+		if filePath == removedPath || strings.HasPrefix(filePath, removedPath+"/") {
+			delete(f.files, filePath)
+		}
+	}
+	return nil
+}
+
+// memoryFS is the io/fs view of a MemoryFileSystem, keyed by slash-separated path.
+// Every directory has its own entry, "." being the filesystem root.
+type memoryFS map[string]*memoryFile
+
+// Open opens a file or directory for reading
+func (m memoryFS) Open(name string) (fs.File, error) {
+	file, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryHandle{file: file, reader: bytes.NewReader(file.data), entries: m.children(name)}, nil
+}
+
+// Stat returns the info of a file or directory
+func (m memoryFS) Stat(name string) (fs.FileInfo, error) {
+	return m.lookup("stat", name)
+}
+
+// ReadFile returns a copy of the content of a file
+func (m memoryFS) ReadFile(name string) ([]byte, error) {
+	file, err := m.lookup("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if file.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fmt.Errorf("is a directory")}
+	}
+	return append([]byte(nil), file.data...), nil
+}
+
+// ReadDir returns the entries of a directory sorted by name
+func (m memoryFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	file, err := m.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !file.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	return m.children(name), nil
+}
+
+// lookup returns the file at name or an fs.PathError for op
+func (m memoryFS) lookup(op, name string) (*memoryFile, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	file, ok := m[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return file, nil
+}
+
+// children returns the entries directly inside dir sorted by name
+func (m memoryFS) children(dir string) []fs.DirEntry {
+	var entries []fs.DirEntry
+	for name, file := range m {
+		if name != "." && path.Dir(name) == dir {
+			entries = append(entries, file)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+// memoryFile is a file or directory of a MemoryFileSystem, it is its own fs.FileInfo and fs.DirEntry
+type memoryFile struct {
+	name    string // Base name
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func newMemoryFile(filePath string, data []byte, mode fs.FileMode) *memoryFile {
+	return &memoryFile{name: path.Base(filePath), data: data, mode: mode, modTime: time.Now()}
+}
+
+func (f *memoryFile) Name() string               { return f.name }
+func (f *memoryFile) Size() int64                { return int64(len(f.data)) }
+func (f *memoryFile) Mode() fs.FileMode          { return f.mode }
+func (f *memoryFile) ModTime() time.Time         { return f.modTime }
+func (f *memoryFile) IsDir() bool                { return f.mode.IsDir() }
+func (f *memoryFile) Sys() any                   { return nil }
+func (f *memoryFile) Type() fs.FileMode          { return f.mode.Type() }
+func (f *memoryFile) Info() (fs.FileInfo, error) { return f, nil }
+
+// memoryHandle is an open memoryFile
+type memoryHandle struct {
+	file    *memoryFile
+	reader  *bytes.Reader
+	entries []fs.DirEntry // Entries not yet returned by ReadDir
+}
+
+func (h *memoryHandle) Stat() (fs.FileInfo, error) { return h.file, nil }
+func (h *memoryHandle) Read(b []byte) (int, error) {
+	if h.file.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: h.file.name, Err: fmt.Errorf("is a directory")}
+	}
+	return h.reader.Read(b)
+}
+func (h *memoryHandle) Close() error { return nil }
+
+// ReadDir returns the next count entries of a directory, all remaining ones when count is not positive
+func (h *memoryHandle) ReadDir(count int) ([]fs.DirEntry, error) {
+	if !h.file.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: h.file.name, Err: fmt.Errorf("not a directory")}
+	}
+	if count <= 0 || count > len(h.entries) {
+		if count > 0 && len(h.entries) == 0 {
+			return nil, io.EOF
+		}
+		count = len(h.entries)
+	}
+	entries := h.entries[:count]
+	h.entries = h.entries[count:]
+	return entries, nil
+}
+
+// memoryPath maps an OS path to the slash-separated path used by io/fs, rooted at the filesystem root
+func memoryPath(name string) string {
+	name = filepath.ToSlash(filepath.Clean(name))
+	name = strings.TrimPrefix(name[len(filepath.VolumeName(name)):], "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// osPath maps an io/fs path of a MemoryFileSystem back to an OS path
+func osPath(name string) string {
+	return filepath.Join(string(filepath.Separator), filepath.FromSlash(name))
+}
+
+// MountedFileSystem serves an fs.FS, such as an embed.FS bundle or a zip archive, read-only at a directory.
+// Paths outside the directory are passed to the base FileSystem.
+type MountedFileSystem struct {
+	base FileSystem
+	dir  string
+	fsys fs.FS
+}
+
+// NewMountedFileSystem creates a FileSystem serving fsys at dir on top of base
+func NewMountedFileSystem(base FileSystem, dir string, fsys fs.FS) *MountedFileSystem {
+	return &MountedFileSystem{base: base, dir: filepath.Clean(dir), fsys: fsys}
+}
+
+// mountedPath returns the io/fs path of name inside the mount, the second return value is false outside it
+func (f *MountedFileSystem) mountedPath(name string) (string, bool) {
+	relativePath, err := filepath.Rel(f.dir, filepath.Clean(name))
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(relativePath), true
+}
+
+// ReadFile reads a file from the mount or the base
+func (f *MountedFileSystem) ReadFile(name string) ([]byte, error) {
+	if mountedPath, ok := f.mountedPath(name); ok {
+		return fs.ReadFile(f.fsys, mountedPath)
+	}
+	return f.base.ReadFile(name)
+}
+
+// Stat returns file info from the mount or the base
+func (f *MountedFileSystem) Stat(name string) (fs.FileInfo, error) {
+	if mountedPath, ok := f.mountedPath(name); ok {
+		return fs.Stat(f.fsys, mountedPath)
+	}
+	return f.base.Stat(name)
+}
+
+// WalkDir walks a directory tree of the mount or the base
+func (f *MountedFileSystem) WalkDir(root string, fn fs.WalkDirFunc) error {
+	mountedPath, ok := f.mountedPath(root)
+	if !ok {
+		return f.base.WalkDir(root, fn)
+	}
+	return fs.WalkDir(f.fsys, mountedPath, func(name string, entry fs.DirEntry, err error) error {
+		return fn(filepath.Join(f.dir, filepath.FromSlash(name)), entry, err)
+	})
+}
+
+// WriteFile writes a file to the base, the mount is read-only
+func (f *MountedFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if _, ok := f.mountedPath(name); ok {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrPermission}
+	}
+	return f.base.WriteFile(name, data, perm)
+}
+
+// Rename moves a file on the base, the mount is read-only
+func (f *MountedFileSystem) Rename(oldname, newname string) error {
+	_, oldMounted := f.mountedPath(oldname)
+	_, newMounted := f.mountedPath(newname)
+	if oldMounted || newMounted {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrPermission}
+	}
+	return f.base.Rename(oldname, newname)
+}
+
+// Remove removes a file from the base, the mount is read-only
+func (f *MountedFileSystem) Remove(name string) error {
+	if _, ok := f.mountedPath(name); ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	return f.base.Remove(name)
+}
+
+// MkdirAll creates a directory on the base, directories inside the mount must already exist
+func (f *MountedFileSystem) MkdirAll(name string, perm fs.FileMode) error {
+	if mountedPath, ok := f.mountedPath(name); ok {
+		info, err := fs.Stat(f.fsys, mountedPath)
+		if err != nil || !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrPermission}
+		}
+		return nil
+	}
+	return f.base.MkdirAll(name, perm)
+}
+
+// MkdirTemp creates a temporary directory on the base, the mount is read-only
+func (f *MountedFileSystem) MkdirTemp(dir, pattern string) (string, error) {
+	if _, ok := f.mountedPath(dir); ok && dir != "" {
+		return "", &fs.PathError{Op: "mkdirtemp", Path: dir, Err: fs.ErrPermission}
+	}
+	return f.base.MkdirTemp(dir, pattern)
+}
+
+// RemoveAll removes a file or a directory tree from the base, the mount and the directories holding it are read-only
+func (f *MountedFileSystem) RemoveAll(name string) error {
+	relativePath, err := filepath.Rel(filepath.Clean(name), f.dir)
+	holdsMount := err == nil && relativePath != ".." && !strings.HasPrefix(relativePath, ".."+string(filepath.Separator))
+	if _, ok := f.mountedPath(name); ok || holdsMount {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrPermission}
+	}
+	return f.base.RemoveAll(name)
+}
+
+// findAllFiles finds all files in the specified directory recursively
+func findAllFiles(fileSystem FileSystem, dir string) ([]string, error) {
+	var allFiles []string
+	err := fileSystem.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			allFiles = append(allFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error finding files in %s: %w", dir, err)
+	}
+	return allFiles, nil
+}
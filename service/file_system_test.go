@@ -0,0 +1,165 @@
+package service
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// writeFileSystemFiles writes files, keyed by path relative to dir, creating parent directories
+func writeFileSystemFiles(t *testing.T, fileSystem FileSystem, dir string, files map[string]string) {
+	t.Helper()
+	for relativePath, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(relativePath))
+		if err := fileSystem.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fileSystem.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFileSystemFiles returns every file below dir keyed by slash-separated relative path
+func readFileSystemFiles(t *testing.T, fileSystem FileSystem, dir string) map[string]string {
+	t.Helper()
+	paths, err := findAllFiles(fileSystem, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string, len(paths))
+	for _, path := range paths {
+		content, err := fileSystem.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[filepath.ToSlash(relativePath)] = string(content)
+	}
+	return files
+}
+
+func TestMemoryFileSystem(t *testing.T) {
+	fileSystem := NewMemoryFileSystem()
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+
+	if err := fileSystem.WriteFile(filepath.Join(rulesDir, "rule.mdc"), []byte("rule"), 0644); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected writing without a parent directory to fail with ErrNotExist, got %v", err)
+	}
+
+	writeFileSystemFiles(t, fileSystem, rulesDir, map[string]string{
+		"b.mdc":        "b",
+		"a/nested.mdc": "nested",
+	})
+	paths, err := findAllFiles(fileSystem, rulesDir)
+	if err != nil {
+		t.Fatalf("Unexpected error walking: %v", err)
+	}
+	expected := []string{filepath.Join(rulesDir, "a", "nested.mdc"), filepath.Join(rulesDir, "b.mdc")}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected files %v in lexical order, got %v", expected, paths)
+	}
+
+	if err := fileSystem.Remove(filepath.Join(rulesDir, "a")); err == nil {
+		t.Errorf("Expected removing a non-empty directory to fail")
+	}
+	if err := fileSystem.Remove(filepath.Join(rulesDir, "a", "nested.mdc")); err != nil {
+		t.Fatalf("Unexpected error removing: %v", err)
+	}
+	if info, err := fileSystem.Stat(filepath.Join(rulesDir, "a")); err != nil || !info.IsDir() {
+		t.Errorf("Expected the emptied directory to remain, got %v", err)
+	}
+	if _, err := fileSystem.ReadFile(filepath.Join(rulesDir, "a", "nested.mdc")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected removed file to be gone, got %v", err)
+	}
+	if err := fileSystem.Rename(filepath.Join(rulesDir, "b.mdc"), filepath.Join(rulesDir, "a", "b.mdc")); err != nil {
+		t.Fatalf("Unexpected error renaming: %v", err)
+	}
+	if files := readFileSystemFiles(t, fileSystem, rulesDir); len(files) != 1 || files["a/b.mdc"] != "b" {
+		t.Errorf("Expected b.mdc to be moved into a, got %v", files)
+	}
+	if err := fileSystem.Rename(filepath.Join(rulesDir, "b.mdc"), filepath.Join(rulesDir, "c.mdc")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected renaming a missing file to fail with ErrNotExist, got %v", err)
+	}
+	if err := fileSystem.MkdirAll(filepath.Join(rulesDir, "a", "b.mdc", "sub"), 0755); err == nil {
+		t.Errorf("Expected creating a directory below a file to fail")
+	}
+
+	// The io/fs view must behave like any other fs.FS
+	if err := fstest.TestFS(fileSystem.files, "rules/a/b.mdc"); err != nil {
+		t.Errorf("Expected the memory filesystem to satisfy io/fs: %v", err)
+	}
+
+	tempDir, err := fileSystem.MkdirTemp("", "sync-*-tmp")
+	if err != nil {
+		t.Fatalf("Unexpected error creating a temp dir: %v", err)
+	}
+	if filepath.Dir(tempDir) != os.TempDir() || !strings.HasPrefix(filepath.Base(tempDir), "sync-") || !strings.HasSuffix(tempDir, "-tmp") {
+		t.Errorf("Expected a temp dir matching the pattern in %s, got %s", os.TempDir(), tempDir)
+	}
+	if otherDir, err := fileSystem.MkdirTemp("", "sync-*-tmp"); err != nil || otherDir == tempDir {
+		t.Errorf("Expected a second temp dir to get a new name, got %s (%v)", otherDir, err)
+	}
+	if _, err := fileSystem.MkdirTemp(filepath.Join(rulesDir, "missing"), "sync-"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a temp dir in a missing directory to fail with ErrNotExist, got %v", err)
+	}
+	if err := fileSystem.RemoveAll(rulesDir); err != nil {
+		t.Fatalf("Unexpected error removing a tree: %v", err)
+	}
+	if _, err := fileSystem.Stat(rulesDir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the removed tree to be gone, got %v", err)
+	}
+	if err := fileSystem.RemoveAll(rulesDir); err != nil {
+		t.Errorf("Expected removing a missing tree to succeed, got %v", err)
+	}
+}
+
+func TestMountedFileSystem(t *testing.T) {
+	base := NewMemoryFileSystem()
+	bundleDir := filepath.Join(string(filepath.Separator), "bundle")
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	writeFileSystemFiles(t, base, projectDir, map[string]string{"local.mdc": "local"})
+
+	bundle := fstest.MapFS{
+		"rule.mdc":       {Data: []byte("rule")},
+		"lang/go.mdc":    {Data: []byte("go")},
+		"lang/notes.txt": {Data: []byte("notes")},
+	}
+	fileSystem := NewMountedFileSystem(base, bundleDir, bundle)
+
+	files := readFileSystemFiles(t, fileSystem, bundleDir)
+	if len(files) != 3 || files["lang/go.mdc"] != "go" {
+		t.Errorf("Expected the bundle to be readable at %s, got %v", bundleDir, files)
+	}
+	if files := readFileSystemFiles(t, fileSystem, projectDir); files["local.mdc"] != "local" {
+		t.Errorf("Expected paths outside the mount to be read from the base, got %v", files)
+	}
+
+	if err := fileSystem.WriteFile(filepath.Join(bundleDir, "rule.mdc"), []byte("changed"), 0644); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected writes to the mount to fail with ErrPermission, got %v", err)
+	}
+	if err := fileSystem.Remove(filepath.Join(bundleDir, "rule.mdc")); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected removals from the mount to fail with ErrPermission, got %v", err)
+	}
+	if err := fileSystem.Rename(filepath.Join(projectDir, "local.mdc"), filepath.Join(bundleDir, "local.mdc")); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected renames into the mount to fail with ErrPermission, got %v", err)
+	}
+	if err := fileSystem.WriteFile(filepath.Join(projectDir, "new.mdc"), []byte("new"), 0644); err != nil {
+		t.Errorf("Expected writes outside the mount to reach the base, got %v", err)
+	}
+	for _, dir := range []string{filepath.Join(bundleDir, "lang"), string(filepath.Separator)} {
+		if err := fileSystem.RemoveAll(dir); !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("Expected removing %s to fail with ErrPermission, got %v", dir, err)
+		}
+	}
+	if err := fileSystem.RemoveAll(projectDir); err != nil {
+		t.Errorf("Expected removals outside the mount to reach the base, got %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Frontmatter keys Cursor defines for .mdc rules
+const (
+	frontmatterDescription = "description"
+	frontmatterGlobs       = "globs"
+	frontmatterAlwaysApply = "alwaysApply"
+)
+
+var (
+	// unquotedAliasRegex matches top-level values and list items starting with *. Cursor writes globs such as
+	// "globs: *.ts" unquoted although * starts a YAML alias, so these values are read as plain strings.
+	unquotedAliasRegex = regexp.MustCompile(`^([^\s#'"-][^:]*:[ \t]+|[ \t]*-[ \t]+)(\*.*?)[ \t]*$`)
+	yamlErrorLineRegex = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+)
+
+// Frontmatter is the parsed YAML header of an .mdc file. It renders back to the exact text it was parsed from,
+// keys changed with Set are rewritten in place and all other lines, comments included, are kept as they are.
+type Frontmatter struct {
+	Header  models.RuleHeader
+	opening string           // Opening separator line
+	closing string           // Closing separator line
+	lines   []string         // Lines between the separators
+	keys    []frontmatterKey // Top-level keys in order
+}
+
+// frontmatterKey is a top-level key and the lines its value spans
+type frontmatterKey struct {
+	name   string
+	start  int  // First line in lines
+	end    int  // Line after the value in lines, trailing blank and comment lines belong to the next key
+	scalar bool // Whether the value is a scalar rather than a list or mapping
+}
+
+// ParseFrontmatter parses the frontmatter of .mdc content with LF line endings and returns it with the body.
+// Content without frontmatter yields a nil Frontmatter. Malformed headers yield FrontmatterErrors,
+// joined when a header has several.
+func ParseFrontmatter(content string) (*Frontmatter, string, error) {
+	header, body, err := splitFrontmatter(content)
+	if err != nil || header == "" {
+		return nil, body, err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(header, "\n"), "\n")
+	frontmatter, err := parseFrontmatterLines(lines[1 : len(lines)-1])
+	if err != nil {
+		return nil, body, err
+	}
+	frontmatter.opening, frontmatter.closing = lines[0], lines[len(lines)-1]
+	return frontmatter, body, nil
+}
+
+// splitFrontmatter splits content with LF line endings into its frontmatter block, both separators and the newline
+// after the closing one included, and the body without its leading empty lines. The header is empty for content
+// without frontmatter. The block ends at the first separator line however long it is, so separators in the body
+// are never mistaken for its end.
+func splitFrontmatter(content string) (string, string, error) {
+	lines := strings.Split(content, "\n")
+	if !isHeaderSeparator(lines[0]) {
+		return "", content, nil
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if !isHeaderSeparator(lines[i]) {
+			continue
+		}
+		body := lines[i+1:]
+		for len(body) > 0 && strings.TrimSpace(body[0]) == "" {
+			body = body[1:]
+		}
+		return strings.Join(lines[:i+1], "\n") + "\n", strings.Join(body, "\n"), nil
+	}
+	return "", content, &FrontmatterError{Line: 1, Message: fmt.Sprintf("unclosed frontmatter, no closing %s line found", headerSeparator)}
+}
+
+// isHeaderSeparator reports whether a line opens or closes frontmatter, trailing blanks are allowed
+func isHeaderSeparator(line string) bool {
+	return strings.TrimRight(line, " \t") == headerSeparator
+}
+
+// parseFrontmatterLines parses the lines between the separators, line numbers in errors are file lines
+func parseFrontmatterLines(lines []string) (*Frontmatter, error) {
+	frontmatter := &Frontmatter{opening: headerSeparator, closing: headerSeparator, lines: lines}
+
+	// Values Cursor leaves unquoted are quoted for parsing only, the lines themselves are kept
+	parseable := make([]string, len(lines))
+	for i, line := range lines {
+		parseable[i] = line
+		if match := unquotedAliasRegex.FindStringSubmatch(line); match != nil {
+			parseable[i] = match[1] + strconv.Quote(match[2])
+		}
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(strings.Join(parseable, "\n")), &document); err != nil {
+		return nil, newYAMLFrontmatterError(err)
+	}
+	if len(document.Content) == 0 {
+		return frontmatter, nil // Empty header
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode || root.Style&yaml.FlowStyle != 0 {
+		return nil, &FrontmatterError{Line: root.Line + 1, Message: "frontmatter must be a block mapping of keys to values"}
+	}
+
+	var errs []error
+	firstLines := make(map[string]int)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keyNode, valueNode := root.Content[i], root.Content[i+1]
+		if firstLine, ok := firstLines[keyNode.Value]; ok {
+			errs = append(errs, &FrontmatterError{Line: keyNode.Line + 1, Message: fmt.Sprintf("duplicate key %q, first defined on line %d", keyNode.Value, firstLine)})
+			continue
+		}
+		firstLines[keyNode.Value] = keyNode.Line + 1
+
+		frontmatter.keys = append(frontmatter.keys, frontmatterKey{name: keyNode.Value, start: keyNode.Line - 1, scalar: valueNode.Kind == yaml.ScalarNode})
+		if err := decodeHeaderField(&frontmatter.Header, keyNode.Value, valueNode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	for i := range frontmatter.keys {
+		end := len(lines)
+		if i+1 < len(frontmatter.keys) {
+			end = frontmatter.keys[i+1].start
+		}
+		for end > frontmatter.keys[i].start+1 && isBlankOrComment(lines[end-1]) {
+			end--
+		}
+		frontmatter.keys[i].end = end
+	}
+	return frontmatter, nil
+}
+
+// decodeHeaderField decodes the value of a top-level key into the header
+func decodeHeaderField(header *models.RuleHeader, key string, value *yaml.Node) error {
+	line := value.Line + 1
+	switch key {
+	case frontmatterDescription:
+		if value.Kind != yaml.ScalarNode || (value.Tag != "!!str" && value.Tag != "!!null") {
+			return &FrontmatterError{Line: line, Key: key, Message: "description must be a string"}
+		}
+		if value.Tag == "!!str" {
+			header.Description = value.Value
+		}
+	case frontmatterGlobs:
+		globs, err := decodeGlobs(value)
+		if err != nil {
+			return err
+		}
+		header.Globs = globs
+	case frontmatterAlwaysApply:
+		if value.Kind != yaml.ScalarNode || (value.Tag != "!!bool" && value.Tag != "!!null") {
+			return &FrontmatterError{Line: line, Key: key, Message: fmt.Sprintf("alwaysApply must be true or false, got %q", value.Value)}
+		}
+		if value.Tag == "!!bool" {
+			if err := value.Decode(&header.AlwaysApply); err != nil {
+				return &FrontmatterError{Line: line, Key: key, Message: err.Error()}
+			}
+		}
+	default:
+		var decoded interface{}
+		if err := value.Decode(&decoded); err != nil {
+			return &FrontmatterError{Line: line, Key: key, Message: fmt.Sprintf("invalid value for %s: %v", key, err)}
+		}
+		if header.Unknown == nil {
+			header.Unknown = make(map[string]interface{})
+		}
+		header.Unknown[key] = decoded
+	}
+	return nil
+}
+
+// decodeGlobs decodes globs written as a comma-separated string, as Cursor does, or as a list of strings
+func decodeGlobs(value *yaml.Node) ([]string, error) {
+	switch {
+	case value.Kind == yaml.ScalarNode && value.Tag == "!!null":
+		return nil, nil
+	case value.Kind == yaml.ScalarNode && value.Tag == "!!str":
+		var globs []string
+		for _, glob := range strings.Split(value.Value, ",") {
+			if glob = strings.TrimSpace(glob); glob != "" {
+				globs = append(globs, glob)
+			}
+		}
+		return globs, nil
+	case value.Kind == yaml.SequenceNode:
+		globs := make([]string, 0, len(value.Content))
+		for _, item := range value.Content {
+			if item.Kind != yaml.ScalarNode || item.Tag != "!!str" {
+				return nil, &FrontmatterError{Line: item.Line + 1, Key: frontmatterGlobs, Message: "globs entries must be strings"}
+			}
+			globs = append(globs, item.Value)
+		}
+		return globs, nil
+	default:
+		return nil, &FrontmatterError{Line: value.Line + 1, Key: frontmatterGlobs, Message: "globs must be a comma-separated string or a list of strings"}
+	}
+}
+
+// newYAMLFrontmatterError converts a yaml syntax error, whose line numbers count from the first header line
+func newYAMLFrontmatterError(err error) error {
+	message := strings.TrimPrefix(err.Error(), "yaml: ")
+	if match := yamlErrorLineRegex.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return &FrontmatterError{Line: line + 1, Message: match[2]}
+	}
+	return &FrontmatterError{Line: 1, Message: message}
+}
+
+// isBlankOrComment reports whether a YAML line carries no value
+func isBlankOrComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+// String renders the frontmatter block with both separators and a trailing newline
+func (f *Frontmatter) String() string {
+	lines := append(append([]string{f.opening}, f.lines...), f.closing)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Set replaces the value of a top-level key in place, or appends the key, and updates Header.
+// Globs are written as a comma-separated string, as Cursor does, unless the key already holds a list.
+func (f *Frontmatter) Set(key string, value interface{}) error {
+	index := f.keyIndex(key)
+	if globs, ok := value.([]string); ok && key == frontmatterGlobs && (index < 0 || f.keys[index].scalar) {
+		// Cursor's unquoted style is kept when it parses back to the same globs
+		if err := f.replaceKey(index, []string{key + ": " + strings.Join(globs, ",")}); err == nil && reflect.DeepEqual(f.Header.Globs, globs) {
+			return nil
+		}
+		index = f.keyIndex(key)
+		value = strings.Join(globs, ",")
+	}
+
+	encoded, err := yaml.Marshal(map[string]interface{}{key: value})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	return f.replaceKey(index, strings.Split(strings.TrimSuffix(string(encoded), "\n"), "\n"))
+}
+
+// takeKey copies the lines of a top-level key from src as they are, or removes the key when src doesn't have it
+func (f *Frontmatter) takeKey(src *Frontmatter, key string) error {
+	index, srcIndex := f.keyIndex(key), src.keyIndex(key)
+	if srcIndex < 0 {
+		if index < 0 {
+			return nil
+		}
+		return f.replaceKey(index, nil)
+	}
+	srcKey := src.keys[srcIndex]
+	return f.replaceKey(index, src.lines[srcKey.start:srcKey.end])
+}
+
+// keyIndex returns the index of a top-level key, -1 when the frontmatter doesn't have it
+func (f *Frontmatter) keyIndex(key string) int {
+	for i, frontmatterKey := range f.keys {
+		if frontmatterKey.name == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// replaceKey replaces the lines of the key at index, or appends them when index is -1, and reparses the result
+func (f *Frontmatter) replaceKey(index int, keyLines []string) error {
+	var lines []string
+	if index < 0 {
+		lines = append(append(lines, f.lines...), keyLines...)
+	} else {
+		lines = append(append(append(lines, f.lines[:f.keys[index].start]...), keyLines...), f.lines[f.keys[index].end:]...)
+	}
+
+	parsed, err := parseFrontmatterLines(lines)
+	if err != nil {
+		return err
+	}
+	parsed.opening, parsed.closing = f.opening, f.closing
+	*f = *parsed
+	return nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestSplitFrontmatter(t *testing.T) {
+	var longHeader strings.Builder
+	longHeader.WriteString("---\nglobs:\n")
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(&longHeader, "  - src/module%d/**/*.go\n", i)
+	}
+	longHeader.WriteString("---\n")
+
+	tests := []struct {
+		content        string
+		expectedHeader string
+		expectedBody   string
+		expectedErr    bool
+		description    string
+	}{
+		{
+			content:        "---\ndescription: test\n---\n\nBody\n",
+			expectedHeader: "---\ndescription: test\n---\n",
+			expectedBody:   "Body\n",
+			description:    "Header should be split from the body without leading empty lines",
+		},
+		{
+			content:        longHeader.String() + "Body\n",
+			expectedHeader: longHeader.String(),
+			expectedBody:   "Body\n",
+			description:    "Headers longer than 20 lines should be found",
+		},
+		{
+			content:        "---\ndescription: test\n---\nIntro\n\n---\n\nMore\n",
+			expectedHeader: "---\ndescription: test\n---\n",
+			expectedBody:   "Intro\n\n---\n\nMore\n",
+			description:    "Horizontal rules in the body should stay in the body",
+		},
+		{
+			content:      "# Title\n\n---\nText\n",
+			expectedBody: "# Title\n\n---\nText\n",
+			description:  "Content not starting with a separator should have no header",
+		},
+		{
+			content:     "---\ndescription: test\nBody\n",
+			expectedErr: true,
+			description: "Unclosed header should be an error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			header, body, err := splitFrontmatter(test.content)
+			if test.expectedErr {
+				var frontmatterErr *FrontmatterError
+				if !errors.As(err, &frontmatterErr) || frontmatterErr.Line != 1 {
+					t.Errorf("Expected a FrontmatterError on line 1, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if header != test.expectedHeader {
+				t.Errorf("Expected header %q, got %q", test.expectedHeader, header)
+			}
+			if body != test.expectedBody {
+				t.Errorf("Expected body %q, got %q", test.expectedBody, body)
+			}
+		})
+	}
+}
+
+func TestParseFrontmatter(t *testing.T) {
+	tests := []struct {
+		content       string
+		expected      models.RuleHeader
+		expectedLines []int
+		description   string
+	}{
+		{
+			content:     "---\ndescription: Go style\nglobs: *.go,*_test.go\nalwaysApply: false\n---\nBody\n",
+			expected:    models.RuleHeader{Description: "Go style", Globs: []string{"*.go", "*_test.go"}},
+			description: "Cursor's unquoted comma-separated globs should be parsed",
+		},
+		{
+			content:     "---\nglobs:\n  - \"src/**/*.ts\"\n  - *.tsx\nalwaysApply: true\nowner: frontend\n---\n",
+			expected:    models.RuleHeader{Globs: []string{"src/**/*.ts", "*.tsx"}, AlwaysApply: true, Unknown: map[string]interface{}{"owner": "frontend"}},
+			description: "Glob lists, booleans and unknown keys should be parsed",
+		},
+		{
+			content:     "---\n---\nBody\n",
+			description: "Empty header should be parsed",
+		},
+		{
+			content:       "---\ndescription: ok\nalwaysApply: yes please\nglobs: 5\n---\n",
+			expectedLines: []int{3, 4},
+			description:   "Type errors should be reported with their file lines",
+		},
+		{
+			content:       "---\ndescription: ok\n  globs: [\n---\n",
+			expectedLines: []int{3},
+			description:   "YAML syntax errors should be reported with their file line",
+		},
+		{
+			content:       "---\ndescription: one\ndescription: two\n---\n",
+			expectedLines: []int{3},
+			description:   "Duplicate keys should be reported",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			frontmatter, _, err := ParseFrontmatter(test.content)
+			if len(test.expectedLines) > 0 {
+				var lines []int
+				for _, joined := range unwrapErrors(err) {
+					var frontmatterErr *FrontmatterError
+					if errors.As(joined, &frontmatterErr) {
+						lines = append(lines, frontmatterErr.Line)
+					}
+				}
+				if !reflect.DeepEqual(lines, test.expectedLines) {
+					t.Errorf("Expected errors on lines %v, got %v", test.expectedLines, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(frontmatter.Header, test.expected) {
+				t.Errorf("Expected header %+v, got %+v", test.expected, frontmatter.Header)
+			}
+		})
+	}
+}
+
+func TestFrontmatterSet(t *testing.T) {
+	content := "---\n# Maintained by the platform team\ndescription:   Old  \nglobs: *.go\n\n# Extra settings\nowner: platform\n---\nBody\n"
+	header, _, err := splitFrontmatter(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key         string
+		value       interface{}
+		expected    string
+		description string
+	}{
+		{
+			key:         frontmatterDescription,
+			value:       "New description",
+			expected:    "---\n# Maintained by the platform team\ndescription: New description\nglobs: *.go\n\n# Extra settings\nowner: platform\n---\n",
+			description: "Changing a key should keep every other line",
+		},
+		{
+			key:         frontmatterGlobs,
+			value:       []string{"*.go", "cmd/**/*.go"},
+			expected:    "---\n# Maintained by the platform team\ndescription:   Old  \nglobs: *.go,cmd/**/*.go\n\n# Extra settings\nowner: platform\n---\n",
+			description: "Globs should keep Cursor's unquoted style",
+		},
+		{
+			key:         frontmatterAlwaysApply,
+			value:       true,
+			expected:    "---\n# Maintained by the platform team\ndescription:   Old  \nglobs: *.go\n\n# Extra settings\nowner: platform\nalwaysApply: true\n---\n",
+			description: "New keys should be appended",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			frontmatter, _, err := ParseFrontmatter(content)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if frontmatter.String() != header {
+				t.Fatalf("Expected the unchanged header to render as %q, got %q", header, frontmatter.String())
+			}
+
+			if err := frontmatter.Set(test.key, test.value); err != nil {
+				t.Fatalf("Unexpected error setting %s: %v", test.key, err)
+			}
+			if frontmatter.String() != test.expected {
+				t.Errorf("Expected header %q, got %q", test.expected, frontmatter.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,425 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+// GitClient runs the git operations of a sync. dir may be any directory inside the repository,
+// paths passed to Add and Commit are relative to dir.
+type GitClient interface {
+	// Root returns the top-level directory of the repository containing dir
+	Root(dir string) (string, error)
+	// Prefix returns the path of dir relative to the repository root, with a trailing slash unless empty
+	Prefix(dir string) (string, error)
+	// Status returns the paths with uncommitted changes, staged, unstaged or untracked, relative to the repository root
+	Status(dir string) ([]string, error)
+	// Add stages the given paths including deletions, paths that are neither on disk nor tracked are skipped
+	Add(dir string, paths []string) error
+	// Unstage removes the given paths from the index, leaving the working tree as it is
+	Unstage(dir string, paths []string) error
+	// Commit commits the staged changes of the given paths only and reports whether a commit was made
+	Commit(dir, message string, paths []string, options models.CommitOptions) (bool, error)
+	// Push pushes the current branch to origin, setting the upstream when it has none
+	Push(dir string) error
+	// Fetch fetches origin including tags, pruning deleted branches
+	Fetch(dir string) error
+	// HeadSHA returns the commit SHA of HEAD
+	HeadSHA(dir string) (string, error)
+	// RemoteURL returns the URL of origin, empty when the repository has no origin
+	RemoteURL(dir string) (string, error)
+	// Clone clones url into dir, which must not exist yet
+	Clone(url, dir string) error
+	// DiscardChanges resets tracked files to HEAD and removes untracked files
+	DiscardChanges(dir string) error
+	// DefaultBranch returns the branch origin/HEAD points to, without the origin/ prefix
+	DefaultBranch(dir string) (string, error)
+	// RefExists reports whether a fully qualified ref such as refs/heads/main exists
+	RefExists(dir, ref string) bool
+	// Checkout checks out an existing local branch
+	Checkout(dir, branch string) error
+	// CheckoutDetached detaches HEAD at a tag or commit
+	CheckoutDetached(dir, rev string) error
+	// CheckoutTracking creates a local branch tracking the branch of the same name on origin and checks it out
+	CheckoutTracking(dir, branch string) error
+	// Upstream returns the upstream of the current branch, such as origin/main, empty when it has none
+	Upstream(dir string) (string, error)
+	// AheadBehind returns the number of commits only HEAD has and the number only rev has
+	AheadBehind(dir, rev string) (int, int, error)
+	// MergeFastForward fast-forwards the current branch to rev, failing when it has diverged
+	MergeFastForward(dir, rev string) error
+	// Rebase rebases the current branch onto rev, a failed rebase is left in progress
+	Rebase(dir, rev string) error
+	// AbortRebase aborts the rebase in progress, restoring the branch as it was before
+	AbortRebase(dir string) error
+	// CommitExists reports whether rev names a commit of the repository
+	CommitExists(dir, rev string) bool
+	// AddWorktree adds a worktree at worktreeDir checked out at rev, detached unless a new branch is given
+	AddWorktree(dir, worktreeDir, rev, branch string) error
+	// RemoveWorktree removes a worktree, discarding its changes
+	RemoveWorktree(dir, worktreeDir string) error
+	// PruneWorktrees removes what is left of worktrees whose directory no longer exists
+	PruneWorktrees(dir string) error
+	// ValidBranchName reports whether name can be used as a branch name
+	ValidBranchName(dir, name string) bool
+	// DeleteBranch deletes a local branch even when it is not merged
+	DeleteBranch(dir, branch string) error
+}
+
+// ExecGitClient implements GitClient by running the git binary
+type ExecGitClient struct{}
+
+// NewExecGitClient creates a new ExecGitClient
+func NewExecGitClient() *ExecGitClient {
+	return &ExecGitClient{}
+}
+
+// Root returns the top-level directory of the repository containing dir
+func (c *ExecGitClient) Root(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error finding git repository root: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Prefix returns the path of dir relative to the repository root
+func (c *ExecGitClient) Prefix(dir string) (string, error) {
+	return c.run(dir, "rev-parse", "--show-prefix")
+}
+
+// Status returns the paths listed by git status --porcelain, renames contribute both paths
+func (c *ExecGitClient) Status(dir string) ([]string, error) {
+	// Porcelain output is not trimmed, its leading status columns are significant
+	cmd := exec.Command("git", "status", "--porcelain", "-z", "--untracked-files=all")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check git status in %s: %w", dir, err)
+	}
+	return parsePorcelainPaths(string(output)), nil
+}
+
+// Add stages the given paths
+func (c *ExecGitClient) Add(dir string, paths []string) error {
+	paths, err := c.knownPaths(dir, paths)
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+
+	// git add with a pathspec stages deletions of the given paths too
+	_, err = c.run(dir, append([]string{"add", "--"}, paths...)...)
+	return err
+}
+
+// Unstage resets the index entries of the given paths to HEAD
+func (c *ExecGitClient) Unstage(dir string, paths []string) error {
+	paths, err := c.knownPaths(dir, paths)
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+	_, err = c.run(dir, append([]string{"reset", "-q", "--"}, paths...)...)
+	return err
+}
+
+// Commit commits the given paths, leaving changes staged for other paths out of the commit
+func (c *ExecGitClient) Commit(dir, message string, paths []string, options models.CommitOptions) (bool, error) {
+	if len(paths) == 0 {
+		return false, nil
+	}
+
+	// Only paths with staged changes are committed, synced files may already match HEAD,
+	// for example after reverting a local edit, and git rejects pathspecs that match nothing
+	diffCmd := exec.Command("git", append([]string{"diff", "--cached", "--name-only", "--relative", "-z", "--"}, paths...)...)
+	diffCmd.Dir = dir
+	output, err := diffCmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to list staged changes in %s: %w", dir, err)
+	}
+	var staged []string
+	for _, stagedPath := range strings.Split(string(output), "\x00") {
+		if stagedPath != "" {
+			staged = append(staged, stagedPath)
+		}
+	}
+	if len(staged) == 0 {
+		return false, nil
+	}
+
+	args, env, err := commitArgs(message, options)
+	if err != nil {
+		return false, err
+	}
+	args = append(append(args, "--"), staged...)
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	output, err = cmd.CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(output), "nothing to commit") || strings.Contains(string(output), "no changes added to commit") {
+			return false, nil // Not an error, just nothing to do for commit
+		}
+		return false, fmt.Errorf("error running 'git commit' in %s: %s\n%v\n", dir, string(output), err)
+	}
+	return true, nil
+}
+
+// Push pushes the current branch, a branch without upstream such as one created by push --branch is pushed with tracking
+func (c *ExecGitClient) Push(dir string) error {
+	args := []string{"push"}
+	if upstream, _ := c.Upstream(dir); upstream == "" {
+		args = []string{"push", "--set-upstream", "origin", "HEAD"}
+	}
+	_, err := c.run(dir, args...)
+	return err
+}
+
+// Fetch fetches origin
+func (c *ExecGitClient) Fetch(dir string) error {
+	_, err := c.run(dir, "fetch", "-q", "--prune", "--tags", "origin")
+	return err
+}
+
+// HeadSHA returns the commit SHA of HEAD in the specified repository
+func (c *ExecGitClient) HeadSHA(dir string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error reading HEAD commit: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// RemoteURL returns the URL of origin, a failing git remote means there is no origin
+func (c *ExecGitClient) RemoteURL(dir string) (string, error) {
+	cmd := exec.Command("git", "remote", "get-url", "origin")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil
+		}
+		return "", fmt.Errorf("error checking git remote origin: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Clone clones url into dir
+func (c *ExecGitClient) Clone(url, dir string) error {
+	_, err := c.run(filepath.Dir(dir), "clone", "-q", url, dir)
+	return err
+}
+
+// DiscardChanges resets the working tree and removes untracked files and directories
+func (c *ExecGitClient) DiscardChanges(dir string) error {
+	if _, err := c.run(dir, "reset", "-q", "--hard"); err != nil {
+		return err
+	}
+	_, err := c.run(dir, "clean", "-q", "-fd")
+	return err
+}
+
+// DefaultBranch returns the branch origin/HEAD points to
+func (c *ExecGitClient) DefaultBranch(dir string) (string, error) {
+	head, err := c.run(dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(head, "origin/"), nil
+}
+
+// RefExists reports whether a fully qualified ref exists
+func (c *ExecGitClient) RefExists(dir, ref string) bool {
+	_, err := c.run(dir, "show-ref", "--verify", "-q", ref)
+	return err == nil
+}
+
+// Checkout checks out an existing local branch
+func (c *ExecGitClient) Checkout(dir, branch string) error {
+	_, err := c.run(dir, "checkout", "-q", branch)
+	return err
+}
+
+// CheckoutDetached detaches HEAD at a tag or commit
+func (c *ExecGitClient) CheckoutDetached(dir, rev string) error {
+	_, err := c.run(dir, "checkout", "-q", "--detach", rev)
+	return err
+}
+
+// CheckoutTracking creates and checks out a local branch tracking origin
+func (c *ExecGitClient) CheckoutTracking(dir, branch string) error {
+	_, err := c.run(dir, "checkout", "-q", "-b", branch, "--track", "origin/"+branch)
+	return err
+}
+
+// Upstream returns the upstream of the current branch, a failing git rev-parse means there is none
+func (c *ExecGitClient) Upstream(dir string) (string, error) {
+	upstream, err := c.run(dir, "rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	if err != nil {
+		return "", nil
+	}
+	return upstream, nil
+}
+
+// AheadBehind counts the commits on either side of HEAD...rev
+func (c *ExecGitClient) AheadBehind(dir, rev string) (int, int, error) {
+	counts, err := c.run(dir, "rev-list", "--left-right", "--count", "HEAD..."+rev)
+	if err != nil {
+		return 0, 0, err
+	}
+	var ahead, behind int
+	if _, err := fmt.Sscanf(counts, "%d %d", &ahead, &behind); err != nil {
+		return 0, 0, fmt.Errorf("failed to compare HEAD with %s in %s: %w", rev, dir, err)
+	}
+	return ahead, behind, nil
+}
+
+// MergeFastForward fast-forwards the current branch to rev
+func (c *ExecGitClient) MergeFastForward(dir, rev string) error {
+	_, err := c.run(dir, "merge", "-q", "--ff-only", rev)
+	return err
+}
+
+// Rebase rebases the current branch onto rev
+func (c *ExecGitClient) Rebase(dir, rev string) error {
+	_, err := c.run(dir, "rebase", "-q", rev)
+	return err
+}
+
+// AbortRebase aborts the rebase in progress
+func (c *ExecGitClient) AbortRebase(dir string) error {
+	_, err := c.run(dir, "rebase", "--abort")
+	return err
+}
+
+// CommitExists reports whether rev names a commit
+func (c *ExecGitClient) CommitExists(dir, rev string) bool {
+	_, err := c.run(dir, "cat-file", "-e", rev+"^{commit}")
+	return err == nil
+}
+
+// AddWorktree adds a detached worktree, or one on a new branch
+func (c *ExecGitClient) AddWorktree(dir, worktreeDir, rev, branch string) error {
+	args := []string{"worktree", "add", "--detach", worktreeDir, rev}
+	if branch != "" {
+		args = []string{"worktree", "add", "-b", branch, worktreeDir, rev}
+	}
+	_, err := c.run(dir, args...)
+	return err
+}
+
+// RemoveWorktree removes a worktree including its changes
+func (c *ExecGitClient) RemoveWorktree(dir, worktreeDir string) error {
+	_, err := c.run(dir, "worktree", "remove", "--force", worktreeDir)
+	return err
+}
+
+// PruneWorktrees prunes worktrees whose directory no longer exists
+func (c *ExecGitClient) PruneWorktrees(dir string) error {
+	_, err := c.run(dir, "worktree", "prune")
+	return err
+}
+
+// ValidBranchName checks name with git check-ref-format
+func (c *ExecGitClient) ValidBranchName(dir, name string) bool {
+	_, err := c.run(dir, "check-ref-format", "--branch", name)
+	return err == nil
+}
+
+// DeleteBranch force-deletes a local branch
+func (c *ExecGitClient) DeleteBranch(dir, branch string) error {
+	_, err := c.run(dir, "branch", "-D", branch)
+	return err
+}
+
+// run runs a git command in dir and returns its trimmed output
+func (c *ExecGitClient) run(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("error running 'git %s' in %s: %s\n%v", strings.Join(args, " "), dir, strings.TrimSpace(string(output)), err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// knownPaths drops paths that are neither on disk nor tracked, such as deleted untracked files,
+// since git rejects pathspecs that match nothing
+func (c *ExecGitClient) knownPaths(dir string, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	cmd := exec.Command("git", append([]string{"ls-files", "-z", "--"}, paths...)...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tracked files in %s: %w", dir, err)
+	}
+	tracked := make(map[string]bool)
+	for _, trackedPath := range strings.Split(string(output), "\x00") {
+		tracked[trackedPath] = true
+	}
+
+	var known []string
+	for _, relativePath := range paths {
+		if _, statErr := os.Lstat(filepath.Join(dir, relativePath)); statErr == nil || tracked[relativePath] {
+			known = append(known, relativePath)
+		}
+	}
+	return known, nil
+}
+
+// commitArgs returns the git commit arguments and extra environment for the commit options
+func commitArgs(message string, options models.CommitOptions) ([]string, []string, error) {
+	args := []string{"commit", "-m", message}
+	var env []string
+
+	if options.Author != "" {
+		args = append(args, "--author", options.Author)
+	}
+	if options.Committer != "" {
+		name, email, err := parseIdentity(options.Committer)
+		if err != nil {
+			return nil, nil, err
+		}
+		env = append(env, "GIT_COMMITTER_NAME="+name, "GIT_COMMITTER_EMAIL="+email)
+	}
+	if options.Signoff {
+		args = append(args, "--signoff")
+	}
+	if options.GPGKey != "" {
+		args = append(args, "--gpg-sign="+options.GPGKey)
+	} else if options.GPGSign {
+		args = append(args, "--gpg-sign")
+	}
+	return args, env, nil
+}
+
+// parsePorcelainPaths returns the paths listed by git status --porcelain -z, relative to the repository root.
+// Renamed and copied entries contribute both their new and their original path.
+func parsePorcelainPaths(status string) []string {
+	var paths []string
+	entries := strings.Split(status, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		paths = append(paths, entry[3:])
+		if (entry[0] == 'R' || entry[0] == 'C') && i+1 < len(entries) {
+			i++
+			paths = append(paths, entries[i])
+		}
+	}
+	return paths
+}
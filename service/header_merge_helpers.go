@@ -0,0 +1,202 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+// defaultHeaderFieldPolicies apply to keys the merge policy doesn't cover: descriptions flow from the source,
+// everything else the destination customised is kept
+var defaultHeaderFieldPolicies = map[string]models.HeaderFieldPolicy{
+	frontmatterDescription: models.HeaderTakeSource,
+}
+
+// validateHeaderOptions reports invalid header settings before any file is touched
+func (s *SyncService) validateHeaderOptions(options *models.SyncOptions) error {
+	if !options.MergeHeaders {
+		return nil
+	}
+	if options.OverwriteHeaders {
+		return fmt.Errorf("headers can either be overwritten or merged, not both")
+	}
+
+	policy := options.HeaderPolicy
+	if err := validateHeaderFieldPolicies(policy.Default, policy.Fields); err != nil {
+		return err
+	}
+	for _, file := range policy.Files {
+		if file.Pattern == "" {
+			return fmt.Errorf("header policy for files is missing a pattern")
+		}
+		if err := s.fileFilterService.ValidatePatterns([]string{file.Pattern}); err != nil {
+			return fmt.Errorf("invalid header policy for files: %w", err)
+		}
+		if err := validateHeaderFieldPolicies(file.Default, file.Fields); err != nil {
+			return fmt.Errorf("invalid header policy for %s: %w", file.Pattern, err)
+		}
+	}
+	return nil
+}
+
+// validateHeaderFieldPolicies checks policy names, union is only defined for globs
+func validateHeaderFieldPolicies(defaultPolicy models.HeaderFieldPolicy, fields map[string]models.HeaderFieldPolicy) error {
+	if defaultPolicy != "" && defaultPolicy != models.HeaderKeepLocal && defaultPolicy != models.HeaderTakeSource {
+		return fmt.Errorf("invalid default header policy %q: use %s or %s", defaultPolicy, models.HeaderKeepLocal, models.HeaderTakeSource)
+	}
+	for key, policy := range fields {
+		switch policy {
+		case models.HeaderKeepLocal, models.HeaderTakeSource:
+		case models.HeaderUnion:
+			if key != frontmatterGlobs {
+				return fmt.Errorf("header policy %s only applies to %s, not %s", policy, frontmatterGlobs, key)
+			}
+		default:
+			return fmt.Errorf("invalid header policy %q for %s: use %s, %s or %s", policy, key, models.HeaderKeepLocal, models.HeaderTakeSource, models.HeaderUnion)
+		}
+	}
+	return nil
+}
+
+// ParseHeaderPolicy parses comma-separated key=policy pairs, such as "description=take-source,globs=union"
+func (s *SyncService) ParseHeaderPolicy(value string) (map[string]models.HeaderFieldPolicy, error) {
+	fields := make(map[string]models.HeaderFieldPolicy)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, policy, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid header policy %q: use key=policy", pair)
+		}
+		fields[strings.TrimSpace(key)] = models.HeaderFieldPolicy(strings.TrimSpace(policy))
+	}
+	return fields, validateHeaderFieldPolicies("", fields)
+}
+
+// fieldPolicy returns the policy of a frontmatter key for a file. Policies for matching files override
+// the global ones, an explicit default overrides the built-in defaults.
+func (s *SyncService) fieldPolicy(policy models.HeaderMergePolicy, relativePath, key string) models.HeaderFieldPolicy {
+	result := models.HeaderKeepLocal
+	if defaultPolicy, ok := defaultHeaderFieldPolicies[key]; ok {
+		result = defaultPolicy
+	}
+	if policy.Default != "" {
+		result = policy.Default
+	}
+	if fieldPolicy, ok := policy.Fields[key]; ok {
+		result = fieldPolicy
+	}
+
+	for _, file := range policy.Files {
+		if !s.fileFilterService.MatchesPattern(relativePath, file.Pattern) {
+			continue
+		}
+		if file.Default != "" {
+			result = file.Default
+		}
+		if fieldPolicy, ok := file.Fields[key]; ok {
+			result = fieldPolicy
+		}
+	}
+	return result
+}
+
+// destinationHeader returns the header syncing a file writes below the source body. Without a destination header
+// or when headers are overwritten it is the source header, when headers are merged it is the merged header,
+// otherwise the destination header is kept. Rule overrides of the file are set on top.
+func (s *SyncService) destinationHeader(srcHeader, dstHeader, relativePath string, options models.SyncOptions) (string, error) {
+	header := dstHeader
+	switch {
+	case dstHeader == "" || options.OverwriteHeaders:
+		header = srcHeader
+	case options.MergeHeaders:
+		merged, err := s.mergeHeaders(srcHeader, dstHeader, relativePath, options.HeaderPolicy)
+		if err != nil {
+			return "", err
+		}
+		header = merged
+	}
+
+	if override, ok := options.RuleOverrides[relativePath]; ok {
+		overridden, err := applyRuleOverride(header, override)
+		if err != nil {
+			return "", fmt.Errorf("failed to apply rule override: %w", err)
+		}
+		return overridden, nil
+	}
+	return header, nil
+}
+
+// mergeHeaders merges the source header into the destination header key by key.
+// The destination header keeps its formatting, keys taken from the source keep theirs.
+// Without a source header the destination header is kept.
+func (s *SyncService) mergeHeaders(srcHeader, dstHeader, relativePath string, policy models.HeaderMergePolicy) (string, error) {
+	if srcHeader == "" {
+		return dstHeader, nil
+	}
+	src, _, err := ParseFrontmatter(srcHeader)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse source header: %w", err)
+	}
+	dst, _, err := ParseFrontmatter(dstHeader)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse destination header: %w", err)
+	}
+
+	for _, key := range headerKeys(dst, src) {
+		switch s.fieldPolicy(policy, relativePath, key) {
+		case models.HeaderTakeSource:
+			err = dst.takeKey(src, key)
+		case models.HeaderUnion:
+			switch {
+			case src.keyIndex(key) < 0:
+			case dst.keyIndex(key) < 0:
+				err = dst.takeKey(src, key)
+			default:
+				if globs := unionGlobs(dst.Header.Globs, src.Header.Globs); !reflect.DeepEqual(globs, dst.Header.Globs) {
+					err = dst.Set(key, globs)
+				}
+			}
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to merge %s: %w", key, err)
+		}
+	}
+	return dst.String(), nil
+}
+
+// headerKeys returns the keys of both headers, destination keys first and in their order
+func headerKeys(dst, src *Frontmatter) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, frontmatter := range []*Frontmatter{dst, src} {
+		for _, key := range frontmatter.keys {
+			if !seen[key.name] {
+				seen[key.name] = true
+				keys = append(keys, key.name)
+			}
+		}
+	}
+	return keys
+}
+
+// unionGlobs returns the destination globs followed by the source globs they lack
+func unionGlobs(dstGlobs, srcGlobs []string) []string {
+	globs := append([]string{}, dstGlobs...)
+	for _, glob := range srcGlobs {
+		found := false
+		for _, existing := range globs {
+			if existing == glob {
+				found = true
+				break
+			}
+		}
+		if !found {
+			globs = append(globs, glob)
+		}
+	}
+	return globs
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestMergeHeaders(t *testing.T) {
+	syncService := NewSyncService(NewOutputService(), NewExecGitClient(), NewMemoryFileSystem())
+
+	src := "---\ndescription: Central description\nglobs: *.go,cmd/**/*.go\nalwaysApply: true\nowner: platform\n---\n"
+	dst := "---\n# Tuned for this project\ndescription: Local description\nglobs: *.go,internal/**/*.go\nalwaysApply: false\n---\n"
+
+	tests := []struct {
+		relativePath string
+		policy       models.HeaderMergePolicy
+		expected     string
+		description  string
+	}{
+		{
+			relativePath: "go.mdc",
+			expected:     "---\n# Tuned for this project\ndescription: Central description\nglobs: *.go,internal/**/*.go\nalwaysApply: false\n---\n",
+			description:  "Descriptions should be taken from the source and other keys kept by default",
+		},
+		{
+			relativePath: "go.mdc",
+			policy:       models.HeaderMergePolicy{Fields: map[string]models.HeaderFieldPolicy{frontmatterGlobs: models.HeaderUnion, frontmatterDescription: models.HeaderKeepLocal}},
+			expected:     "---\n# Tuned for this project\ndescription: Local description\nglobs: *.go,internal/**/*.go,cmd/**/*.go\nalwaysApply: false\n---\n",
+			description:  "Union should append the source globs the destination lacks",
+		},
+		{
+			relativePath: "go.mdc",
+			policy:       models.HeaderMergePolicy{Default: models.HeaderTakeSource},
+			expected:     "---\n# Tuned for this project\ndescription: Central description\nglobs: *.go,cmd/**/*.go\nalwaysApply: true\nowner: platform\n---\n",
+			description:  "Take-source as default should take every key including new ones",
+		},
+		{
+			relativePath: "team/go.mdc",
+			policy: models.HeaderMergePolicy{
+				Fields: map[string]models.HeaderFieldPolicy{frontmatterAlwaysApply: models.HeaderTakeSource},
+				Files: []models.HeaderFilePolicy{
+					{Pattern: "team/*.mdc", Fields: map[string]models.HeaderFieldPolicy{frontmatterAlwaysApply: models.HeaderKeepLocal, frontmatterGlobs: models.HeaderTakeSource}},
+				},
+			},
+			expected:    "---\n# Tuned for this project\ndescription: Central description\nglobs: *.go,cmd/**/*.go\nalwaysApply: false\n---\n",
+			description: "Policies for matching files should override the global ones",
+		},
+		{
+			relativePath: "go.mdc",
+			policy: models.HeaderMergePolicy{
+				Fields: map[string]models.HeaderFieldPolicy{frontmatterAlwaysApply: models.HeaderTakeSource},
+				Files:  []models.HeaderFilePolicy{{Pattern: "team/*.mdc", Default: models.HeaderKeepLocal}},
+			},
+			expected:    "---\n# Tuned for this project\ndescription: Central description\nglobs: *.go,internal/**/*.go\nalwaysApply: true\n---\n",
+			description: "Policies for other files should not apply",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			merged, err := syncService.mergeHeaders(src, dst, test.relativePath, test.policy)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if merged != test.expected {
+				t.Errorf("Expected header %q, got %q", test.expected, merged)
+			}
+		})
+	}
+}
+
+func TestValidateHeaderOptions(t *testing.T) {
+	syncService := NewSyncService(NewOutputService(), NewExecGitClient(), NewMemoryFileSystem())
+
+	tests := []struct {
+		options     models.SyncOptions
+		expectedErr bool
+		description string
+	}{
+		{
+			options:     models.SyncOptions{MergeHeaders: true, HeaderPolicy: models.HeaderMergePolicy{Fields: map[string]models.HeaderFieldPolicy{frontmatterGlobs: models.HeaderUnion}}},
+			description: "Union of globs should be valid",
+		},
+		{
+			options:     models.SyncOptions{MergeHeaders: true, OverwriteHeaders: true},
+			expectedErr: true,
+			description: "Merging and overwriting headers together should be rejected",
+		},
+		{
+			options:     models.SyncOptions{MergeHeaders: true, HeaderPolicy: models.HeaderMergePolicy{Fields: map[string]models.HeaderFieldPolicy{frontmatterDescription: models.HeaderUnion}}},
+			expectedErr: true,
+			description: "Union of other keys should be rejected",
+		},
+		{
+			options:     models.SyncOptions{MergeHeaders: true, HeaderPolicy: models.HeaderMergePolicy{Default: models.HeaderUnion}},
+			expectedErr: true,
+			description: "Union as default should be rejected",
+		},
+		{
+			options:     models.SyncOptions{MergeHeaders: true, HeaderPolicy: models.HeaderMergePolicy{Files: []models.HeaderFilePolicy{{Pattern: "team/[.mdc"}}}},
+			expectedErr: true,
+			description: "Invalid file patterns should be rejected",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := syncService.validateHeaderOptions(&test.options)
+			if test.expectedErr && err == nil {
+				t.Errorf("Expected an error")
+			}
+			if !test.expectedErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSyncWithMergedHeaders(t *testing.T) {
+	fileSystem := NewMemoryFileSystem()
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+	writeFileSystemFiles(t, fileSystem, rulesDir, map[string]string{
+		"go.mdc":   "---\ndescription: Go style\nglobs: *.go\n---\nUse gofmt.\n",
+		"same.mdc": "---\ndescription: Same\nglobs: *.md\n---\nBody\n",
+	})
+	writeFileSystemFiles(t, fileSystem, projectDir, map[string]string{
+		"go.mdc":   "---\ndescription: Old\nglobs: internal/**/*.go\n---\nUse gofmt.\n",
+		"same.mdc": "---\ndescription: Same\nglobs: docs/*.md\n---\nBody\n",
+	})
+
+	var stdout bytes.Buffer
+	syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), NewExecGitClient(), fileSystem)
+	options := models.SyncOptions{MergeHeaders: true, HeaderPolicy: models.HeaderMergePolicy{Fields: map[string]models.HeaderFieldPolicy{frontmatterGlobs: models.HeaderUnion}}}
+
+	for _, file := range []string{"go.mdc", "same.mdc"} {
+		equal, err := syncService.filesAreEqualBasedOnExtension(filepath.Join(rulesDir, file), filepath.Join(projectDir, file), file, models.SyncOptions{MergeHeaders: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if expected := file == "same.mdc"; equal != expected {
+			t.Errorf("Expected %s to be equal %v with the default policy, got %v", file, expected, equal)
+		}
+	}
+
+	plan := &models.SyncPlan{
+		Direction: models.DirectionPull,
+		SourceDir: rulesDir,
+		TargetDir: projectDir,
+		Options:   options,
+	}
+	for _, file := range []string{"go.mdc", "same.mdc"} {
+		plan.Operations = append(plan.Operations, models.FileOperation{
+			Type:         models.OperationUpdate,
+			SourcePath:   filepath.Join(rulesDir, file),
+			TargetPath:   filepath.Join(projectDir, file),
+			RelativePath: file,
+		})
+	}
+	if _, err := syncService.Apply(plan); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	assertFiles(t, "project", map[string]string{
+		"go.mdc":   "---\ndescription: Go style\nglobs: internal/**/*.go,*.go\n---\nUse gofmt.\n",
+		"same.mdc": "---\ndescription: Same\nglobs: docs/*.md,*.md\n---\nBody\n",
+	}, readFileSystemFiles(t, fileSystem, projectDir))
+}
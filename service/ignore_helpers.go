@@ -0,0 +1,250 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+// LoadIgnorePatterns reads .ruleignore files from the given directories and compiles them.
+// Patterns are returned in the order of the directories, so rules from later directories win.
+func (s *FileFilterService) LoadIgnorePatterns(dirs ...string) ([]models.IgnorePattern, error) {
+	var patterns []models.IgnorePattern
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+
+		filePatterns, err := s.loadIgnoreFile(filepath.Join(dir, ruleignoreFileName))
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, filePatterns...)
+	}
+	return patterns, nil
+}
+
+// loadIgnoreFile reads and compiles a single .ruleignore file, a missing file yields no patterns
+func (s *FileFilterService) loadIgnoreFile(path string) ([]models.IgnorePattern, error) {
+	content, err := s.fileSystem.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	patterns, err := s.CompileIgnorePatterns(lines)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern in %s: %w", path, err)
+	}
+	return patterns, nil
+}
+
+// CompileIgnorePatterns compiles gitignore-style lines, skipping comments and empty lines
+func (s *FileFilterService) CompileIgnorePatterns(lines []string) ([]models.IgnorePattern, error) {
+	var patterns []models.IgnorePattern
+	for _, line := range lines {
+		pattern, ok, err := s.CompileIgnorePattern(line)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns, nil
+}
+
+// CompileIgnorePattern compiles a single gitignore-style line.
+// The second return value is false for comments and empty lines.
+func (s *FileFilterService) CompileIgnorePattern(line string) (models.IgnorePattern, bool, error) {
+	pattern := strings.TrimRight(strings.TrimSuffix(line, "\r"), " \t")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return models.IgnorePattern{}, false, nil
+	}
+
+	result := models.IgnorePattern{Pattern: pattern}
+
+	// Leading "!" negates the pattern, "\!" and "\#" escape literal characters
+	switch {
+	case strings.HasPrefix(pattern, "!"):
+		result.IsNegation = true
+		pattern = pattern[1:]
+	case strings.HasPrefix(pattern, `\!`), strings.HasPrefix(pattern, `\#`):
+		pattern = pattern[1:]
+	}
+
+	// Trailing "/" matches only directories, i.e. everything below them
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimRight(pattern, "/")
+	if pattern == "" {
+		return models.IgnorePattern{}, false, nil
+	}
+
+	// A slash at the beginning or in the middle anchors the pattern to the rules root
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var regex strings.Builder
+	regex.WriteString("^")
+	if !anchored && !strings.HasPrefix(pattern, "**") {
+		regex.WriteString("(?:.*/)?")
+	}
+	regex.WriteString(globToRegex(pattern))
+	if dirOnly {
+		regex.WriteString("/.*$")
+	} else {
+		regex.WriteString("(?:/.*)?$")
+	}
+
+	result.Regex = regex.String()
+	if _, err := regexp.Compile(result.Regex); err != nil {
+		return models.IgnorePattern{}, false, fmt.Errorf("invalid pattern '%s': %w", line, err)
+	}
+
+	return result, true, nil
+}
+
+// globToRegex converts gitignore glob syntax into a regular expression fragment
+func globToRegex(pattern string) string {
+	var regex strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				atStart := i == 0 || pattern[i-1] == '/'
+				atEnd := i+2 == len(pattern)
+				if atStart && !atEnd && pattern[i+2] == '/' {
+					// "**/" matches zero or more directories
+					regex.WriteString("(?:.*/)?")
+					i += 2
+					continue
+				}
+				if atStart && atEnd {
+					// trailing "**" matches everything inside
+					regex.WriteString(".*")
+					i++
+					continue
+				}
+			}
+			regex.WriteString("[^/]*")
+		case '?':
+			regex.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				regex.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			regex.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				regex.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		default:
+			regex.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return regex.String()
+}
+
+// IsIgnored reports whether a relative path is ignored by the patterns.
+// The last matching pattern wins, so negations can re-include previously ignored files, but as in git
+// not files below an ignored directory. Reserved files such as .ruleignore itself are always ignored.
+func (s *FileFilterService) IsIgnored(relativePath string, patterns []models.IgnorePattern) bool {
+	normalizedPath := filepath.ToSlash(relativePath)
+	if isReservedFile(normalizedPath) {
+		return true
+	}
+
+	parts := strings.Split(normalizedPath, "/")
+	for i := 1; i < len(parts); i++ {
+		if s.lastMatchIgnores(strings.Join(parts[:i], "/"), true, patterns) {
+			return true
+		}
+	}
+	return s.lastMatchIgnores(normalizedPath, false, patterns)
+}
+
+// lastMatchIgnores reports whether the last pattern matching a file or directory ignores it.
+// Directories are matched by directory patterns such as drafts/ and by patterns matching their path.
+func (s *FileFilterService) lastMatchIgnores(normalizedPath string, isDir bool, patterns []models.IgnorePattern) bool {
+	ignored := false
+	for _, pattern := range patterns {
+		candidate := normalizedPath
+		if isDir && strings.HasSuffix(pattern.Pattern, "/") {
+			candidate += "/"
+		}
+		if s.matchesIgnorePattern(candidate, pattern) {
+			ignored = !pattern.IsNegation
+		}
+	}
+	return ignored
+}
+
+// matchesIgnorePattern checks a slash-separated relative path against a compiled pattern
+func (s *FileFilterService) matchesIgnorePattern(normalizedPath string, pattern models.IgnorePattern) bool {
+	regex, ok := s.ignoreRegexCache[pattern.Regex]
+	if !ok {
+		compiled, err := regexp.Compile(pattern.Regex)
+		if err != nil {
+			return false
+		}
+		s.ignoreRegexCache[pattern.Regex] = compiled
+		regex = compiled
+	}
+	return regex.MatchString(normalizedPath)
+}
+
+// FilterIgnoredFiles removes files ignored by the patterns
+func (s *FileFilterService) FilterIgnoredFiles(files []string, baseDir string, patterns []models.IgnorePattern) []string {
+	var filtered []string
+	for _, file := range files {
+		relativePath, err := filepath.Rel(baseDir, file)
+		if err != nil {
+			relativePath = filepath.Base(file)
+		}
+
+		if !s.IsIgnored(relativePath, patterns) {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
+
+// isReservedFile reports whether a relative path belongs to the syncer or to git and must never be synced.
+// The .git directory shows up when the rules live at the root of a repository, such as a cache clone.
+// Temp files are left behind only when a sync was killed while writing.
+func isReservedFile(normalizedPath string) bool {
+	if normalizedPath == gitDirName || strings.HasPrefix(normalizedPath, gitDirName+"/") {
+		return true
+	}
+	if isTempFileName(path.Base(normalizedPath)) {
+		return true
+	}
+	return normalizedPath == ruleignoreFileName || normalizedPath == syncStateFileName
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsIgnored(t *testing.T) {
+	outputService := NewOutputService()
+	fileFilterService := NewFileFilterService(outputService, NewOSFileSystem())
+
+	patterns, err := fileFilterService.CompileIgnorePatterns([]string{
+		"# comment",
+		"",
+		"secret-rules.mdc",
+		"drafts/",
+		"*.backup.mdc",
+		"/root-only.mdc",
+		"**/private/**",
+		"**/temp/**/*.mdc",
+		"!important.mdc",
+		"!**/public/**/*.mdc",
+		"!keep.backup.mdc",
+		"archive/*",
+		"!archive/keep.mdc",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error compiling patterns: %v", err)
+	}
+
+	tests := []struct {
+		filepath    string
+		expected    bool
+		description string
+	}{
+		{
+			filepath:    "secret-rules.mdc",
+			expected:    true,
+			description: "Exact file name should be ignored",
+		},
+		{
+			filepath:    "nested/secret-rules.mdc",
+			expected:    true,
+			description: "Unanchored file name should be ignored at any depth",
+		},
+		{
+			filepath:    "drafts/new-rule.mdc",
+			expected:    true,
+			description: "Files inside ignored directory should be ignored",
+		},
+		{
+			filepath:    "drafts.mdc",
+			expected:    false,
+			description: "Directory pattern should not match files with the same prefix",
+		},
+		{
+			filepath:    "go/old.backup.mdc",
+			expected:    true,
+			description: "Wildcard pattern should match in subdirectories",
+		},
+		{
+			filepath:    "root-only.mdc",
+			expected:    true,
+			description: "Anchored pattern should match at root",
+		},
+		{
+			filepath:    "nested/root-only.mdc",
+			expected:    false,
+			description: "Anchored pattern should not match in subdirectories",
+		},
+		{
+			filepath:    "team/private/notes.mdc",
+			expected:    true,
+			description: "Double star directory pattern should match nested files",
+		},
+		{
+			filepath:    "temp/a/b/rule.mdc",
+			expected:    true,
+			description: "Double star in the middle should match any depth",
+		},
+		{
+			filepath:    "temp/rule.md",
+			expected:    false,
+			description: "Double star pattern should respect extension",
+		},
+		{
+			filepath:    "go/keep.backup.mdc",
+			expected:    false,
+			description: "Negation should re-include previously ignored file",
+		},
+		{
+			filepath:    "archive/keep.mdc",
+			expected:    false,
+			description: "Later negation should win over earlier pattern for the contents of a directory",
+		},
+		{
+			filepath:    "archive/old.mdc",
+			expected:    true,
+			description: "Directory contents pattern should ignore files not re-included",
+		},
+		{
+			filepath:    "drafts/important.mdc",
+			expected:    true,
+			description: "Negation should not re-include a file below an ignored directory, as in git",
+		},
+		{
+			filepath:    "team/private/public/shared.mdc",
+			expected:    true,
+			description: "Negation should not re-include a file below a directory matched by a double star pattern",
+		},
+		{
+			filepath:    "team/public/shared.mdc",
+			expected:    false,
+			description: "File matching only a negation should not be ignored",
+		},
+		{
+			filepath:    "go-development.mdc",
+			expected:    false,
+			description: "Regular file should not be ignored",
+		},
+		{
+			filepath:    ".ruleignore",
+			expected:    true,
+			description: "Ignore file itself should never be synced",
+		},
+		{
+			filepath:    ".git/config",
+			expected:    true,
+			description: "Git internals of a repository root should never be synced",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			result := fileFilterService.IsIgnored(test.filepath, patterns)
+			if result != test.expected {
+				t.Errorf("Path %s: expected ignored=%v, got %v", test.filepath, test.expected, result)
+			}
+		})
+	}
+}
+
+func TestLoadIgnorePatterns(t *testing.T) {
+	outputService := NewOutputService()
+	fileFilterService := NewFileFilterService(outputService, NewOSFileSystem())
+
+	projectDir := t.TempDir()
+	centralDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(projectDir, ruleignoreFileName), []byte("!drafts/keep.mdc\nlocal.mdc\n"), 0644); err != nil {
+		t.Fatalf("Failed to write project ignore file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(centralDir, ruleignoreFileName), []byte("drafts/\n"), 0644); err != nil {
+		t.Fatalf("Failed to write central ignore file: %v", err)
+	}
+
+	patterns, err := fileFilterService.LoadIgnorePatterns(projectDir, centralDir, filepath.Join(centralDir, "missing"))
+	if err != nil {
+		t.Fatalf("Unexpected error loading patterns: %v", err)
+	}
+
+	if len(patterns) != 3 {
+		t.Fatalf("Expected 3 patterns, got %d", len(patterns))
+	}
+
+	if !fileFilterService.IsIgnored("drafts/keep.mdc", patterns) {
+		t.Errorf("Central pattern loaded last should take precedence over project negation")
+	}
+	if !fileFilterService.IsIgnored("local.mdc", patterns) {
+		t.Errorf("Project pattern should be applied")
+	}
+}
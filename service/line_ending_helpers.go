@@ -0,0 +1,285 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+const (
+	gitattributesFileName = ".gitattributes"
+	editorconfigFileName  = ".editorconfig"
+)
+
+// editorconfigBraceRegex matches the innermost {a,b} alternation of an .editorconfig glob
+var editorconfigBraceRegex = regexp.MustCompile(`\{([^{}]*,[^{}]*)\}`)
+
+// lineEndingFormat is how the lines of a synced file end
+type lineEndingFormat struct {
+	eol          string // Written for every line break, only for a rewritten header when preserving
+	finalNewline bool   // Whether a missing final newline is added
+	preserve     bool   // Whether the source keeps its original line breaks
+}
+
+// validateLineEndingPolicy reports unknown line ending policies before any file is touched
+func validateLineEndingPolicy(policy models.LineEndingPolicy) error {
+	switch policy {
+	case "", models.LineEndingsLF, models.LineEndingsCRLF, models.LineEndingsPreserve, models.LineEndingsAuto:
+		return nil
+	default:
+		return fmt.Errorf("invalid line endings %q: use %s, %s, %s or %s", policy,
+			models.LineEndingsLF, models.LineEndingsCRLF, models.LineEndingsPreserve, models.LineEndingsAuto)
+	}
+}
+
+// lineEndingFormat resolves the format a file synced from srcContent to dstPath is written with
+func (s *SyncService) lineEndingFormat(srcContent []byte, dstPath string, policy models.LineEndingPolicy) (lineEndingFormat, error) {
+	switch policy {
+	case models.LineEndingsCRLF:
+		return lineEndingFormat{eol: "\r\n", finalNewline: true}, nil
+	case models.LineEndingsPreserve:
+		return lineEndingFormat{eol: detectLineEnding(srcContent), preserve: true}, nil
+	case models.LineEndingsAuto:
+		// .gitattributes decides what git checks out, so it takes precedence over .editorconfig
+		format := lineEndingFormat{eol: detectLineEnding(srcContent), preserve: true}
+		if err := s.applyEditorconfig(&format, dstPath); err != nil {
+			return lineEndingFormat{}, err
+		}
+		if err := s.applyGitattributes(&format, srcContent, dstPath); err != nil {
+			return lineEndingFormat{}, err
+		}
+		return format, nil
+	default:
+		return lineEndingFormat{eol: "\n", finalNewline: true}, nil
+	}
+}
+
+// detectLineEnding returns the line break of the first line, LF for content without line breaks
+func detectLineEnding(content []byte) string {
+	index := bytes.IndexAny(content, "\r\n")
+	switch {
+	case index < 0 || content[index] == '\n':
+		return "\n"
+	case index+1 < len(content) && content[index+1] == '\n':
+		return "\r\n"
+	default:
+		return "\r"
+	}
+}
+
+// normalizeLineEndings converts CRLF and CR line breaks to LF
+func normalizeLineEndings(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	return strings.ReplaceAll(content, "\r", "\n")
+}
+
+// applyLineEndings converts content with LF line endings to the format
+func applyLineEndings(content string, format lineEndingFormat) []byte {
+	if format.finalNewline && content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if format.eol != "\n" {
+		content = strings.ReplaceAll(content, "\n", format.eol)
+	}
+	return []byte(content)
+}
+
+// preserveLineEndings returns content with its line breaks unchanged, adding a missing final newline if the format asks for it
+func preserveLineEndings(content []byte, format lineEndingFormat) []byte {
+	if format.finalNewline && len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) && !bytes.HasSuffix(content, []byte("\r")) {
+		content = append(content, format.eol...)
+	}
+	return content
+}
+
+// skipLines returns content after its first count lines, whatever their line breaks
+func skipLines(content []byte, count int) []byte {
+	for ; count > 0 && len(content) > 0; count-- {
+		index := bytes.IndexAny(content, "\r\n")
+		if index < 0 {
+			return nil
+		}
+		if content[index] == '\r' && index+1 < len(content) && content[index+1] == '\n' {
+			index++
+		}
+		content = content[index+1:]
+	}
+	return content
+}
+
+// isBinary reports whether content looks binary, as git does, binary files are copied unchanged
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// configDirs returns the directories from the repository root containing path down to the directory of path.
+// Without a repository the walk stops at the filesystem root.
+func (s *SyncService) configDirs(path string) []string {
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+		if _, err := s.fileSystem.Stat(filepath.Join(dir, gitDirName)); err == nil || filepath.Dir(dir) == dir {
+			return dirs
+		}
+	}
+}
+
+// readConfigLines reads the lines of a config file, a missing file has none
+func (s *SyncService) readConfigLines(path string) ([]string, error) {
+	content, err := s.fileSystem.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSpace(scanner.Text()))
+	}
+	return lines, nil
+}
+
+// applyGitattributes applies the text and eol attributes .gitattributes files set for path.
+// Files marked -text or binary keep the line endings of the source.
+func (s *SyncService) applyGitattributes(format *lineEndingFormat, srcContent []byte, path string) error {
+	text, eol := "", ""
+	for _, dir := range s.configDirs(path) {
+		lines, err := s.readConfigLines(filepath.Join(dir, gitattributesFileName))
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(dir, path)
+		if err != nil {
+			continue
+		}
+
+		// Later lines and deeper files override earlier ones
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "!") {
+				continue
+			}
+			pattern, ok, err := s.fileFilterService.CompileIgnorePattern(fields[0])
+			if err != nil || !ok || !s.fileFilterService.matchesIgnorePattern(filepath.ToSlash(relativePath), pattern) {
+				continue
+			}
+			for _, attribute := range fields[1:] {
+				switch attribute {
+				case "text", "-text", "!text":
+					text = attribute
+				case "binary":
+					text = "-text"
+				case "eol=lf", "eol=crlf", "-eol", "!eol":
+					eol = attribute
+				}
+			}
+		}
+	}
+
+	switch {
+	case text == "-text":
+		*format = lineEndingFormat{eol: detectLineEnding(srcContent), preserve: true}
+	case eol == "eol=lf":
+		format.eol, format.preserve = "\n", false
+	case eol == "eol=crlf":
+		format.eol, format.preserve = "\r\n", false
+	}
+	return nil
+}
+
+// applyEditorconfig applies the end_of_line and insert_final_newline properties .editorconfig files set for path.
+// Files closer to path take precedence, a file with root = true ends the search.
+func (s *SyncService) applyEditorconfig(format *lineEndingFormat, path string) error {
+	dirs := s.configDirs(path)
+	start := 0
+	sections := make([][]string, len(dirs))
+	for i := len(dirs) - 1; i >= 0; i-- {
+		lines, err := s.readConfigLines(filepath.Join(dirs[i], editorconfigFileName))
+		if err != nil {
+			return err
+		}
+		sections[i] = lines
+		if isEditorconfigRoot(lines) {
+			start = i
+			break
+		}
+	}
+
+	for i := start; i < len(dirs); i++ {
+		relativePath, err := filepath.Rel(dirs[i], path)
+		if err != nil {
+			continue
+		}
+		matches := false
+		for _, line := range sections[i] {
+			switch {
+			case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+				matches = editorconfigMatches(line[1:len(line)-1], filepath.ToSlash(relativePath))
+			case matches:
+				key, value, ok := strings.Cut(line, "=")
+				if !ok {
+					continue
+				}
+				switch strings.ToLower(strings.TrimSpace(key)) + "=" + strings.ToLower(strings.TrimSpace(value)) {
+				case "end_of_line=lf":
+					format.eol, format.preserve = "\n", false
+				case "end_of_line=crlf":
+					format.eol, format.preserve = "\r\n", false
+				case "end_of_line=cr":
+					format.eol, format.preserve = "\r", false
+				case "insert_final_newline=true":
+					format.finalNewline = true
+				case "insert_final_newline=false":
+					format.finalNewline = false
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isEditorconfigRoot reports whether an .editorconfig sets root = true before its first section
+func isEditorconfigRoot(lines []string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(line, "[") {
+			return false
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "root") && strings.EqualFold(strings.TrimSpace(value), "true") {
+			return true
+		}
+	}
+	return false
+}
+
+// editorconfigMatches reports whether an .editorconfig section glob matches a slash-separated path relative to it.
+// Globs without a slash match file names at any depth, {a,b} alternatives are expanded.
+func editorconfigMatches(glob, relativePath string) bool {
+	if match := editorconfigBraceRegex.FindStringSubmatchIndex(glob); match != nil {
+		for _, alternative := range strings.Split(glob[match[2]:match[3]], ",") {
+			if editorconfigMatches(glob[:match[0]]+alternative+glob[match[1]:], relativePath) {
+				return true
+			}
+		}
+		return false
+	}
+
+	regex := "^"
+	if !strings.Contains(glob, "/") {
+		regex += "(?:.*/)?"
+	}
+	regex += globToRegex(strings.TrimPrefix(glob, "/")) + "$"
+	matched, err := regexp.MatchString(regex, relativePath)
+	return err == nil && matched
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestLineEndingFormat(t *testing.T) {
+	repoDir := filepath.Join(string(filepath.Separator), "repo")
+	dstPath := filepath.Join(repoDir, cursorDirName, rulesDirName, "lang", "go.mdc")
+
+	tests := []struct {
+		policy      models.LineEndingPolicy
+		files       map[string]string // Config files relative to the repository root
+		source      string
+		expected    string // "a\nb" rendered in the resolved format
+		description string
+	}{
+		{
+			source:      "a\r\nb",
+			expected:    "a\nb\n",
+			description: "Default policy should write LF with a final newline",
+		},
+		{
+			policy:      models.LineEndingsCRLF,
+			source:      "a\nb\n",
+			expected:    "a\r\nb\r\n",
+			description: "CRLF policy should write CRLF with a final newline",
+		},
+		{
+			policy:      models.LineEndingsPreserve,
+			source:      "a\r\nb",
+			expected:    "a\r\nb",
+			description: "Preserve policy should keep the source line endings and missing final newline",
+		},
+		{
+			policy:      models.LineEndingsAuto,
+			source:      "a\r\nb",
+			expected:    "a\r\nb",
+			description: "Auto policy without config files should preserve the source",
+		},
+		{
+			policy: models.LineEndingsAuto,
+			files: map[string]string{
+				editorconfigFileName: "root = true\n[*]\nend_of_line = crlf\n[*.{md,mdc}]\ninsert_final_newline = true\n",
+				filepath.Join(cursorDirName, editorconfigFileName):      "[rules/**]\nend_of_line = lf\n",
+				filepath.Join("..", editorconfigFileName):               "[*]\nend_of_line = cr\n",
+				filepath.Join(cursorDirName, rulesDirName, "other.txt"): "",
+			},
+			source:      "a\r\nb",
+			expected:    "a\nb\n",
+			description: "Auto policy should apply the nearest .editorconfig sections up to the root",
+		},
+		{
+			policy: models.LineEndingsAuto,
+			files: map[string]string{
+				editorconfigFileName:  "[*]\nend_of_line = lf\ninsert_final_newline = true\n",
+				gitattributesFileName: "* text=auto\n*.mdc eol=crlf\n",
+			},
+			source:      "a\nb",
+			expected:    "a\r\nb\r\n",
+			description: "Auto policy should let .gitattributes override .editorconfig",
+		},
+		{
+			policy: models.LineEndingsAuto,
+			files: map[string]string{
+				gitattributesFileName: "*.mdc eol=lf\n",
+				filepath.Join(cursorDirName, rulesDirName, gitattributesFileName): "lang/*.mdc -text\n",
+			},
+			source:      "a\r\nb",
+			expected:    "a\r\nb",
+			description: "Auto policy should keep files marked -text in deeper .gitattributes unchanged",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			fileSystem := NewMemoryFileSystem()
+			if err := fileSystem.MkdirAll(filepath.Join(repoDir, gitDirName), 0755); err != nil {
+				t.Fatal(err)
+			}
+			writeFileSystemFiles(t, fileSystem, repoDir, test.files)
+			syncService := NewSyncService(NewOutputService(), NewExecGitClient(), fileSystem)
+
+			format, err := syncService.lineEndingFormat([]byte(test.source), dstPath, test.policy)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if actual := string(applyLineEndings("a\nb", format)); actual != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEditorconfigMatches(t *testing.T) {
+	tests := []struct {
+		glob        string
+		path        string
+		expected    bool
+		description string
+	}{
+		{glob: "*", path: "a/b/go.mdc", expected: true, description: "Star should match files at any depth"},
+		{glob: "*.{md,mdc}", path: "rules/go.mdc", expected: true, description: "Braces should expand alternatives"},
+		{glob: "*.{md,txt}", path: "rules/go.mdc", expected: false, description: "Braces without a matching alternative should not match"},
+		{glob: "rules/*.mdc", path: "rules/go.mdc", expected: true, description: "Globs with a slash should match relative to the file"},
+		{glob: "/rules/*.mdc", path: "x/rules/go.mdc", expected: false, description: "Globs with a slash should be anchored"},
+		{glob: "rules/**", path: "rules/lang/go.mdc", expected: true, description: "Double star should match across directories"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if actual := editorconfigMatches(test.glob, test.path); actual != test.expected {
+				t.Errorf("Expected %s to match %s: %v, got %v", test.glob, test.path, test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPullRulesWithLineEndings(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	projectRulesDir := filepath.Join(projectDir, cursorDirName, rulesDirName)
+	centralDir := filepath.Join(string(filepath.Separator), "central")
+	rulesDir := filepath.Join(centralDir, rulesDirName)
+
+	tests := []struct {
+		policy          models.LineEndingPolicy
+		project         map[string]string
+		expectedProject map[string]string
+		expectedReason  string
+		description     string
+	}{
+		{
+			project:         map[string]string{"go.mdc": "---\ndescription: Go\n---\n\nUse gofmt.\nKeep\nlines.\n", "notes.md": "Notes\r\nMore"},
+			expectedProject: map[string]string{"go.mdc": "---\ndescription: Go\n---\n\nUse gofmt.\nKeep\nlines.\n", "notes.md": "Notes\r\nMore"},
+			description:     "Files equal under the default policy should not be rewritten",
+		},
+		{
+			expectedProject: map[string]string{"go.mdc": "---\ndescription: Go\n---\n\nUse gofmt.\nKeep\nlines.\n", "notes.md": "Notes\r\nMore"},
+			description:     "Default policy should copy other files than rules byte for byte",
+		},
+		{
+			project:         map[string]string{"go.mdc": "---\ndescription: Go\n---\n\nUse gofmt.\nKeep\nlines.\n\n\n", "notes.md": "Notes\r\nMore"},
+			expectedProject: map[string]string{"go.mdc": "---\ndescription: Go\n---\n\nUse gofmt.\nKeep\nlines.\n", "notes.md": "Notes\r\nMore"},
+			expectedReason:  reasonFormatDiffers,
+			description:     "Trailing blank lines should be detected and rewritten",
+		},
+		{
+			policy:          models.LineEndingsCRLF,
+			project:         map[string]string{"go.mdc": "---\ndescription: Local\n---\nUse gofmt.\nKeep\nlines.\n", "notes.md": "Notes\nMore\n"},
+			expectedProject: map[string]string{"go.mdc": "---\r\ndescription: Local\r\n---\r\nUse gofmt.\r\nKeep\r\nlines.\r\n", "notes.md": "Notes\r\nMore\r\n"},
+			expectedReason:  reasonFormatDiffers,
+			description:     "CRLF policy should convert preserved headers and bodies alike",
+		},
+		{
+			policy:          models.LineEndingsPreserve,
+			expectedProject: map[string]string{"go.mdc": "---\r\ndescription: Go\r\n---\r\n\r\nUse gofmt.\nKeep\r\nlines.", "notes.md": "Notes\r\nMore"},
+			description:     "Preserve policy should copy the source bytes unchanged",
+		},
+		{
+			policy:          models.LineEndingsPreserve,
+			project:         map[string]string{"go.mdc": "---\ndescription: Local\n---\nOld\n", "notes.md": "Notes\r\nMore"},
+			expectedProject: map[string]string{"go.mdc": "---\r\ndescription: Local\r\n---\r\nUse gofmt.\nKeep\r\nlines.", "notes.md": "Notes\r\nMore"},
+			description:     "Preserve policy should keep mixed line endings of the body below a preserved header",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			fileSystem := NewMemoryFileSystem()
+			if err := fileSystem.MkdirAll(projectRulesDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			writeFileSystemFiles(t, fileSystem, projectRulesDir, test.project)
+			writeFileSystemFiles(t, fileSystem, rulesDir, map[string]string{
+				"go.mdc":   "---\r\ndescription: Go\r\n---\r\n\r\nUse gofmt.\nKeep\r\nlines.",
+				"notes.md": "Notes\r\nMore",
+			})
+			git, err := newFakeGitClient(fileSystem, projectDir, centralDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var stdout bytes.Buffer
+			syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), git, fileSystem)
+			options := models.SyncOptions{RulesDir: rulesDir, ProjectDir: projectDir, LineEndings: test.policy}
+
+			result, err := syncService.PullRules(&options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if test.expectedReason != "" {
+				found := false
+				for _, operation := range result.Operations {
+					found = found || operation.Reason == test.expectedReason
+				}
+				if !found {
+					t.Errorf("Expected an operation with reason %q, got %+v", test.expectedReason, result.Operations)
+				}
+			}
+			projectFiles := readFileSystemFiles(t, fileSystem, projectRulesDir)
+			delete(projectFiles, syncStateFileName)
+			assertFiles(t, "project", test.expectedProject, projectFiles)
+
+			// Copying and comparing agree, so a second pull changes nothing and status reports no drift
+			result, err = syncService.PullRules(&options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(result.Operations) != 0 {
+				t.Errorf("Expected no operations on the second pull, got %+v", result.Operations)
+			}
+			report, err := syncService.Status(&options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !report.InSync {
+				t.Errorf("Expected rules to be in sync, got %+v", report.Files)
+			}
+		})
+	}
+}
+
+func TestValidateLineEndingPolicy(t *testing.T) {
+	for _, policy := range []models.LineEndingPolicy{"", models.LineEndingsLF, models.LineEndingsCRLF, models.LineEndingsPreserve, models.LineEndingsAuto} {
+		if err := validateLineEndingPolicy(policy); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", policy, err)
+		}
+	}
+	if err := validateLineEndingPolicy("native"); err == nil {
+		t.Errorf("Expected an unknown policy to be rejected")
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+// defaultMaxRuleLines is the rule length above which lint reports a rule as oversized
+const defaultMaxRuleLines = 500
+
+// Lint checks
+const (
+	lintMissingFrontmatter   = "missing-frontmatter"
+	lintUnclosedFrontmatter  = "unclosed-frontmatter"
+	lintInvalidFrontmatter   = "invalid-frontmatter"
+	lintInvalidValue         = "invalid-value"
+	lintUnknownKey           = "unknown-key"
+	lintInvalidGlob          = "invalid-glob"
+	lintEmptyBody            = "empty-body"
+	lintDuplicateDescription = "duplicate-description"
+	lintOversizedRule        = "oversized-rule"
+)
+
+// lintChecks describes every check in the order they run
+var lintChecks = []struct {
+	rule        string
+	description string
+}{
+	{lintMissingFrontmatter, "Rule has no frontmatter, so Cursor can't tell when to apply it"},
+	{lintUnclosedFrontmatter, "Frontmatter is never closed by a --- line"},
+	{lintInvalidFrontmatter, "Frontmatter is not a valid YAML mapping"},
+	{lintInvalidValue, "Frontmatter key has a value of the wrong type"},
+	{lintUnknownKey, "Frontmatter key is not read by Cursor"},
+	{lintInvalidGlob, "Glob pattern is malformed"},
+	{lintEmptyBody, "Rule has no content below its frontmatter"},
+	{lintDuplicateDescription, "Another rule has the same description"},
+	{lintOversizedRule, "Rule is longer than the line limit"},
+}
+
+// Lint checks every .mdc rule in the project's .cursor/rules directory, or in the central rules directory with central.
+// Central rules are limited to the files a pull would sync.
+func (s *SyncService) Lint(options *models.SyncOptions, central bool) (*models.LintReport, error) {
+	var dir string
+	var scope *syncScope
+	if central {
+		var err error
+		if scope, err = s.resolveSyncScope(options, false); err != nil {
+			return nil, err
+		}
+		dir = scope.rulesDir
+	} else {
+		currentDir := options.ProjectDir
+		if currentDir == "" {
+			var err error
+			if currentDir, err = os.Getwd(); err != nil {
+				return nil, fmt.Errorf("failed to get current directory: %w", err)
+			}
+		}
+		projectRoot, err := s.git.Root(currentDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find git root: %w", err)
+		}
+		dir = filepath.Join(projectRoot, cursorDirName, rulesDirName)
+	}
+
+	return s.lintDir(dir, scope, options.MaxRuleLines)
+}
+
+// lintDir lints the .mdc files in dir, descriptions are compared across all of them.
+// With a scope only files passing its patterns and ignore rules are linted.
+func (s *SyncService) lintDir(dir string, scope *syncScope, maxLines int) (*models.LintReport, error) {
+	if maxLines <= 0 {
+		maxLines = defaultMaxRuleLines
+	}
+	if _, err := s.fileSystem.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to read rules directory %s: %w", dir, err)
+	}
+	files, err := findAllFiles(s.fileSystem, dir)
+	if err != nil {
+		return nil, err
+	}
+	if scope != nil {
+		if files, err = s.findSourceFiles(dir, scope.patterns); err != nil {
+			return nil, err
+		}
+		files = s.fileFilterService.FilterIgnoredFiles(files, dir, scope.ignorePatterns)
+		sort.Strings(files)
+	}
+
+	report := &models.LintReport{Dir: dir, Diagnostics: []models.LintDiagnostic{}}
+	descriptions := make(map[string]string) // First rule using each description
+	for _, file := range files {
+		relativePath, err := s.GetRelativePath(file, dir)
+		if err != nil || filepath.Ext(file) != mdcExtension || isReservedFile(filepath.ToSlash(relativePath)) {
+			continue
+		}
+
+		content, err := s.fileSystem.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		normalized := normalizeLineEndings(string(content))
+
+		diagnostics := s.lintRule(relativePath, normalized, maxLines, descriptions)
+		sort.SliceStable(diagnostics, func(i, j int) bool { return diagnostics[i].Line < diagnostics[j].Line })
+		for _, diagnostic := range diagnostics {
+			diagnostic.Path = file
+			if diagnostic.Severity == models.SeverityError {
+				report.Errors++
+			} else {
+				report.Warnings++
+			}
+			report.Diagnostics = append(report.Diagnostics, diagnostic)
+		}
+		report.Files++
+	}
+	return report, nil
+}
+
+// lintRule checks a single rule with LF line endings, descriptions maps the descriptions of the rules seen so far
+func (s *SyncService) lintRule(relativePath, content string, maxLines int, descriptions map[string]string) []models.LintDiagnostic {
+	var diagnostics []models.LintDiagnostic
+	add := func(line int, severity models.LintSeverity, rule, message string) {
+		diagnostics = append(diagnostics, models.LintDiagnostic{RelativePath: relativePath, Line: line, Severity: severity, Rule: rule, Message: message})
+	}
+
+	header, body, err := splitFrontmatter(content)
+	var frontmatterErr *FrontmatterError
+	switch {
+	case errors.As(err, &frontmatterErr):
+		add(frontmatterErr.Line, models.SeverityError, lintUnclosedFrontmatter, frontmatterErr.Message)
+	case header == "":
+		add(1, models.SeverityWarning, lintMissingFrontmatter, "rule has no frontmatter, Cursor can't tell when to apply it")
+	default:
+		frontmatter, _, err := ParseFrontmatter(content)
+		if err != nil {
+			for _, parseErr := range unwrapErrors(err) {
+				if !errors.As(parseErr, &frontmatterErr) {
+					add(1, models.SeverityError, lintInvalidFrontmatter, parseErr.Error())
+				} else if frontmatterErr.Key != "" {
+					add(frontmatterErr.Line, models.SeverityError, lintInvalidValue, frontmatterErr.Message)
+				} else {
+					add(frontmatterErr.Line, models.SeverityError, lintInvalidFrontmatter, frontmatterErr.Message)
+				}
+			}
+			break
+		}
+
+		for _, key := range frontmatter.keys {
+			line := key.start + 2 // Lines are counted from the line after the opening separator
+			switch key.name {
+			case frontmatterDescription:
+				description := strings.TrimSpace(frontmatter.Header.Description)
+				if description == "" {
+					continue
+				}
+				if first, ok := descriptions[description]; ok {
+					add(line, models.SeverityWarning, lintDuplicateDescription, fmt.Sprintf("description is the same as in %s", first))
+				} else {
+					descriptions[description] = relativePath
+				}
+			case frontmatterGlobs:
+				for _, glob := range frontmatter.Header.Globs {
+					if err := s.fileFilterService.ValidatePatterns([]string{glob}); err != nil {
+						add(line, models.SeverityError, lintInvalidGlob, err.Error())
+					}
+				}
+			case frontmatterAlwaysApply:
+			default:
+				add(line, models.SeverityWarning, lintUnknownKey, fmt.Sprintf("unknown key %q, Cursor only reads %s, %s and %s",
+					key.name, frontmatterDescription, frontmatterGlobs, frontmatterAlwaysApply))
+			}
+		}
+	}
+
+	if err == nil && strings.TrimSpace(body) == "" {
+		// Reported on the closing separator, the body has no line of its own
+		line := strings.Count(header, "\n")
+		if line == 0 {
+			line = 1
+		}
+		add(line, models.SeverityWarning, lintEmptyBody, "rule has no content below its frontmatter")
+	}
+	if lines := strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1; lines > maxLines {
+		add(maxLines+1, models.SeverityWarning, lintOversizedRule, fmt.Sprintf("rule has %d lines, more than the limit of %d, consider splitting it", lines, maxLines))
+	}
+	return diagnostics
+}
+
+// lintBeforePush lints the project rules the push would sync and refuses the push when any of them has errors.
+// The rules repository is fetched by the push itself, not for linting.
+func (s *SyncService) lintBeforePush(options *models.SyncOptions) error {
+	lintOptions := *options
+	lintOptions.NoFetch = true
+	scope, err := s.resolveSyncScope(&lintOptions, false)
+	if err != nil {
+		return err
+	}
+	report, err := s.lintDir(scope.projectRulesDir, scope, options.MaxRuleLines)
+	if err != nil {
+		return fmt.Errorf("failed to lint rules: %w", err)
+	}
+	s.outputService.PrintLintDiagnostics(report)
+	if report.Errors > 0 {
+		return &LintError{Report: report}
+	}
+	return nil
+}
+
+// unwrapErrors returns the errors joined by errors.Join, or err itself
+func unwrapErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestLintDir(t *testing.T) {
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+
+	tests := []struct {
+		files       map[string]string
+		maxLines    int
+		expected    []string // rule@relative path:line
+		description string
+	}{
+		{
+			files: map[string]string{
+				"go.mdc":    "---\ndescription: Go style\nglobs: *.go,cmd/**/*.go\nalwaysApply: false\n---\nUse gofmt.\n",
+				"notes.md":  "",
+				"empty.txt": "---\n",
+			},
+			description: "Valid rules and other files should have no diagnostics",
+		},
+		{
+			files: map[string]string{
+				"plain.mdc":    "Body only\n",
+				"unclosed.mdc": "---\ndescription: test\nBody\n",
+			},
+			expected:    []string{"missing-frontmatter@plain.mdc:1", "unclosed-frontmatter@unclosed.mdc:1"},
+			description: "Missing and unclosed frontmatter should be reported on the first line",
+		},
+		{
+			files: map[string]string{
+				"crlf.mdc": "---\r\ndescription: CRLF\r\nalwaysApply: sometimes\r\n---\r\nBody\r\n",
+				"cr.mdc":   "---\rdescription: CR\ralwaysApply: sometimes\r---\rBody\r",
+			},
+			expected:    []string{"invalid-value@crlf.mdc:3", "invalid-value@cr.mdc:3"},
+			description: "CRLF and CR line endings should be reported on the right line",
+		},
+		{
+			files: map[string]string{
+				"rule.mdc": "---\ndescription: Rule\nalwaysApply: yes please\nglobs: src/[.go\n---\nBody\n",
+			},
+			expected:    []string{"invalid-value@rule.mdc:3"},
+			description: "alwaysApply type errors should be reported on their line",
+		},
+		{
+			files: map[string]string{
+				"rule.mdc": "---\ndescription: Rule\nowner: platform\nglobs: *.go,src/[.go\n---\nBody\n",
+			},
+			expected:    []string{"unknown-key@rule.mdc:3", "invalid-glob@rule.mdc:4"},
+			description: "Unknown keys and malformed globs should be reported on their line",
+		},
+		{
+			files: map[string]string{
+				"rule.mdc": "---\ndescription: ok\n  globs: [\n---\nBody\n",
+			},
+			expected:    []string{"invalid-frontmatter@rule.mdc:3"},
+			description: "YAML syntax errors should be reported on their line",
+		},
+		{
+			files: map[string]string{
+				"a.mdc":        "---\ndescription: Shared\n---\nA\n",
+				"nested/b.mdc": "---\nglobs: *.go\ndescription: Shared\n---\nB\n",
+				"empty.mdc":    "---\ndescription: Empty\n---\n\n",
+			},
+			expected:    []string{"duplicate-description@nested/b.mdc:3", "empty-body@empty.mdc:3"},
+			description: "Duplicate descriptions and empty bodies should be reported",
+		},
+		{
+			files: map[string]string{
+				"long.mdc": "---\ndescription: Long\n---\n" + strings.Repeat("line\n", 5),
+			},
+			maxLines:    5,
+			expected:    []string{"oversized-rule@long.mdc:6"},
+			description: "Rules longer than the limit should be reported at the first line over it",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			fileSystem := NewMemoryFileSystem()
+			writeFileSystemFiles(t, fileSystem, rulesDir, test.files)
+			syncService := NewSyncService(NewOutputService(), NewExecGitClient(), fileSystem)
+
+			report, err := syncService.lintDir(rulesDir, nil, test.maxLines)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var actual []string
+			for _, diagnostic := range report.Diagnostics {
+				actual = append(actual, fmt.Sprintf("%s@%s:%d", diagnostic.Rule, filepath.ToSlash(diagnostic.RelativePath), diagnostic.Line))
+			}
+			if !sameElements(actual, test.expected) {
+				t.Errorf("Expected diagnostics %v, got %v", test.expected, actual)
+			}
+			if report.Errors+report.Warnings != len(report.Diagnostics) {
+				t.Errorf("Expected %d counted diagnostics, got %d errors and %d warnings", len(report.Diagnostics), report.Errors, report.Warnings)
+			}
+		})
+	}
+}
+
+// sameElements reports whether both slices hold the same strings, ignoring order
+func sameElements(a, b []string) bool {
+	counts := make(map[string]int)
+	for _, value := range a {
+		counts[value]++
+	}
+	for _, value := range b {
+		counts[value]--
+	}
+	for _, count := range counts {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPushRulesWithLint(t *testing.T) {
+	fileSystem := NewMemoryFileSystem()
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+	projectRulesDir := filepath.Join(projectDir, cursorDirName, rulesDirName)
+	writeFileSystemFiles(t, fileSystem, projectRulesDir, map[string]string{
+		"broken.mdc": "---\nalwaysApply: sometimes\n---\nBody\n",
+	})
+	writeFileSystemFiles(t, fileSystem, rulesDir, map[string]string{"existing.mdc": "Existing\n"})
+	gitClient, err := newFakeGitClient(fileSystem, projectDir, rulesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stderr), gitClient, fileSystem)
+
+	_, err = syncService.PushRules(&models.SyncOptions{RulesDir: rulesDir, ProjectDir: projectDir, Lint: true, GitWithoutPush: true})
+	var lintErr *LintError
+	if !errors.As(err, &lintErr) || lintErr.Report.Errors != 1 {
+		t.Fatalf("Expected a LintError with one error, got %v", err)
+	}
+	if !strings.Contains(stderr.String(), "broken.mdc:2") {
+		t.Errorf("Expected the diagnostic to be printed with its line, got %q", stderr.String())
+	}
+	assertFiles(t, "central", map[string]string{"existing.mdc": "Existing\n"}, readFileSystemFiles(t, fileSystem, rulesDir))
+
+	// Rules the push would not sync don't block it
+	stderr.Reset()
+	writeFileSystemFiles(t, fileSystem, projectRulesDir, map[string]string{
+		"drafts/broken.mdc": "---\nalwaysApply: sometimes\n---\nBody\n",
+		"go.mdc":            "---\ndescription: Go\nalwaysApply: true\n---\nUse gofmt.\n",
+	})
+	options := &models.SyncOptions{RulesDir: rulesDir, ProjectDir: projectDir, Lint: true, GitWithoutPush: true, IgnoreFiles: "broken.mdc,drafts/"}
+	if _, err := syncService.PushRules(options); err != nil {
+		t.Fatalf("Expected ignored rules not to be linted, got %v", err)
+	}
+	if strings.Contains(stderr.String(), "broken.mdc") {
+		t.Errorf("Expected no diagnostics for ignored rules, got %q", stderr.String())
+	}
+	if files := readFileSystemFiles(t, fileSystem, rulesDir); files["go.mdc"] == "" {
+		t.Errorf("Expected go.mdc to be pushed, got %v", files)
+	}
+}
+
+func TestPrintLintReportSARIF(t *testing.T) {
+	report := &models.LintReport{
+		Dir:    filepath.Join(string(filepath.Separator), "rules"),
+		Files:  1,
+		Errors: 1,
+		Diagnostics: []models.LintDiagnostic{
+			{RelativePath: filepath.Join("nested", "rule.mdc"), Line: 3, Severity: models.SeverityError, Rule: lintInvalidValue, Message: "alwaysApply must be true or false"},
+		},
+	}
+
+	var stdout bytes.Buffer
+	outputService := NewOutputServiceWithWriters(&stdout, &stdout)
+	if err := outputService.SetLintFormat("sarif"); err != nil {
+		t.Fatalf("Unexpected error setting format: %v", err)
+	}
+	outputService.PrintLintReport(report)
+
+	var log sarifLog
+	if err := json.Unmarshal(stdout.Bytes(), &log); err != nil {
+		t.Fatalf("Expected a JSON document, got %q: %v", stdout.String(), err)
+	}
+	if log.Version != sarifVersion || len(log.Runs) != 1 || len(log.Runs[0].Tool.Driver.Rules) != len(lintChecks) {
+		t.Fatalf("Expected one run with every check as a rule, got %+v", log)
+	}
+	expected := []sarifResult{{
+		RuleID:  lintInvalidValue,
+		Level:   "error",
+		Message: sarifMessage{Text: "alwaysApply must be true or false"},
+		Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactURI{URI: "nested/rule.mdc", URIBaseID: sarifRulesBase},
+			Region:           sarifRegion{StartLine: 3},
+		}}},
+	}}
+	if !reflect.DeepEqual(log.Runs[0].Results, expected) {
+		t.Errorf("Expected results %+v, got %+v", expected, log.Runs[0].Results)
+	}
+	if err := outputService.SetFormat("sarif"); err == nil {
+		t.Errorf("Expected SARIF to be rejected for commands other than lint")
+	}
+}
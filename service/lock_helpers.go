@@ -0,0 +1,270 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+const (
+	rulesLockFileName = "rules.lock"
+	rulesLockVersion  = 1
+)
+
+// PullLocked pulls the rules exactly as they were at the commit pinned in rules.lock.
+// The lockfile is left unchanged and the pull fails when the pinned rules don't match its hashes.
+func (s *SyncService) PullLocked(options *models.SyncOptions) (*models.SyncResult, error) {
+	scope, err := s.resolveSyncScope(options, false)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := s.loadRulesLock(scope.projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		return nil, fmt.Errorf("%s not found in %s, run pull or update first", rulesLockFileName, filepath.Join(scope.projectRoot, cursorDirName))
+	}
+
+	s.outputService.PrintInfo(fmt.Sprintf("Using rules pinned at %s", lock.Commit))
+	plan, cleanup, err := s.planAtCommit(scope, options, lock.Commit)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if err := verifyRulesLock(lock, plan.SourceHashes); err != nil {
+		return nil, err
+	}
+	return s.execute(plan)
+}
+
+// UpdateRules moves the pin in rules.lock to the latest commit of the rules repository and pulls that commit.
+// Uncommitted changes in the rules directory are not pulled, so the lockfile always matches a commit.
+func (s *SyncService) UpdateRules(options *models.SyncOptions) (*models.SyncResult, error) {
+	scope, err := s.resolveSyncScope(options, !options.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := s.git.HeadSHA(scope.rulesDir)
+	if err != nil {
+		return nil, fmt.Errorf("rules directory %s is not a git repository: %w", scope.rulesDir, err)
+	}
+
+	previous, err := s.loadRulesLock(scope.projectRoot)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil && previous.Commit != commit {
+		s.outputService.PrintInfo(fmt.Sprintf("Updating rules pin from %s to %s", previous.Commit, commit))
+	} else {
+		s.outputService.PrintInfo(fmt.Sprintf("Pinning rules to %s", commit))
+	}
+
+	plan, cleanup, err := s.planAtCommit(scope, options, commit)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	result, err := s.execute(plan)
+	if result == nil {
+		return nil, err
+	}
+	s.recordRulesLock(plan, result)
+	return result, err
+}
+
+// planAtCommit plans a pull from a temporary worktree of the rules repository checked out at commit.
+// The returned cleanup removes the worktree and must be called once the plan has been executed.
+func (s *SyncService) planAtCommit(scope *syncScope, options *models.SyncOptions, commit string) (*models.SyncPlan, func(), error) {
+	rulesDir, cleanup, err := s.checkoutRevision(scope.rulesDir, commit, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pinnedOptions := *options
+	pinnedOptions.RulesDir = rulesDir
+	pinnedOptions.NoFetch = true
+	plan, err := s.Plan(models.DirectionPull, &pinnedOptions)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return plan, cleanup, nil
+}
+
+// checkoutRevision adds a worktree of the repository containing rulesDir at commit and returns the rules
+// directory inside it together with a cleanup function. The worktree is detached unless a new branch is given.
+func (s *SyncService) checkoutRevision(rulesDir, commit, branch string) (string, func(), error) {
+	repoRoot, err := s.git.Root(rulesDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("rules directory %s is not a git repository: %w", rulesDir, err)
+	}
+	prefix, err := s.git.Prefix(rulesDir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !s.git.CommitExists(repoRoot, commit) {
+		return "", nil, fmt.Errorf("commit %s not found in %s, fetch the rules repository first", commit, repoRoot)
+	}
+
+	tempDir, err := s.fileSystem.MkdirTemp("", "cursor-rules-syncer-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	worktreeDir := filepath.Join(tempDir, "rules")
+
+	// Worktrees left behind by an interrupted run would otherwise accumulate
+	if err := s.git.PruneWorktrees(repoRoot); err != nil {
+		s.outputService.PrintWarningf("Could not prune worktrees in %s: %v", repoRoot, err)
+	}
+	if err := s.git.AddWorktree(repoRoot, worktreeDir, commit, branch); err != nil {
+		s.fileSystem.RemoveAll(tempDir)
+		return "", nil, err
+	}
+
+	cleanup := func() {
+		if err := s.git.RemoveWorktree(repoRoot, worktreeDir); err != nil {
+			s.outputService.PrintWarningf("Could not remove worktree %s: %v", worktreeDir, err)
+		}
+		s.fileSystem.RemoveAll(tempDir)
+	}
+	return filepath.Join(worktreeDir, prefix), cleanup, nil
+}
+
+// describeSource returns the commit of the repository containing sourceDir, empty outside git,
+// and the hashes of the exact bytes of the given files by relative path
+func (s *SyncService) describeSource(sourceDir string, sourceFiles []string) (string, map[string]string, error) {
+	commit, err := s.git.HeadSHA(sourceDir)
+	if err != nil {
+		commit = ""
+	}
+
+	hashes := make(map[string]string, len(sourceFiles))
+	for _, sourceFile := range sourceFiles {
+		relativePath, err := s.GetRelativePath(sourceFile, sourceDir)
+		if err != nil {
+			return "", nil, err
+		}
+		content, err := s.fileSystem.ReadFile(sourceFile)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read %s: %w", relativePath, err)
+		}
+		hashes[filepath.ToSlash(relativePath)] = hashContent(string(content))
+	}
+	return commit, hashes, nil
+}
+
+// recordRulesLock writes rules.lock after a pull from a git repository.
+// Dry runs, pulls with failed files and pulls of uncommitted rules leave the lockfile untouched,
+// since it must always be reproducible with pull --locked.
+func (s *SyncService) recordRulesLock(plan *models.SyncPlan, result *models.SyncResult) {
+	if plan.Options.DryRun || plan.SourceCommit == "" {
+		return
+	}
+	if len(result.Errors) > 0 {
+		s.outputService.PrintWarningf("%s not updated because some files could not be synced", rulesLockFileName)
+		return
+	}
+
+	if s.hasUncommittedChanges(plan.SourceDir) {
+		s.outputService.PrintWarningf("%s not updated because the rules directory has uncommitted changes", rulesLockFileName)
+		return
+	}
+
+	lock := &models.RulesLock{
+		Version: rulesLockVersion,
+		Commit:  plan.SourceCommit,
+		Files:   plan.SourceHashes,
+	}
+	if err := s.saveRulesLock(plan.ProjectRoot, lock); err != nil {
+		s.outputService.PrintWarningf("Could not save %s: %v", rulesLockFileName, err)
+	}
+}
+
+// hasUncommittedChanges reports whether files below dir have uncommitted changes, errors count as clean
+func (s *SyncService) hasUncommittedChanges(dir string) bool {
+	prefix, err := s.git.Prefix(dir)
+	if err != nil {
+		return false
+	}
+	changedPaths, err := s.git.Status(dir)
+	if err != nil {
+		return false
+	}
+	for _, changedPath := range changedPaths {
+		if strings.HasPrefix(changedPath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadRulesLock reads rules.lock from the project, a missing lockfile yields nil
+func (s *SyncService) loadRulesLock(projectRoot string) (*models.RulesLock, error) {
+	content, err := s.fileSystem.ReadFile(filepath.Join(projectRoot, cursorDirName, rulesLockFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", rulesLockFileName, err)
+	}
+
+	lock := &models.RulesLock{}
+	if err := json.Unmarshal(content, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", rulesLockFileName, err)
+	}
+	if lock.Commit == "" {
+		return nil, fmt.Errorf("%s does not pin a commit", rulesLockFileName)
+	}
+	return lock, nil
+}
+
+// saveRulesLock writes rules.lock to the project's .cursor directory
+func (s *SyncService) saveRulesLock(projectRoot string, lock *models.RulesLock) error {
+	content, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", rulesLockFileName, err)
+	}
+
+	cursorDir := filepath.Join(projectRoot, cursorDirName)
+	if err := s.fileSystem.MkdirAll(cursorDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", cursorDir, err)
+	}
+	return writeFileAtomic(s.fileSystem, filepath.Join(cursorDir, rulesLockFileName), append(content, '\n'), 0644)
+}
+
+// verifyRulesLock checks that the pinned rules hash to the values recorded in the lockfile
+func verifyRulesLock(lock *models.RulesLock, hashes map[string]string) error {
+	var mismatches []string
+	for relativePath, hash := range lock.Files {
+		actual, ok := hashes[relativePath]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, relativePath+" (missing)")
+		case actual != hash:
+			mismatches = append(mismatches, relativePath+" (hash differs)")
+		}
+	}
+	for relativePath := range hashes {
+		if _, ok := lock.Files[relativePath]; !ok {
+			mismatches = append(mismatches, relativePath+" (not in lockfile)")
+		}
+	}
+
+	if len(mismatches) > 0 {
+		sort.Strings(mismatches)
+		return fmt.Errorf("rules at %s don't match %s, run update to re-pin:\n  %s",
+			lock.Commit, rulesLockFileName, strings.Join(mismatches, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestVerifyRulesLock(t *testing.T) {
+	lock := &models.RulesLock{
+		Commit: "abc123",
+		Files:  map[string]string{"a.mdc": "hash-a", "nested/b.md": "hash-b"},
+	}
+
+	tests := []struct {
+		hashes      map[string]string
+		expectedErr string
+		description string
+	}{
+		{
+			hashes:      map[string]string{"a.mdc": "hash-a", "nested/b.md": "hash-b"},
+			description: "Matching hashes should pass",
+		},
+		{
+			hashes:      map[string]string{"a.mdc": "other", "nested/b.md": "hash-b"},
+			expectedErr: "a.mdc (hash differs)",
+			description: "Changed file should be reported",
+		},
+		{
+			hashes:      map[string]string{"a.mdc": "hash-a"},
+			expectedErr: "nested/b.md (missing)",
+			description: "Missing file should be reported",
+		},
+		{
+			hashes:      map[string]string{"a.mdc": "hash-a", "nested/b.md": "hash-b", "c.mdc": "hash-c"},
+			expectedErr: "c.mdc (not in lockfile)",
+			description: "Unexpected file should be reported",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := verifyRulesLock(lock, test.hashes)
+			if test.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("Expected error containing %q, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckoutRevision(t *testing.T) {
+	repoDir := initTestRepo(t)
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	rulesDir := filepath.Join(repoDir, "rules")
+
+	writeFileSystemFiles(t, NewOSFileSystem(), rulesDir, map[string]string{"rule.mdc": "v1\n"})
+	runTestGit(t, repoDir, "add", "-A")
+	runTestGit(t, repoDir, "commit", "-q", "-m", "v1")
+	pinned := runTestGit(t, repoDir, "rev-parse", "HEAD")
+
+	writeFileSystemFiles(t, NewOSFileSystem(), rulesDir, map[string]string{"rule.mdc": "v2\n"})
+	runTestGit(t, repoDir, "commit", "-q", "-am", "v2")
+	writeFileSystemFiles(t, NewOSFileSystem(), rulesDir, map[string]string{"rule.mdc": "uncommitted\n"})
+
+	syncService := NewSyncService(NewOutputService(), NewExecGitClient(), NewOSFileSystem())
+	checkoutDir, cleanup, err := syncService.checkoutRevision(rulesDir, pinned, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(checkoutDir, "rule.mdc"), "v1\n")
+	commit, hashes, err := syncService.describeSource(checkoutDir, []string{filepath.Join(checkoutDir, "rule.mdc")})
+	if err != nil {
+		t.Fatalf("Unexpected error describing source: %v", err)
+	}
+	if commit != pinned || hashes["rule.mdc"] != hashContent("v1\n") {
+		t.Errorf("Expected pinned commit and hash, got %s %v", commit, hashes)
+	}
+
+	cleanup()
+	if worktrees := runTestGit(t, repoDir, "worktree", "list"); strings.Count(worktrees, "\n") != 0 {
+		t.Errorf("Expected worktree to be removed, got:\n%s", worktrees)
+	}
+	assertFileContent(t, filepath.Join(rulesDir, "rule.mdc"), "uncommitted\n")
+}
+
+func TestPullLockedAndUpdateWithFakeGit(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	projectRulesDir := filepath.Join(projectDir, cursorDirName, rulesDirName)
+	centralDir := filepath.Join(string(filepath.Separator), "central")
+	rulesDir := filepath.Join(centralDir, rulesDirName)
+
+	fileSystem := NewMemoryFileSystem()
+	if err := fileSystem.MkdirAll(projectRulesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFileSystemFiles(t, fileSystem, centralDir, map[string]string{"rules/rule.mdc": "v1\n", "README.md": "readme\n"})
+	git, err := newFakeGitClient(fileSystem, projectDir, centralDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	central := git.repos[centralDir]
+	pinned := central.headSHA()
+
+	var stdout bytes.Buffer
+	syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), git, fileSystem)
+	options := models.SyncOptions{RulesDir: rulesDir, ProjectDir: projectDir}
+	if _, err := syncService.PullRules(&options); err != nil {
+		t.Fatalf("Unexpected error pulling: %v", err)
+	}
+	lock, err := syncService.loadRulesLock(projectDir)
+	if err != nil || lock == nil || lock.Commit != pinned {
+		t.Fatalf("Expected rules.lock pinning %s, got %+v (%v)", pinned, lock, err)
+	}
+
+	// A locked pull checks the pinned commit out in a worktree, leaving the rules directory alone
+	git.commitFiles(t, centralDir, "v2", map[string]string{"rules/rule.mdc": "v2\n"})
+	writeFileSystemFiles(t, fileSystem, rulesDir, map[string]string{"rule.mdc": "uncommitted\n"})
+	lockedOptions := options
+	lockedOptions.Locked = true
+	if _, err := syncService.PullRules(&lockedOptions); err != nil {
+		t.Fatalf("Unexpected error pulling locked: %v", err)
+	}
+	assertFiles(t, "project", map[string]string{"rule.mdc": "v1\n"}, readProjectRules(t, fileSystem, projectRulesDir))
+	assertFiles(t, "rules", map[string]string{"rule.mdc": "uncommitted\n"}, readFileSystemFiles(t, fileSystem, rulesDir))
+	if len(central.worktrees) != 1 || !central.worktrees[0].removed || central.worktrees[0].branch != "" {
+		t.Errorf("Expected one detached worktree to be added and removed, got %+v", central.worktrees)
+	}
+	var leftovers []string
+	err = fileSystem.WalkDir(os.TempDir(), func(path string, entry fs.DirEntry, err error) error {
+		if path != os.TempDir() {
+			leftovers = append(leftovers, path)
+		}
+		return err
+	})
+	if err != nil || len(leftovers) != 0 {
+		t.Errorf("Expected the worktree to be checked out in a temp dir of the filesystem and removed, got %v (%v)", leftovers, err)
+	}
+
+	// Update pins the latest commit, uncommitted changes are not pulled
+	if _, err := syncService.UpdateRules(&options); err != nil {
+		t.Fatalf("Unexpected error updating: %v", err)
+	}
+	assertFiles(t, "project", map[string]string{"rule.mdc": "v2\n"}, readProjectRules(t, fileSystem, projectRulesDir))
+	if lock, err := syncService.loadRulesLock(projectDir); err != nil || lock.Commit != central.headSHA() {
+		t.Errorf("Expected rules.lock pinning %s, got %+v (%v)", central.headSHA(), lock, err)
+	}
+
+	// A pin to a commit that was never fetched fails before anything is pulled
+	if err := syncService.saveRulesLock(projectDir, &models.RulesLock{Version: rulesLockVersion, Commit: "unknown"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syncService.PullRules(&lockedOptions); err == nil || !strings.Contains(err.Error(), "commit unknown not found") {
+		t.Errorf("Expected a missing pinned commit to fail, got %v", err)
+	}
+}
+
+// readProjectRules returns the project rules without the sync state
+func readProjectRules(t *testing.T, fileSystem FileSystem, projectRulesDir string) map[string]string {
+	t.Helper()
+	files := readFileSystemFiles(t, fileSystem, projectRulesDir)
+	delete(files, syncStateFileName)
+	return files
+}
+
+func TestDescribeSourceHashesRawBytes(t *testing.T) {
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+	fileSystem := NewMemoryFileSystem()
+	files := map[string]string{"crlf.mdc": "---\r\ndescription: Go\r\n---\r\nUse gofmt.\r\n", "trailing.md": "notes  \n\n\n"}
+	writeFileSystemFiles(t, fileSystem, rulesDir, files)
+
+	syncService := NewSyncService(NewOutputService(), NewExecGitClient(), fileSystem)
+	var sourceFiles []string
+	for relativePath := range files {
+		sourceFiles = append(sourceFiles, filepath.Join(rulesDir, relativePath))
+	}
+	_, hashes, err := syncService.describeSource(rulesDir, sourceFiles)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for relativePath, content := range files {
+		if hashes[relativePath] != hashContent(content) {
+			t.Errorf("Expected the hash of the bytes of %s, got %s", relativePath, hashes[relativePath])
+		}
+	}
+}
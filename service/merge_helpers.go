@@ -0,0 +1,178 @@
+package service
+
+import (
+	"strings"
+)
+
+const (
+	conflictMarkerOurs   = "<<<<<<<"
+	conflictMarkerBase   = "======="
+	conflictMarkerTheirs = ">>>>>>>"
+)
+
+// diffHunk describes base lines [baseStart, baseEnd) replaced by lines
+type diffHunk struct {
+	baseStart int
+	baseEnd   int
+	lines     []string
+}
+
+// splitLines splits normalized content into lines, a trailing newline does not produce an empty line
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// joinLines joins lines back into content terminated by a newline
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// longestCommonSubsequence returns pairs of matching line indexes between a and b in increasing order
+func longestCommonSubsequence(a, b []string) [][2]int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	var matches [][2]int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			matches = append(matches, [2]int{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
+
+// diffHunks returns the changes turning base into other, expressed in base coordinates
+func diffHunks(base, other []string) []diffHunk {
+	var hunks []diffHunk
+	baseIndex, otherIndex := 0, 0
+	matches := append(longestCommonSubsequence(base, other), [2]int{len(base), len(other)})
+	for _, match := range matches {
+		if match[0] > baseIndex || match[1] > otherIndex {
+			hunks = append(hunks, diffHunk{
+				baseStart: baseIndex,
+				baseEnd:   match[0],
+				lines:     other[otherIndex:match[1]],
+			})
+		}
+		baseIndex, otherIndex = match[0]+1, match[1]+1
+	}
+	return hunks
+}
+
+// mergeThreeWay merges ours and theirs relative to base line by line.
+// Non-overlapping changes are combined, identical changes are taken once and
+// conflicting regions are wrapped in conflict markers using the given labels.
+// The boolean result reports whether any conflict was found.
+func mergeThreeWay(base, ours, theirs, oursLabel, theirsLabel string) (string, bool) {
+	baseLines := splitLines(base)
+	oursHunks := diffHunks(baseLines, splitLines(ours))
+	theirsHunks := diffHunks(baseLines, splitLines(theirs))
+
+	var merged []string
+	hasConflict := false
+	baseIndex := 0
+	i, j := 0, 0
+	for i < len(oursHunks) || j < len(theirsHunks) {
+		// Start a group with the hunk that begins first in base
+		lo := len(baseLines)
+		if i < len(oursHunks) {
+			lo = oursHunks[i].baseStart
+		}
+		if j < len(theirsHunks) && theirsHunks[j].baseStart < lo {
+			lo = theirsHunks[j].baseStart
+		}
+		hi := lo
+
+		// Extend the group while hunks from either side overlap it
+		groupOurs, groupTheirs := []diffHunk{}, []diffHunk{}
+		for {
+			switch {
+			case i < len(oursHunks) && hunksOverlap(oursHunks[i], lo, hi, len(groupOurs)+len(groupTheirs) == 0):
+				groupOurs = append(groupOurs, oursHunks[i])
+				hi = max(hi, oursHunks[i].baseEnd)
+				i++
+				continue
+			case j < len(theirsHunks) && hunksOverlap(theirsHunks[j], lo, hi, len(groupOurs)+len(groupTheirs) == 0):
+				groupTheirs = append(groupTheirs, theirsHunks[j])
+				hi = max(hi, theirsHunks[j].baseEnd)
+				j++
+				continue
+			}
+			break
+		}
+
+		merged = append(merged, baseLines[baseIndex:lo]...)
+		baseIndex = hi
+
+		oursText := applyHunks(baseLines, groupOurs, lo, hi)
+		theirsText := applyHunks(baseLines, groupTheirs, lo, hi)
+		switch {
+		case len(groupTheirs) == 0:
+			merged = append(merged, oursText...)
+		case len(groupOurs) == 0:
+			merged = append(merged, theirsText...)
+		case joinLines(oursText) == joinLines(theirsText):
+			merged = append(merged, oursText...)
+		default:
+			hasConflict = true
+			merged = append(merged, conflictMarkerOurs+" "+oursLabel)
+			merged = append(merged, oursText...)
+			merged = append(merged, conflictMarkerBase)
+			merged = append(merged, theirsText...)
+			merged = append(merged, conflictMarkerTheirs+" "+theirsLabel)
+		}
+	}
+	merged = append(merged, baseLines[baseIndex:]...)
+
+	return joinLines(merged), hasConflict
+}
+
+// hunksOverlap reports whether a hunk belongs to the group spanning base lines [lo, hi).
+// Insertions at the same position as the group are treated as overlapping.
+func hunksOverlap(hunk diffHunk, lo, hi int, empty bool) bool {
+	if empty {
+		return hunk.baseStart == lo
+	}
+	if hunk.baseStart < hi {
+		return true
+	}
+	return hunk.baseStart == hi && (lo == hi || hunk.baseStart == hunk.baseEnd)
+}
+
+// applyHunks returns base lines [lo, hi) with the given hunks applied
+func applyHunks(baseLines []string, hunks []diffHunk, lo, hi int) []string {
+	result := []string{}
+	index := lo
+	for _, hunk := range hunks {
+		result = append(result, baseLines[index:hunk.baseStart]...)
+		result = append(result, hunk.lines...)
+		index = hunk.baseEnd
+	}
+	return append(result, baseLines[index:hi]...)
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestMergeThreeWay(t *testing.T) {
+	base := "one\ntwo\nthree\nfour\nfive\n"
+
+	tests := []struct {
+		ours             string
+		theirs           string
+		expected         string
+		expectedConflict bool
+		description      string
+	}{
+		{
+			ours:        "one\nTWO\nthree\nfour\nfive\n",
+			theirs:      "one\ntwo\nthree\nFOUR\nfive\n",
+			expected:    "one\nTWO\nthree\nFOUR\nfive\n",
+			description: "Non-overlapping changes should be combined",
+		},
+		{
+			ours:        "zero\none\ntwo\nthree\nfour\nfive\n",
+			theirs:      "one\ntwo\nthree\nfour\nfive\nsix\n",
+			expected:    "zero\none\ntwo\nthree\nfour\nfive\nsix\n",
+			description: "Insertions at different positions should be combined",
+		},
+		{
+			ours:        "one\nthree\nfour\nfive\n",
+			theirs:      "one\nthree\nfour\nfive\n",
+			expected:    "one\nthree\nfour\nfive\n",
+			description: "Identical changes should be applied once",
+		},
+		{
+			ours:             "one\nours\nthree\nfour\nfive\n",
+			theirs:           "one\ntheirs\nthree\nfour\nfive\n",
+			expected:         "one\n<<<<<<< project\nours\n=======\ntheirs\n>>>>>>> central\nthree\nfour\nfive\n",
+			expectedConflict: true,
+			description:      "Overlapping changes should produce conflict markers",
+		},
+		{
+			ours:             "one\ntwo\nthree\nfour\nfive\nours\n",
+			theirs:           "one\ntwo\nthree\nfour\nfive\ntheirs\n",
+			expected:         "one\ntwo\nthree\nfour\nfive\n<<<<<<< project\nours\n=======\ntheirs\n>>>>>>> central\n",
+			expectedConflict: true,
+			description:      "Different insertions at the same position should conflict",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			merged, conflict := mergeThreeWay(base, test.ours, test.theirs, "project", "central")
+			if conflict != test.expectedConflict {
+				t.Errorf("Expected conflict=%v, got %v", test.expectedConflict, conflict)
+			}
+			if merged != test.expected {
+				t.Errorf("Unexpected merge result:\n%s\nexpected:\n%s", merged, test.expected)
+			}
+		})
+	}
+}
+
+func TestPlanCopiesWithSyncState(t *testing.T) {
+	outputService := NewOutputService()
+	syncService := NewSyncService(outputService, NewExecGitClient(), NewOSFileSystem())
+
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	base := "---\ndescription: rule\n---\nintro\nbody\noutro\n"
+	writeFileSystemFiles(t, NewOSFileSystem(), srcDir, map[string]string{
+		"central-only.mdc": "---\ndescription: rule\n---\nintro\nnew body\noutro\n",
+		"project-only.mdc": base,
+		"both.mdc":         "---\ndescription: rule\n---\nnew intro\nbody\noutro\n",
+		"conflict.mdc":     "---\ndescription: rule\n---\nintro\ncentral body\noutro\n",
+	})
+	writeFileSystemFiles(t, NewOSFileSystem(), dstDir, map[string]string{
+		"central-only.mdc": base,
+		"project-only.mdc": "---\ndescription: rule\n---\nintro\nlocal body\noutro\n",
+		"both.mdc":         "---\ndescription: rule\n---\nintro\nbody\nlocal outro\n",
+		"conflict.mdc":     "---\ndescription: rule\n---\nintro\nproject body\noutro\n",
+	})
+
+	baseBody := "intro\nbody\noutro\n"
+	state := &models.SyncState{Files: map[string]models.SyncStateEntry{}}
+	for _, file := range []string{"central-only.mdc", "project-only.mdc", "both.mdc", "conflict.mdc"} {
+		state.Files[file] = newSyncStateEntry(baseBody)
+	}
+
+	srcFiles, err := findAllFiles(syncService.fileSystem, srcDir)
+	if err != nil {
+		t.Fatalf("Failed to list source files: %v", err)
+	}
+
+	plan := &models.SyncPlan{
+		Direction: models.DirectionPull,
+		SourceDir: srcDir,
+		TargetDir: dstDir,
+		NextState: copySyncState(state),
+	}
+	syncService.planCopies(plan, srcFiles, state)
+
+	operations := make(map[string]models.FileOperation)
+	for _, operation := range plan.Operations {
+		operations[operation.RelativePath] = operation
+	}
+
+	if operation, ok := operations["central-only.mdc"]; !ok || operation.Merged {
+		t.Errorf("Expected a regular update for a central-only change, got %+v", operation)
+	}
+	if _, ok := operations["project-only.mdc"]; ok {
+		t.Errorf("Expected a project-only change to be kept")
+	}
+	if operation := operations["both.mdc"]; !operation.Merged || operation.MergedBody != "new intro\nbody\nlocal outro\n" {
+		t.Errorf("Expected a clean merge for both.mdc, got %+v", operation)
+	}
+	if _, ok := operations["conflict.mdc"]; ok {
+		t.Errorf("Expected conflicting file to be refused")
+	}
+	if len(plan.Errors) != 1 || plan.Errors[0].RelativePath != "conflict.mdc" {
+		t.Errorf("Expected one conflict error, got %+v", plan.Errors)
+	}
+	if plan.NextState.Files["conflict.mdc"] != state.Files["conflict.mdc"] {
+		t.Errorf("Expected state of a refused file to stay unchanged")
+	}
+}
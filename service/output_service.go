@@ -1,15 +1,27 @@
 package service
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+const (
+	colorGreen  = "\033[32m"
+	colorRed    = "\033[31m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
 )
 
 // OutputService handles all output operations
 type OutputService struct {
 	stdout io.Writer
 	stderr io.Writer
+	format models.OutputFormat
 }
 
 // NewOutputService creates a new OutputService
@@ -17,6 +29,7 @@ func NewOutputService() *OutputService {
 	return &OutputService{
 		stdout: os.Stdout,
 		stderr: os.Stderr,
+		format: models.OutputText,
 	}
 }
 
@@ -25,11 +38,54 @@ func NewOutputServiceWithWriters(stdout, stderr io.Writer) *OutputService {
 	return &OutputService{
 		stdout: stdout,
 		stderr: stderr,
+		format: models.OutputText,
+	}
+}
+
+// SetFormat switches the output format. Machine-readable formats keep stdout free of
+// human-oriented lines and colour codes.
+func (s *OutputService) SetFormat(format string) error {
+	switch models.OutputFormat(format) {
+	case "", models.OutputText:
+		s.format = models.OutputText
+	case models.OutputJSON, models.OutputNDJSON:
+		s.format = models.OutputFormat(format)
+	default:
+		return fmt.Errorf("unknown output format %q: use %s, %s or %s", format, models.OutputText, models.OutputJSON, models.OutputNDJSON)
+	}
+	return nil
+}
+
+// SetLintFormat switches the output format of the lint command, which supports SARIF in addition to the others
+func (s *OutputService) SetLintFormat(format string) error {
+	if models.OutputFormat(format) == models.OutputSARIF {
+		s.format = models.OutputSARIF
+		return nil
+	}
+	if err := s.SetFormat(format); err != nil {
+		return fmt.Errorf("unknown output format %q: use %s, %s, %s or %s", format, models.OutputText, models.OutputJSON, models.OutputNDJSON, models.OutputSARIF)
+	}
+	return nil
+}
+
+// IsMachineReadable reports whether output is JSON, NDJSON or SARIF
+func (s *OutputService) IsMachineReadable() bool {
+	return s.format != models.OutputText
+}
+
+// colorize wraps text in a colour code, machine-readable formats get plain text
+func (s *OutputService) colorize(color, text string) string {
+	if s.IsMachineReadable() {
+		return text
 	}
+	return color + text + colorReset
 }
 
 // PrintInfo prints an informational message
 func (s *OutputService) PrintInfo(message string) {
+	if s.IsMachineReadable() {
+		return
+	}
 	fmt.Fprintln(s.stdout, message)
 }
 
@@ -45,11 +101,15 @@ func (s *OutputService) PrintErrorf(format string, args ...interface{}) {
 
 // PrintOperation prints a file operation with color coding
 func (s *OutputService) PrintOperation(operationType, relativePath string) {
+	if s.IsMachineReadable() {
+		return
+	}
+
 	colors := map[string]string{
-		"add":    "\033[32m", // green
-		"delete": "\033[31m", // red
-		"update": "\033[33m", // yellow
-		"reset":  "\033[0m",  // reset
+		"add":    colorGreen,
+		"delete": colorRed,
+		"update": colorYellow,
+		"reset":  colorReset,
 	}
 
 	symbols := map[string]string{
@@ -73,11 +133,15 @@ func (s *OutputService) PrintOperation(operationType, relativePath string) {
 
 // PrintOperationWithTarget prints operation with additional target info
 func (s *OutputService) PrintOperationWithTarget(operationType, relativePath, target string) {
+	if s.IsMachineReadable() {
+		return
+	}
+
 	colors := map[string]string{
-		"add":    "\033[32m", // green
-		"delete": "\033[31m", // red
-		"update": "\033[33m", // yellow
-		"reset":  "\033[0m",  // reset
+		"add":    colorGreen,
+		"delete": colorRed,
+		"update": colorYellow,
+		"reset":  colorReset,
 	}
 
 	symbols := map[string]string{
@@ -99,29 +163,199 @@ func (s *OutputService) PrintOperationWithTarget(operationType, relativePath, ta
 	fmt.Fprintf(s.stdout, "%s%s %s (to %s)%s\n", color, symbol, relativePath, target, reset)
 }
 
+// PrintFileOperation prints an operation in the current format, NDJSON streams it as an event
+func (s *OutputService) PrintFileOperation(operation models.FileOperation, target string) {
+	switch s.format {
+	case models.OutputNDJSON:
+		s.printEvent(models.OutputEvent{Event: models.EventOperation, Operation: &operation})
+	case models.OutputJSON:
+		// The whole result is printed at once by PrintResult
+	default:
+		if target != "" {
+			s.PrintOperationWithTarget(string(operation.Type), operation.RelativePath, target)
+		} else {
+			s.PrintOperation(string(operation.Type), operation.RelativePath)
+		}
+		s.printOperationDiff(operation)
+	}
+}
+
+// printOperationDiff prints the diffs attached to an operation, the header diff is shown separately
+func (s *OutputService) printOperationDiff(operation models.FileOperation) {
+	if operation.HeaderPreserved {
+		s.PrintInfo("  header differs, destination header is kept")
+	}
+	if operation.FormatOnly {
+		s.PrintInfo("  only line endings or trailing whitespace differ")
+	}
+	if operation.HeaderDiff != "" {
+		s.PrintInfo("  header:")
+		s.PrintDiff(operation.HeaderDiff)
+	}
+	if operation.Diff != "" {
+		s.PrintDiff(operation.Diff)
+	}
+}
+
+// PrintDiff prints a unified diff with added and removed lines colour coded
+func (s *OutputService) PrintDiff(diff string) {
+	if s.IsMachineReadable() {
+		return
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			fmt.Fprintln(s.stdout, line)
+		case strings.HasPrefix(line, "+"):
+			fmt.Fprintln(s.stdout, s.colorize(colorGreen, line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Fprintln(s.stdout, s.colorize(colorRed, line))
+		case strings.HasPrefix(line, "@@"):
+			fmt.Fprintln(s.stdout, s.colorize(colorYellow, line))
+		default:
+			fmt.Fprintln(s.stdout, line)
+		}
+	}
+}
+
+// PrintResult prints the final sync result, a summary line for text and the full result for JSON formats
+func (s *OutputService) PrintResult(result *models.SyncResult) {
+	switch s.format {
+	case models.OutputNDJSON:
+		s.printEvent(models.OutputEvent{Event: models.EventResult, Result: result})
+	case models.OutputJSON:
+		s.printJSON(result, "  ")
+	default:
+		if result.DryRun {
+			s.PrintWarning("Dry run: no changes were written")
+		}
+		s.PrintSummary(result.Summary)
+		if result.Branch != "" && result.CommitSHA != "" {
+			s.PrintInfo(fmt.Sprintf("Committed to branch %s", result.Branch))
+		}
+		if len(result.Errors) > 0 {
+			s.PrintWarningf("%d file(s) could not be synced, see errors above", len(result.Errors))
+		}
+	}
+}
+
+// PrintStatus prints the drift report, machine-readable formats print it as a single JSON document
+func (s *OutputService) PrintStatus(report *models.StatusReport) {
+	switch s.format {
+	case models.OutputNDJSON:
+		s.printJSON(report, "")
+	case models.OutputJSON:
+		s.printJSON(report, "  ")
+	default:
+		labels := map[models.DriftType]string{
+			models.DriftOnlyInProject: s.colorize(colorRed, "only in project:  "),
+			models.DriftOnlyInCentral: s.colorize(colorGreen, "only in central:  "),
+			models.DriftModified:      s.colorize(colorYellow, "modified:         "),
+			models.DriftHeaderOnly:    "header differs:   ",
+		}
+		for _, file := range report.Files {
+			fmt.Fprintf(s.stdout, "%s%s\n", labels[file.Drift], file.RelativePath)
+		}
+
+		if report.InSync {
+			s.PrintSuccess("In sync")
+			return
+		}
+		s.PrintWarning("Drift detected between project and central rules")
+	}
+}
+
+// PrintLintReport prints the diagnostics of a lint run, machine-readable formats print the report as a single document
+func (s *OutputService) PrintLintReport(report *models.LintReport) {
+	switch s.format {
+	case models.OutputNDJSON:
+		s.printJSON(report, "")
+	case models.OutputJSON:
+		s.printJSON(report, "  ")
+	case models.OutputSARIF:
+		s.printJSON(newSARIFLog(report), "  ")
+	default:
+		for _, diagnostic := range report.Diagnostics {
+			fmt.Fprintln(s.stdout, s.formatDiagnostic(diagnostic))
+		}
+
+		if len(report.Diagnostics) == 0 {
+			s.PrintSuccess(fmt.Sprintf("No problems found in %d rule(s)", report.Files))
+			return
+		}
+		s.PrintWarningf("%d error(s), %d warning(s) in %d rule(s)", report.Errors, report.Warnings, report.Files)
+	}
+}
+
+// PrintLintDiagnostics prints lint diagnostics to stderr, so they don't mix with the output of the command being gated
+func (s *OutputService) PrintLintDiagnostics(report *models.LintReport) {
+	for _, diagnostic := range report.Diagnostics {
+		s.PrintError(s.formatDiagnostic(diagnostic))
+	}
+}
+
+// formatDiagnostic renders a diagnostic as path:line: severity: message (rule)
+func (s *OutputService) formatDiagnostic(diagnostic models.LintDiagnostic) string {
+	color := colorYellow
+	if diagnostic.Severity == models.SeverityError {
+		color = colorRed
+	}
+	return fmt.Sprintf("%s:%d: %s: %s (%s)", diagnostic.Path, diagnostic.Line,
+		s.colorize(color, string(diagnostic.Severity)), diagnostic.Message, diagnostic.Rule)
+}
+
+// PrintSummary prints the number of added, updated and deleted files
+func (s *OutputService) PrintSummary(summary models.SyncSummary) {
+	if summary.Added+summary.Updated+summary.Deleted == 0 {
+		s.PrintInfo("Already in sync")
+		return
+	}
+	s.PrintInfo(fmt.Sprintf("%d added, %d updated, %d deleted", summary.Added, summary.Updated, summary.Deleted))
+}
+
 // PrintSuccess prints a success message
 func (s *OutputService) PrintSuccess(message string) {
-	s.PrintInfo("\033[32m" + message + "\033[0m")
+	s.PrintInfo(s.colorize(colorGreen, message))
 }
 
 // PrintWarning prints a warning message
 func (s *OutputService) PrintWarning(message string) {
-	s.PrintError("\033[33m" + message + "\033[0m")
+	s.PrintError(s.colorize(colorYellow, message))
 }
 
 // PrintWarningf prints a formatted warning message
 func (s *OutputService) PrintWarningf(format string, args ...interface{}) {
-	fmt.Fprintf(s.stderr, "\033[33m"+format+"\033[0m\n", args...)
+	fmt.Fprintf(s.stderr, s.colorize(colorYellow, format)+"\n", args...)
+}
+
+// PrintCommandError prints the error that ended a command
+func (s *OutputService) PrintCommandError(err error) {
+	message := fmt.Sprintf("Error: %v", err)
+	s.printFatalEvent(message)
+	s.PrintError(s.colorize(colorRed, message))
 }
 
-// PrintFatal prints a fatal error and exits
-func (s *OutputService) PrintFatal(message string) {
-	s.PrintError("\033[31m" + message + "\033[0m")
-	os.Exit(1)
+// printFatalEvent reports a command error on stdout so JSON consumers always get a document
+func (s *OutputService) printFatalEvent(message string) {
+	switch s.format {
+	case models.OutputNDJSON:
+		s.printEvent(models.OutputEvent{Event: models.EventError, Error: message})
+	case models.OutputJSON:
+		s.printJSON(models.OutputEvent{Event: models.EventError, Error: message}, "  ")
+	}
 }
 
-// PrintFatalf prints a formatted fatal error and exits
-func (s *OutputService) PrintFatalf(format string, args ...interface{}) {
-	s.PrintErrorf("\033[31m"+format+"\033[0m", args...)
-	os.Exit(1)
+// printEvent writes a single NDJSON line
+func (s *OutputService) printEvent(event models.OutputEvent) {
+	s.printJSON(event, "")
+}
+
+// printJSON encodes a value to stdout, indent is empty for compact output
+func (s *OutputService) printJSON(value interface{}, indent string) {
+	encoder := json.NewEncoder(s.stdout)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(value); err != nil {
+		s.PrintErrorf("Error encoding output: %v", err)
+	}
 }
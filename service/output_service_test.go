@@ -0,0 +1,89 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestMachineReadableOutput(t *testing.T) {
+	operation := models.FileOperation{
+		Type:         models.OperationAdd,
+		RelativePath: "go-test.mdc",
+		Reason:       reasonNewInSource,
+		Merged:       true,
+		MergedBody:   "merged rule body",
+	}
+	result := &models.SyncResult{
+		Direction:  models.DirectionPush,
+		Operations: []models.FileOperation{operation},
+		Errors:     []models.FileError{{RelativePath: "broken.mdc", Error: "permission denied"}},
+		HasChanges: true,
+		Summary:    models.SyncSummary{Added: 1},
+		CommitSHA:  "abc123",
+		Pushed:     true,
+	}
+
+	tests := []struct {
+		format        string
+		expectedLines int
+		description   string
+	}{
+		{
+			format:        "json",
+			expectedLines: 1,
+			description:   "JSON should print a single document",
+		},
+		{
+			format:        "ndjson",
+			expectedLines: 2,
+			description:   "NDJSON should stream operation and result events",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			outputService := NewOutputServiceWithWriters(&stdout, &stderr)
+			if err := outputService.SetFormat(test.format); err != nil {
+				t.Fatalf("Unexpected error setting format: %v", err)
+			}
+
+			outputService.PrintFileOperation(operation, "rules")
+			outputService.PrintWarning("warning")
+			outputService.PrintResult(result)
+
+			if strings.Contains(stdout.String(), "\033[") || strings.Contains(stderr.String(), "\033[") {
+				t.Errorf("Output should not contain colour codes")
+			}
+			if strings.Contains(stdout.String(), "merged rule body") {
+				t.Errorf("Output should not contain file bodies, got %s", stdout.String())
+			}
+
+			var documents []json.RawMessage
+			decoder := json.NewDecoder(bufio.NewReader(&stdout))
+			for decoder.More() {
+				var document json.RawMessage
+				if err := decoder.Decode(&document); err != nil {
+					t.Fatalf("Output is not valid JSON: %v", err)
+				}
+				documents = append(documents, document)
+			}
+
+			if len(documents) != test.expectedLines {
+				t.Fatalf("Expected %d JSON documents, got %d", test.expectedLines, len(documents))
+			}
+
+			last := string(documents[len(documents)-1])
+			for _, expected := range []string{`"commit_sha"`, `"broken.mdc"`, `"pushed"`} {
+				if !strings.Contains(last, expected) {
+					t.Errorf("Expected %s in result document %s", expected, last)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+	"gopkg.in/yaml.v3"
+)
+
+// rulesOverridesFileName is the project file, next to the rules directory, holding header overrides for pulled rules
+const rulesOverridesFileName = "rules-overrides.yaml"
+
+// loadRuleOverrides reads the rule overrides of a project, keyed by relative path with OS separators.
+// A project without an overrides file has no overrides.
+func (s *SyncService) loadRuleOverrides(projectRoot string) (map[string]models.RuleOverride, error) {
+	overridesPath := filepath.Join(projectRoot, cursorDirName, rulesOverridesFileName)
+	content, err := s.fileSystem.ReadFile(overridesPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rule overrides %s: %w", overridesPath, err)
+	}
+
+	overrides := &models.RuleOverrides{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(overrides); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid rule overrides %s: %w", overridesPath, err)
+	}
+
+	byPath := make(map[string]models.RuleOverride, len(overrides.Rules))
+	for rulePath, override := range overrides.Rules {
+		cleanPath := path.Clean(filepath.ToSlash(rulePath))
+		if path.IsAbs(cleanPath) || cleanPath == ".." || strings.HasPrefix(cleanPath, "../") || path.Ext(cleanPath) != mdcExtension {
+			return nil, fmt.Errorf("invalid rule overrides %s: %s is not an .mdc rule inside %s/%s", overridesPath, rulePath, cursorDirName, rulesDirName)
+		}
+		if err := s.fileFilterService.ValidatePatterns(override.Globs); err != nil {
+			return nil, fmt.Errorf("invalid rule overrides %s: globs of %s: %w", overridesPath, rulePath, err)
+		}
+		byPath[filepath.FromSlash(cleanPath)] = override
+	}
+	return byPath, nil
+}
+
+// applyRuleOverride sets the overridden fields in a header, a missing header is created
+func applyRuleOverride(header string, override models.RuleOverride) (string, error) {
+	if header == "" {
+		header = headerSeparator + "\n" + headerSeparator + "\n"
+	}
+	frontmatter, _, err := ParseFrontmatter(header)
+	if err != nil {
+		return "", err
+	}
+
+	if override.Globs != nil {
+		if err := frontmatter.Set(frontmatterGlobs, override.Globs); err != nil {
+			return "", err
+		}
+	}
+	if override.AlwaysApply != nil {
+		if err := frontmatter.Set(frontmatterAlwaysApply, *override.AlwaysApply); err != nil {
+			return "", err
+		}
+	}
+	return frontmatter.String(), nil
+}
+
+// warnUnmatchedOverrides warns about overrides for rules the pull doesn't sync, they are most likely stale
+func (s *SyncService) warnUnmatchedOverrides(plan *models.SyncPlan, sourceFiles []string) {
+	if len(plan.Options.RuleOverrides) == 0 {
+		return
+	}
+
+	relativePaths := make([]string, 0, len(plan.Options.RuleOverrides))
+	for relativePath := range plan.Options.RuleOverrides {
+		relativePaths = append(relativePaths, relativePath)
+	}
+	sort.Strings(relativePaths)
+
+	pulled := make(map[string]bool, len(sourceFiles))
+	for _, sourceFile := range sourceFiles {
+		if relativePath, err := s.GetRelativePath(sourceFile, plan.SourceDir); err == nil {
+			pulled[relativePath] = true
+		}
+	}
+	for _, relativePath := range relativePaths {
+		if !pulled[relativePath] {
+			s.outputService.PrintWarningf("Override for %s in %s matches no pulled rule", relativePath, rulesOverridesFileName)
+		}
+	}
+}
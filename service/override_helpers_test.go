@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestLoadRuleOverrides(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+
+	tests := []struct {
+		content     string
+		expected    map[string]int // Number of globs by relative path
+		expectedErr bool
+		description string
+	}{
+		{
+			content:     "rules:\n  lang/go.mdc:\n    globs: [\"*.go\", \"cmd/**/*.go\"]\n    alwaysApply: false\n  ./style.mdc:\n    alwaysApply: true\n",
+			expected:    map[string]int{filepath.Join("lang", "go.mdc"): 2, "style.mdc": 0},
+			description: "Overrides should be keyed by cleaned relative path",
+		},
+		{
+			content:     "",
+			expected:    map[string]int{},
+			description: "Empty file should have no overrides",
+		},
+		{
+			content:     "rules:\n  go.mdc:\n    description: Local\n",
+			expectedErr: true,
+			description: "Keys other than globs and alwaysApply should be rejected",
+		},
+		{
+			content:     "rules:\n  ../go.mdc:\n    alwaysApply: true\n",
+			expectedErr: true,
+			description: "Paths outside the rules directory should be rejected",
+		},
+		{
+			content:     "rules:\n  go.mdc:\n    globs: [\"src/[.go\"]\n",
+			expectedErr: true,
+			description: "Malformed globs should be rejected",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			fileSystem := NewMemoryFileSystem()
+			writeFileSystemFiles(t, fileSystem, filepath.Join(projectDir, cursorDirName), map[string]string{rulesOverridesFileName: test.content})
+			syncService := NewSyncService(NewOutputService(), NewExecGitClient(), fileSystem)
+
+			overrides, err := syncService.loadRuleOverrides(projectDir)
+			if test.expectedErr {
+				if err == nil {
+					t.Errorf("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(overrides) != len(test.expected) {
+				t.Fatalf("Expected overrides for %v, got %+v", test.expected, overrides)
+			}
+			for relativePath, globs := range test.expected {
+				override, ok := overrides[relativePath]
+				if !ok || len(override.Globs) != globs {
+					t.Errorf("Expected an override for %s with %d globs, got %+v", relativePath, globs, overrides)
+				}
+			}
+		})
+	}
+}
+
+func TestPullRulesWithOverrides(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	projectRulesDir := filepath.Join(projectDir, cursorDirName, rulesDirName)
+	centralDir := filepath.Join(string(filepath.Separator), "central")
+	rulesDir := filepath.Join(centralDir, rulesDirName)
+	overrides := "rules:\n  go.mdc:\n    globs: [\"*.go\", \"internal/**/*.go\"]\n  plain.mdc:\n    alwaysApply: true\n  removed.mdc:\n    alwaysApply: true\n"
+
+	tests := []struct {
+		project         map[string]string
+		options         models.SyncOptions
+		expectedProject map[string]string
+		description     string
+	}{
+		{
+			expectedProject: map[string]string{
+				"go.mdc":    "---\ndescription: Go style\nglobs: *.go,internal/**/*.go\nalwaysApply: false\n---\nUse gofmt.\n",
+				"plain.mdc": "---\nalwaysApply: true\n---\nPlain\n",
+			},
+			description: "Overrides should be applied to new rules, creating missing headers",
+		},
+		{
+			project: map[string]string{
+				"go.mdc":    "---\ndescription: Local\nglobs: *.go\nalwaysApply: false\n---\nUse gofmt.\n",
+				"plain.mdc": "Plain\n",
+			},
+			expectedProject: map[string]string{
+				"go.mdc":    "---\ndescription: Local\nglobs: *.go,internal/**/*.go\nalwaysApply: false\n---\nUse gofmt.\n",
+				"plain.mdc": "---\nalwaysApply: true\n---\nPlain\n",
+			},
+			description: "Overrides should be applied on top of preserved headers when only the header differs",
+		},
+		{
+			project: map[string]string{
+				"go.mdc":    "---\ndescription: Local\nglobs: *.go,internal/**/*.go\nalwaysApply: false\n---\nUse gofmt.\n",
+				"plain.mdc": "---\nalwaysApply: true\n---\nPlain\n",
+			},
+			options: models.SyncOptions{OverwriteHeaders: true},
+			expectedProject: map[string]string{
+				"go.mdc":    "---\ndescription: Go style\nglobs: *.go,internal/**/*.go\nalwaysApply: false\n---\nUse gofmt.\n",
+				"plain.mdc": "---\nalwaysApply: true\n---\nPlain\n",
+			},
+			description: "Overrides should survive overwritten headers",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			fileSystem := NewMemoryFileSystem()
+			if err := fileSystem.MkdirAll(projectRulesDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			writeFileSystemFiles(t, fileSystem, filepath.Join(projectDir, cursorDirName), map[string]string{rulesOverridesFileName: overrides})
+			writeFileSystemFiles(t, fileSystem, projectRulesDir, test.project)
+			writeFileSystemFiles(t, fileSystem, rulesDir, map[string]string{
+				"go.mdc":    "---\ndescription: Go style\nglobs: *.go\nalwaysApply: false\n---\nUse gofmt.\n",
+				"plain.mdc": "Plain\n",
+			})
+			git, err := newFakeGitClient(fileSystem, projectDir, centralDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stderr), git, fileSystem)
+			options := test.options
+			options.RulesDir, options.ProjectDir = rulesDir, projectDir
+
+			if _, err := syncService.PullRules(&options); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			projectFiles := readFileSystemFiles(t, fileSystem, projectRulesDir)
+			delete(projectFiles, syncStateFileName)
+			assertFiles(t, "project", test.expectedProject, projectFiles)
+			if !strings.Contains(stderr.String(), "Override for removed.mdc") {
+				t.Errorf("Expected a warning about the unmatched override, got %q", stderr.String())
+			}
+
+			// A second pull has nothing left to change and status reports no drift
+			stdout.Reset()
+			result, err := syncService.PullRules(&options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(result.Operations) != 0 {
+				t.Errorf("Expected no operations on the second pull, got %+v", result.Operations)
+			}
+			report, err := syncService.Status(&options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !report.InSync {
+				t.Errorf("Expected overridden rules to be in sync, got %+v", report.Files)
+			}
+		})
+	}
+}
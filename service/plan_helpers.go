@@ -0,0 +1,376 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+const (
+	reasonNewInSource     = "new in source"
+	reasonContentDiffers  = "content differs"
+	reasonHeaderDiffers   = "header differs"
+	reasonFormatDiffers   = "line endings or trailing whitespace differ"
+	reasonCompareFailed   = "comparison failed"
+	reasonNotInSource     = "not in source"
+	reasonMerged          = "merged changes from both sides"
+	reasonConflictMarkers = "conflict markers written"
+)
+
+// syncScope holds the directories and filters shared by planning and status checks
+type syncScope struct {
+	rulesDir        string
+	projectRoot     string
+	projectRulesDir string
+	patterns        []string
+	ignorePatterns  []models.IgnorePattern
+	remote          *remoteRules // Set when the rules dir is a git URL served from a cache clone
+	ruleOverrides   map[string]models.RuleOverride
+}
+
+// resolveSyncScope resolves the central rules directory, the project and the file filters from options.
+// With update a local rules repository is fetched and fast-forwarded first, unless NoFetch is set.
+func (s *SyncService) resolveSyncScope(options *models.SyncOptions, update bool) (*syncScope, error) {
+	if err := s.validateHeaderOptions(options); err != nil {
+		return nil, err
+	}
+	if err := validateLineEndingPolicy(options.LineEndings); err != nil {
+		return nil, err
+	}
+
+	rulesDir, err := s.GetRulesSourceDir(options.RulesDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rules source dir: %w", err)
+	}
+
+	currentDir := options.ProjectDir
+	if currentDir == "" {
+		if currentDir, err = os.Getwd(); err != nil {
+			return nil, fmt.Errorf("failed to get current directory: %w", err)
+		}
+	}
+
+	projectRoot, err := s.git.Root(currentDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find git root: %w", err)
+	}
+
+	scope := &syncScope{
+		rulesDir:        rulesDir,
+		projectRoot:     projectRoot,
+		projectRulesDir: filepath.Join(projectRoot, cursorDirName, rulesDirName),
+	}
+
+	// A git URL is synced through a cache clone that is fetched first, the cache belongs to the syncer
+	// so it is fetched even when nothing else is modified
+	if url, ref, isURL := parseGitURL(rulesDir); isURL {
+		scope.remote, err = s.prepareRemoteRules(url, ref, options.NoFetch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare rules repository %s: %w", url, err)
+		}
+		scope.rulesDir = scope.remote.dir
+	} else if update && !options.NoFetch {
+		if err := s.updateRulesRepo(rulesDir); err != nil {
+			return nil, fmt.Errorf("failed to update rules repository: %w", err)
+		}
+	}
+
+	scope.ruleOverrides, err = s.loadRuleOverrides(projectRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get file patterns for filtering
+	filePatterns, err := s.fileFilterService.GetFilePatterns(options.FilePatterns, cursorRulesPatternsEnvVar)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file patterns: %w", err)
+	}
+	scope.patterns = s.fileFilterService.GetEffectivePatterns(filePatterns)
+
+	// Files excluded by .ruleignore and --ignore-files
+	scope.ignorePatterns, err = s.getIgnorePatterns(scope.projectRulesDir, scope.rulesDir, options.IgnoreFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ignore patterns: %w", err)
+	}
+
+	return scope, nil
+}
+
+// findSourceFiles finds files in the source directory, filtered by patterns when any are given
+func (s *SyncService) findSourceFiles(sourceDir string, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		// No patterns specified - get all files
+		files, err := findAllFiles(s.fileSystem, sourceDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find source files in %s: %w", sourceDir, err)
+		}
+		return files, nil
+	}
+
+	// Use pattern filtering
+	files, err := s.fileFilterService.FindFilesByPatterns(sourceDir, patterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find files by patterns in %s: %w", sourceDir, err)
+	}
+	return files, nil
+}
+
+// planDeletes appends delete operations for destination files that don't exist in source.
+// Only destination files matching the patterns are considered, ignored files are never deleted.
+// A tracked file modified in the destination since the last sync is reported as a conflict instead.
+func (s *SyncService) planDeletes(plan *models.SyncPlan, srcFiles []string, patterns []string, ignorePatterns []models.IgnorePattern, state *models.SyncState) error {
+	// Build map of source files by relative path
+	srcFilesMap := make(map[string]bool)
+	for _, srcFile := range srcFiles {
+		relativePath, err := s.GetRelativePath(srcFile, plan.SourceDir)
+		if err != nil {
+			continue
+		}
+		srcFilesMap[relativePath] = true
+	}
+
+	// A destination that does not exist yet has nothing to delete
+	if _, statErr := s.fileSystem.Stat(plan.TargetDir); os.IsNotExist(statErr) {
+		return nil
+	}
+
+	destFiles, err := findAllFiles(s.fileSystem, plan.TargetDir)
+	if err != nil {
+		return err
+	}
+	destFiles = s.fileFilterService.FilterFilesByPatterns(destFiles, plan.TargetDir, patterns)
+	destFiles = s.fileFilterService.FilterIgnoredFiles(destFiles, plan.TargetDir, ignorePatterns)
+
+	for _, destFile := range destFiles {
+		relativePath, err := s.GetRelativePath(destFile, plan.TargetDir)
+		if err != nil || srcFilesMap[relativePath] {
+			continue
+		}
+
+		stateKey := filepath.ToSlash(relativePath)
+		if entry, tracked := state.Files[stateKey]; tracked && !plan.Options.NoMerge {
+			body, err := s.readBody(destFile)
+			if err == nil && hashContent(body) != entry.Hash {
+				s.outputService.PrintErrorf("Conflict in %s: modified since last sync but deleted in source", relativePath)
+				plan.Errors = append(plan.Errors, models.FileError{
+					Type:         models.OperationDelete,
+					RelativePath: relativePath,
+					Error:        "modified since last sync but deleted in source",
+				})
+				continue
+			}
+		}
+
+		delete(plan.NextState.Files, stateKey)
+		plan.Operations = append(plan.Operations, models.FileOperation{
+			Type:         models.OperationDelete,
+			TargetPath:   destFile,
+			RelativePath: relativePath,
+			Reason:       reasonNotInSource,
+		})
+	}
+
+	return nil
+}
+
+// planCopies appends add and update operations for source files that are missing or differ in destination,
+// and errors for files that could not be planned. Files tracked in the state manifest that changed on both
+// sides are merged, files changed only in the destination are left alone.
+func (s *SyncService) planCopies(plan *models.SyncPlan, srcFiles []string, state *models.SyncState) {
+	for _, srcFileFullPath := range srcFiles {
+		dstFileFullPath, err := s.GetDestinationPath(srcFileFullPath, plan.SourceDir, plan.TargetDir)
+		if err != nil {
+			s.outputService.PrintErrorf("Error resolving destination path for %s: %v\n", srcFileFullPath, err)
+			plan.Errors = append(plan.Errors, models.FileError{RelativePath: srcFileFullPath, Error: err.Error()})
+			continue
+		}
+
+		// Get relative path for display
+		relativePath, err := s.GetRelativePath(srcFileFullPath, plan.SourceDir)
+		if err != nil {
+			relativePath = filepath.Base(srcFileFullPath)
+		}
+		stateKey := filepath.ToSlash(relativePath)
+
+		srcBody, err := s.readBody(srcFileFullPath)
+		if err != nil {
+			s.outputService.PrintErrorf("Error reading source file %s: %v\n", relativePath, err)
+			plan.Errors = append(plan.Errors, models.FileError{RelativePath: relativePath, Error: err.Error()})
+			continue
+		}
+
+		operation := models.FileOperation{
+			Type:         models.OperationUpdate,
+			SourcePath:   srcFileFullPath,
+			TargetPath:   dstFileFullPath,
+			RelativePath: relativePath,
+		}
+
+		if _, statErr := s.fileSystem.Stat(dstFileFullPath); os.IsNotExist(statErr) {
+			operation.Type = models.OperationAdd
+			operation.Reason = reasonNewInSource
+			plan.Operations = append(plan.Operations, operation)
+			plan.NextState.Files[stateKey] = newSyncStateEntry(srcBody)
+			continue
+		} else if statErr != nil {
+			s.outputService.PrintErrorf("Error checking destination file %s: %v\n", relativePath, statErr)
+			plan.Errors = append(plan.Errors, models.FileError{RelativePath: relativePath, Error: statErr.Error()})
+			continue
+		}
+
+		// Check if files are different before copying
+		equal, err := s.filesAreEqualBasedOnExtension(srcFileFullPath, dstFileFullPath, relativePath, plan.Options)
+		if err != nil {
+			s.outputService.PrintErrorf("Error comparing files %s: %v\n", relativePath, err)
+			// Continue with copying in case of comparison error
+			operation.Reason = fmt.Sprintf("%s: %v", reasonCompareFailed, err)
+			plan.Operations = append(plan.Operations, operation)
+			plan.NextState.Files[stateKey] = newSyncStateEntry(srcBody)
+			continue
+		}
+		if equal {
+			plan.NextState.Files[stateKey] = newSyncStateEntry(srcBody)
+			continue // Files are identical, no need to copy
+		}
+
+		if entry, tracked := state.Files[stateKey]; tracked && !plan.Options.NoMerge {
+			dstBody, err := s.readBody(dstFileFullPath)
+			if err != nil {
+				s.outputService.PrintErrorf("Error reading destination file %s: %v\n", relativePath, err)
+				plan.Errors = append(plan.Errors, models.FileError{RelativePath: relativePath, Error: err.Error()})
+				continue
+			}
+
+			srcChanged := hashContent(srcBody) != entry.Hash
+			dstChanged := hashContent(dstBody) != entry.Hash
+			if srcBody != dstBody && dstChanged {
+				if !srcChanged {
+					continue // Only the destination changed since the last sync, keep it
+				}
+
+				if !s.planMerge(plan, &operation, entry.Base, dstBody, srcBody) {
+					continue
+				}
+				plan.Operations = append(plan.Operations, operation)
+				plan.NextState.Files[stateKey] = newSyncStateEntry(srcBody)
+				continue
+			}
+		}
+
+		operation.Reason = s.updateReason(srcFileFullPath, dstFileFullPath, relativePath, plan.Options)
+		plan.Operations = append(plan.Operations, operation)
+		plan.NextState.Files[stateKey] = newSyncStateEntry(srcBody)
+	}
+}
+
+// updateReason explains why an existing file differs from what syncing would write to it
+func (s *SyncService) updateReason(srcPath, dstPath, relativePath string, options models.SyncOptions) string {
+	expected, err := s.syncedContent(srcPath, dstPath, relativePath, options)
+	if err != nil {
+		return reasonContentDiffers
+	}
+	actual, err := s.readFileNormalized(dstPath)
+	if err != nil {
+		return reasonContentDiffers
+	}
+	if normalizeContent(string(expected)) == actual {
+		return reasonFormatDiffers
+	}
+
+	_, overridden := options.RuleOverrides[relativePath]
+	if (options.OverwriteHeaders || options.MergeHeaders || overridden) && filepath.Ext(srcPath) == mdcExtension {
+		if bodiesEqual, bodyErr := s.filesAreEqualNormalizedWithoutHeaders(srcPath, dstPath); bodyErr == nil && bodiesEqual {
+			return reasonHeaderDiffers
+		}
+	}
+	return reasonContentDiffers
+}
+
+// planMerge performs a three-way merge of both bodies into the operation.
+// It returns false and records an error when the merge conflicts and conflict markers are not allowed.
+func (s *SyncService) planMerge(plan *models.SyncPlan, operation *models.FileOperation, base, dstBody, srcBody string) bool {
+	targetLabel, sourceLabel := mergeLabels(plan)
+	merged, conflict := mergeThreeWay(base, dstBody, srcBody, targetLabel, sourceLabel)
+	if conflict && !plan.Options.ConflictMarkers {
+		s.outputService.PrintErrorf("Conflict in %s: changed in %s and %s since last sync", operation.RelativePath, targetLabel, sourceLabel)
+		plan.Errors = append(plan.Errors, models.FileError{
+			Type:         models.OperationUpdate,
+			RelativePath: operation.RelativePath,
+			Error:        fmt.Sprintf("conflicting changes in %s and %s since last sync", targetLabel, sourceLabel),
+		})
+		return false
+	}
+
+	operation.Merged = true
+	operation.MergedBody = merged
+	operation.Reason = reasonMerged
+	if conflict {
+		operation.Reason = reasonConflictMarkers
+	}
+	return true
+}
+
+// newSyncResult creates an empty result for a plan, carrying over errors found while planning
+func (s *SyncService) newSyncResult(plan *models.SyncPlan) *models.SyncResult {
+	return &models.SyncResult{
+		Direction:  plan.Direction,
+		DryRun:     plan.Options.DryRun,
+		Operations: []models.FileOperation{},
+		Errors:     append([]models.FileError{}, plan.Errors...),
+		HasChanges: false,
+	}
+}
+
+// planFailures returns the files that failed to plan as FileSyncErrors
+func planFailures(plan *models.SyncPlan) []*FileSyncError {
+	var failures []*FileSyncError
+	for _, fileError := range plan.Errors {
+		failures = append(failures, &FileSyncError{
+			Type:         fileError.Type,
+			RelativePath: fileError.RelativePath,
+			Err:          errors.New(fileError.Error),
+		})
+	}
+	return failures
+}
+
+// recordFailure reports an operation that failed to apply, records it in the result errors and returns it as a FileSyncError
+func (s *SyncService) recordFailure(result *models.SyncResult, operation models.FileOperation, err error) *FileSyncError {
+	s.outputService.PrintErrorf("Error synchronizing file %s: %v\n", operation.RelativePath, err)
+	result.Errors = append(result.Errors, models.FileError{
+		Type:         operation.Type,
+		RelativePath: operation.RelativePath,
+		Error:        err.Error(),
+	})
+	return &FileSyncError{Type: operation.Type, RelativePath: operation.RelativePath, Err: err}
+}
+
+// recordOperation appends an operation to the result and updates the summary counters
+func (s *SyncService) recordOperation(result *models.SyncResult, operation models.FileOperation) {
+	result.Operations = append(result.Operations, operation)
+	result.HasChanges = true
+
+	switch operation.Type {
+	case models.OperationAdd:
+		result.Summary.Added++
+	case models.OperationUpdate:
+		result.Summary.Updated++
+	case models.OperationDelete:
+		result.Summary.Deleted++
+	}
+}
+
+// printOperation prints a planned or applied operation, pushes also name the target directory
+func (s *SyncService) printOperation(plan *models.SyncPlan, operation models.FileOperation) {
+	target := ""
+	if plan.Direction == models.DirectionPush && operation.Type != models.OperationDelete {
+		target = plan.TargetName
+		if target == "" {
+			target = filepath.Base(plan.TargetDir)
+		}
+	}
+	s.outputService.PrintFileOperation(operation, target)
+}
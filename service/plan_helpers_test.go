@@ -0,0 +1,117 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestPlanOperations(t *testing.T) {
+	outputService := NewOutputService()
+	syncService := NewSyncService(outputService, NewExecGitClient(), NewOSFileSystem())
+
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	writeFileSystemFiles(t, NewOSFileSystem(), srcDir, map[string]string{
+		"new.mdc":    "new rule\n",
+		"same.mdc":   "---\ndescription: src\n---\nsame body\n",
+		"changed.md": "changed\n",
+		"header.mdc": "---\ndescription: src\n---\nbody\n",
+	})
+
+	writeFileSystemFiles(t, NewOSFileSystem(), dstDir, map[string]string{
+		"same.mdc":       "---\ndescription: dst\n---\nsame body\n",
+		"changed.md":     "original\n",
+		"header.mdc":     "---\ndescription: dst\n---\nbody\n",
+		"extra.mdc":      "extra\n",
+		"kept/local.mdc": "local\n",
+	})
+
+	srcFiles, err := findAllFiles(syncService.fileSystem, srcDir)
+	if err != nil {
+		t.Fatalf("Failed to list source files: %v", err)
+	}
+
+	ignorePatterns, err := syncService.fileFilterService.CompileIgnorePatterns([]string{"kept/"})
+	if err != nil {
+		t.Fatalf("Failed to compile patterns: %v", err)
+	}
+
+	emptyState := &models.SyncState{Files: map[string]models.SyncStateEntry{}}
+	newPlan := func(overwriteHeaders bool) *models.SyncPlan {
+		return &models.SyncPlan{
+			Direction: models.DirectionPull,
+			SourceDir: srcDir,
+			TargetDir: dstDir,
+			Options:   models.SyncOptions{OverwriteHeaders: overwriteHeaders},
+			NextState: copySyncState(emptyState),
+		}
+	}
+
+	deletePlan := newPlan(false)
+	if err := syncService.planDeletes(deletePlan, srcFiles, nil, ignorePatterns, emptyState); err != nil {
+		t.Fatalf("Unexpected error planning deletes: %v", err)
+	}
+
+	preservingPlan := newPlan(false)
+	syncService.planCopies(preservingPlan, srcFiles, emptyState)
+	if len(preservingPlan.Errors) > 0 {
+		t.Fatalf("Unexpected errors planning copies: %v", preservingPlan.Errors)
+	}
+
+	overwritingPlan := newPlan(true)
+	syncService.planCopies(overwritingPlan, srcFiles, emptyState)
+	if len(overwritingPlan.Errors) > 0 {
+		t.Fatalf("Unexpected errors planning copies: %v", overwritingPlan.Errors)
+	}
+
+	tests := []struct {
+		operations  []models.FileOperation
+		expected    map[string]string
+		description string
+	}{
+		{
+			operations:  deletePlan.Operations,
+			expected:    map[string]string{"extra.mdc": reasonNotInSource},
+			description: "Should delete extra files and keep ignored ones",
+		},
+		{
+			operations: preservingPlan.Operations,
+			expected: map[string]string{
+				"new.mdc":    reasonNewInSource,
+				"changed.md": reasonContentDiffers,
+			},
+			description: "Should skip header-only differences when preserving headers",
+		},
+		{
+			operations: overwritingPlan.Operations,
+			expected: map[string]string{
+				"new.mdc":    reasonNewInSource,
+				"changed.md": reasonContentDiffers,
+				"same.mdc":   reasonHeaderDiffers,
+				"header.mdc": reasonHeaderDiffers,
+			},
+			description: "Should report header-only differences when overwriting headers",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if len(test.operations) != len(test.expected) {
+				t.Fatalf("Expected %d operations, got %d: %+v", len(test.expected), len(test.operations), test.operations)
+			}
+
+			for _, operation := range test.operations {
+				reason, ok := test.expected[operation.RelativePath]
+				if !ok {
+					t.Errorf("Unexpected operation for %s", operation.RelativePath)
+					continue
+				}
+				if operation.Reason != reason {
+					t.Errorf("File %s: expected reason %q, got %q", operation.RelativePath, reason, operation.Reason)
+				}
+			}
+		})
+	}
+}
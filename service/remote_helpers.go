@@ -0,0 +1,129 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	cacheDirName  = "cursor-rules-syncer"
+	reposDirName  = "repos"
+	gitRefDivider = "#"
+)
+
+// gitURLPrefixes lists URL schemes accepted as a remote rules repository
+var gitURLPrefixes = []string{"https://", "http://", "ssh://", "git://", "file://"}
+
+// scpLikeGitURLRegex matches scp-like git URLs such as git@github.com:org/rules.git
+var scpLikeGitURLRegex = regexp.MustCompile(`^[\w.-]+@[\w.-]+:`)
+
+// remoteRules describes a rules directory backed by a cache clone of a remote repository
+type remoteRules struct {
+	url      string
+	ref      string
+	dir      string
+	onBranch bool
+}
+
+// parseGitURL splits a rules dir value of the form <url>[#ref] into URL and ref.
+// The last return value is false when the value is a local path.
+func parseGitURL(value string) (string, string, bool) {
+	isURL := scpLikeGitURLRegex.MatchString(value)
+	for _, prefix := range gitURLPrefixes {
+		if strings.HasPrefix(value, prefix) {
+			isURL = true
+			break
+		}
+	}
+	if !isURL {
+		return "", "", false
+	}
+
+	url, ref, _ := strings.Cut(value, gitRefDivider)
+	return url, ref, true
+}
+
+// getRulesCacheDir returns the cache clone location for a repository URL under the user cache dir
+func getRulesCacheDir(url string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user cache dir: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(url))
+	name := strings.TrimSuffix(filepath.Base(strings.TrimRight(url, "/")), ".git")
+	return filepath.Join(cacheDir, cacheDirName, reposDirName, hex.EncodeToString(sum[:])[:16]+"-"+name), nil
+}
+
+// prepareRemoteRules clones the repository into the cache or fetches it when already cloned,
+// then checks out the requested ref. Without a ref the remote default branch is used.
+// With noFetch an existing clone is used as it is, for offline use.
+func (s *SyncService) prepareRemoteRules(url, ref string, noFetch bool) (*remoteRules, error) {
+	cacheDir, err := getRulesCacheDir(url)
+	if err != nil {
+		return nil, err
+	}
+	remote := &remoteRules{url: url, ref: ref, dir: cacheDir}
+
+	if _, statErr := s.fileSystem.Stat(filepath.Join(cacheDir, gitDirName)); errors.Is(statErr, fs.ErrNotExist) {
+		s.outputService.PrintInfo(fmt.Sprintf("Cloning rules from %s", url))
+		if err := s.fileSystem.MkdirAll(filepath.Dir(cacheDir), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("failed to create cache dir: %w", err)
+		}
+		if err := s.git.Clone(url, cacheDir); err != nil {
+			s.fileSystem.RemoveAll(cacheDir)
+			return nil, err
+		}
+	} else if !noFetch {
+		s.outputService.PrintInfo(fmt.Sprintf("Fetching rules from %s", url))
+		if err := s.git.Fetch(cacheDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// The cache belongs to the syncer, leftovers of an interrupted sync are discarded
+	if err := s.git.DiscardChanges(cacheDir); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkoutRemoteRef(remote); err != nil {
+		return nil, err
+	}
+	return remote, nil
+}
+
+// checkoutRemoteRef checks out a branch tracking origin, or detaches at a tag or commit
+func (s *SyncService) checkoutRemoteRef(remote *remoteRules) error {
+	ref := remote.ref
+	if ref == "" {
+		branch, err := s.git.DefaultBranch(remote.dir)
+		if err != nil {
+			return fmt.Errorf("failed to find default branch of %s: %w", remote.url, err)
+		}
+		ref = branch
+	}
+
+	if !s.git.RefExists(remote.dir, "refs/remotes/origin/"+ref) {
+		// Not a branch, so a tag or a commit that can only be checked out detached
+		if err := s.git.CheckoutDetached(remote.dir, ref); err != nil {
+			return fmt.Errorf("ref %s not found in %s: %w", ref, remote.url, err)
+		}
+		return nil
+	}
+
+	remote.onBranch = true
+	if !s.git.RefExists(remote.dir, "refs/heads/"+ref) {
+		return s.git.CheckoutTracking(remote.dir, ref)
+	}
+	if err := s.git.Checkout(remote.dir, ref); err != nil {
+		return err
+	}
+	return s.fastForwardOrRebase(remote.dir)
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestParseGitURL(t *testing.T) {
+	tests := []struct {
+		value         string
+		expectedURL   string
+		expectedRef   string
+		expectedIsURL bool
+		description   string
+	}{
+		{
+			value:       "/home/user/rules",
+			description: "Local path should not be a URL",
+		},
+		{
+			value:         "https://github.com/org/rules.git",
+			expectedURL:   "https://github.com/org/rules.git",
+			expectedIsURL: true,
+			description:   "HTTPS URL without ref",
+		},
+		{
+			value:         "git@github.com:org/rules.git#v1.2.0",
+			expectedURL:   "git@github.com:org/rules.git",
+			expectedRef:   "v1.2.0",
+			expectedIsURL: true,
+			description:   "scp-like URL with ref",
+		},
+		{
+			value:         "file:///srv/rules.git#main",
+			expectedURL:   "file:///srv/rules.git",
+			expectedRef:   "main",
+			expectedIsURL: true,
+			description:   "File URL with branch ref",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			url, ref, isURL := parseGitURL(test.value)
+			if url != test.expectedURL || ref != test.expectedRef || isURL != test.expectedIsURL {
+				t.Errorf("Expected (%q, %q, %v), got (%q, %q, %v)",
+					test.expectedURL, test.expectedRef, test.expectedIsURL, url, ref, isURL)
+			}
+		})
+	}
+}
+
+func TestPrepareRemoteRulesWithBareRepository(t *testing.T) {
+	workDir := initTestRepo(t)
+	t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	writeFileSystemFiles(t, NewOSFileSystem(), workDir, map[string]string{"rule.mdc": "v1\n"})
+	runTestGit(t, workDir, "add", "-A")
+	runTestGit(t, workDir, "commit", "-q", "-m", "v1")
+	runTestGit(t, workDir, "tag", "v1")
+
+	bareDir := filepath.Join(t.TempDir(), "rules.git")
+	runTestGit(t, workDir, "clone", "-q", "--bare", workDir, bareDir)
+	runTestGit(t, workDir, "remote", "add", "origin", bareDir)
+	url := "file://" + bareDir
+
+	syncService := NewSyncService(NewOutputService(), NewExecGitClient(), NewOSFileSystem())
+
+	remote, err := syncService.prepareRemoteRules(url, "", false)
+	if err != nil {
+		t.Fatalf("Unexpected error cloning: %v", err)
+	}
+	if !remote.onBranch {
+		t.Errorf("Expected the default branch to be checked out")
+	}
+	assertFileContent(t, filepath.Join(remote.dir, "rule.mdc"), "v1\n")
+
+	// A new central commit is fetched by the next sync
+	writeFileSystemFiles(t, NewOSFileSystem(), workDir, map[string]string{"rule.mdc": "v2\n"})
+	runTestGit(t, workDir, "commit", "-q", "-am", "v2")
+	runTestGit(t, workDir, "push", "-q", "origin", "HEAD")
+
+	remote, err = syncService.prepareRemoteRules(url, "", false)
+	if err != nil {
+		t.Fatalf("Unexpected error fetching: %v", err)
+	}
+	assertFileContent(t, filepath.Join(remote.dir, "rule.mdc"), "v2\n")
+
+	// Pushes are committed in the cache and pushed to the remote
+	writeFileSystemFiles(t, NewOSFileSystem(), remote.dir, map[string]string{"rule.mdc": "v3\n"})
+	sha, pushed, err := syncService.commitChanges(remote.dir, "v3", []string{"rule.mdc"}, models.SyncOptions{})
+	if err != nil || !pushed {
+		t.Fatalf("Expected commit to be pushed, got pushed=%v err=%v", pushed, err)
+	}
+	if head := runTestGit(t, bareDir, "rev-parse", "HEAD"); head != sha {
+		t.Errorf("Expected remote HEAD %s, got %s", sha, head)
+	}
+
+	// Tags are checked out detached
+	remote, err = syncService.prepareRemoteRules(url, "v1", false)
+	if err != nil {
+		t.Fatalf("Unexpected error checking out tag: %v", err)
+	}
+	if remote.onBranch {
+		t.Errorf("Expected a tag to be checked out detached")
+	}
+	assertFileContent(t, filepath.Join(remote.dir, "rule.mdc"), "v1\n")
+}
+
+func assertFileContent(t *testing.T, path, expected string) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if string(content) != expected {
+		t.Errorf("Expected %s to contain %q, got %q", path, expected, content)
+	}
+}
+
+func TestPrepareRemoteRulesWithFakeGit(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(string(filepath.Separator), "cache"))
+	centralDir := filepath.Join(string(filepath.Separator), "central")
+	url := "https://example.com/rules.git"
+
+	fileSystem := NewMemoryFileSystem()
+	writeFileSystemFiles(t, fileSystem, centralDir, map[string]string{"rule.mdc": "v1\n"})
+	git, err := newFakeGitClient(fileSystem, centralDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	git.serve(url, centralDir)
+	central := git.repos[centralDir]
+	central.refs["refs/tags/v1"] = central.headSHA()
+
+	var stdout bytes.Buffer
+	syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), git, fileSystem)
+
+	remote, err := syncService.prepareRemoteRules(url, "", false)
+	if err != nil {
+		t.Fatalf("Unexpected error cloning: %v", err)
+	}
+	if !remote.onBranch || git.repos[remote.dir].branch != "main" {
+		t.Errorf("Expected the default branch to be checked out")
+	}
+	assertFiles(t, "cache", map[string]string{"rule.mdc": "v1\n"}, readFileSystemFiles(t, fileSystem, remote.dir))
+
+	// A new central commit is fetched by the next sync, leftovers in the cache are discarded
+	git.commitFiles(t, centralDir, "v2", map[string]string{"rule.mdc": "v2\n"})
+	writeFileSystemFiles(t, fileSystem, remote.dir, map[string]string{"rule.mdc": "leftover\n", "new.mdc": "leftover\n"})
+	remote, err = syncService.prepareRemoteRules(url, "", false)
+	if err != nil {
+		t.Fatalf("Unexpected error fetching: %v", err)
+	}
+	assertFiles(t, "cache", map[string]string{"rule.mdc": "v2\n"}, readFileSystemFiles(t, fileSystem, remote.dir))
+
+	// Without fetching the cache stays at what was fetched last
+	git.commitFiles(t, centralDir, "v3", map[string]string{"rule.mdc": "v3\n"})
+	if remote, err = syncService.prepareRemoteRules(url, "", true); err != nil {
+		t.Fatalf("Unexpected error without fetching: %v", err)
+	}
+	assertFiles(t, "cache", map[string]string{"rule.mdc": "v2\n"}, readFileSystemFiles(t, fileSystem, remote.dir))
+
+	// Tags are checked out detached
+	remote, err = syncService.prepareRemoteRules(url, "v1", false)
+	if err != nil {
+		t.Fatalf("Unexpected error checking out tag: %v", err)
+	}
+	if remote.onBranch {
+		t.Errorf("Expected a tag to be checked out detached")
+	}
+	assertFiles(t, "cache", map[string]string{"rule.mdc": "v1\n"}, readFileSystemFiles(t, fileSystem, remote.dir))
+
+	if _, err := syncService.prepareRemoteRules(url, "missing", false); err == nil {
+		t.Errorf("Expected an unknown ref to fail")
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+const (
+	sarifVersion   = "2.1.0"
+	sarifSchema    = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifToolName  = "cursor-rules-syncer"
+	sarifToolURI   = "https://github.com/yanodintsovmercuryo/cursor-rules-syncer"
+	sarifRulesBase = "RULESDIR" // uriBaseId the artifact locations are relative to
+)
+
+// sarifLog is the subset of a SARIF 2.1.0 log that lint reports use
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactURI `json:"originalUriBaseIds"`
+	Results            []sarifResult               `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactURI `json:"artifactLocation"`
+	Region           sarifRegion      `json:"region"`
+}
+
+type sarifArtifactURI struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// newSARIFLog converts a lint report into a SARIF log, file locations are relative to the linted directory
+func newSARIFLog(report *models.LintReport) sarifLog {
+	rules := make([]sarifRule, 0, len(lintChecks))
+	for _, check := range lintChecks {
+		rules = append(rules, sarifRule{ID: check.rule, ShortDescription: sarifMessage{Text: check.description}})
+	}
+
+	results := make([]sarifResult, 0, len(report.Diagnostics))
+	for _, diagnostic := range report.Diagnostics {
+		results = append(results, sarifResult{
+			RuleID:  diagnostic.Rule,
+			Level:   string(diagnostic.Severity),
+			Message: sarifMessage{Text: diagnostic.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactURI{URI: filepath.ToSlash(diagnostic.RelativePath), URIBaseID: sarifRulesBase},
+				Region:           sarifRegion{StartLine: diagnostic.Line},
+			}}},
+		})
+	}
+
+	// Base URIs must end with a slash for relative locations to resolve inside them
+	baseURI := (&url.URL{Scheme: "file", Path: filepath.ToSlash(report.Dir)}).String()
+	if !strings.HasSuffix(baseURI, "/") {
+		baseURI += "/"
+	}
+
+	return sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs: []sarifRun{{
+			Tool:               sarifTool{Driver: sarifDriver{Name: sarifToolName, InformationURI: sarifToolURI, Rules: rules}},
+			OriginalURIBaseIDs: map[string]sarifArtifactURI{sarifRulesBase: {URI: baseURI}},
+			Results:            results,
+		}},
+	}
+}
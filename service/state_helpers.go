@@ -0,0 +1,116 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+const syncStateVersion = 1
+
+// loadSyncState reads the sync state manifest from the project rules directory.
+// A missing manifest yields an empty state, so every file is treated as untracked.
+func (s *SyncService) loadSyncState(projectRulesDir string) (*models.SyncState, error) {
+	state := &models.SyncState{
+		Version: syncStateVersion,
+		Files:   make(map[string]models.SyncStateEntry),
+	}
+
+	content, err := s.fileSystem.ReadFile(filepath.Join(projectRulesDir, syncStateFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("failed to parse sync state %s: %w", syncStateFileName, err)
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]models.SyncStateEntry)
+	}
+	return state, nil
+}
+
+// saveSyncState writes the sync state manifest to the project rules directory
+func (s *SyncService) saveSyncState(projectRulesDir string, state *models.SyncState) error {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sync state: %w", err)
+	}
+
+	if err := s.fileSystem.MkdirAll(projectRulesDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", projectRulesDir, err)
+	}
+
+	if err := writeFileAtomic(s.fileSystem, filepath.Join(projectRulesDir, syncStateFileName), append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
+
+// copySyncState returns a copy of the state that can be modified independently
+func copySyncState(state *models.SyncState) *models.SyncState {
+	next := &models.SyncState{
+		Version: syncStateVersion,
+		Files:   make(map[string]models.SyncStateEntry, len(state.Files)),
+	}
+	for relativePath, entry := range state.Files {
+		next.Files[relativePath] = entry
+	}
+	return next
+}
+
+// newSyncStateEntry records a synced body
+func newSyncStateEntry(body string) models.SyncStateEntry {
+	return models.SyncStateEntry{
+		Hash: hashContent(body),
+		Base: body,
+	}
+}
+
+// hashContent returns the hex encoded SHA-256 of the content
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// readBody reads a file normalized, without its header for .mdc files.
+// The body is what sync copies between sides, so it is what the state manifest tracks.
+func (s *SyncService) readBody(filePath string) (string, error) {
+	content, err := s.readFileNormalized(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	if filepath.Ext(filePath) == mdcExtension {
+		_, body, err := splitFrontmatter(content)
+		if err != nil {
+			return "", fmt.Errorf("invalid header in %s: %w", filePath, err)
+		}
+		return body, nil
+	}
+	return content, nil
+}
+
+// projectRulesDir returns the project side of a plan, where the state manifest lives
+func projectRulesDir(plan *models.SyncPlan) string {
+	if plan.Direction == models.DirectionPush {
+		return plan.SourceDir
+	}
+	return plan.TargetDir
+}
+
+// mergeLabels returns conflict marker labels for the target and source side of a plan
+func mergeLabels(plan *models.SyncPlan) (string, string) {
+	if plan.Direction == models.DirectionPush {
+		return "central", "project"
+	}
+	return "project", "central"
+}
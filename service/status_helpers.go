@@ -0,0 +1,124 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+// Status compares the project rules with the central rules without modifying anything.
+// Header-only differences only count as drift when headers are overwritten or merged, as that is when sync would change them.
+func (s *SyncService) Status(options *models.SyncOptions) (*models.StatusReport, error) {
+	scope, err := s.resolveSyncScope(options, false)
+	if err != nil {
+		return nil, err
+	}
+	statusOptions := *options
+	statusOptions.RuleOverrides = scope.ruleOverrides
+
+	centralFiles, err := s.findScopedFiles(scope.rulesDir, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	projectFiles := map[string]string{}
+	if _, statErr := s.fileSystem.Stat(scope.projectRulesDir); statErr == nil {
+		projectFiles, err = s.findScopedFiles(scope.projectRulesDir, scope)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	relativePaths := make([]string, 0, len(centralFiles)+len(projectFiles))
+	for relativePath := range centralFiles {
+		relativePaths = append(relativePaths, relativePath)
+	}
+	for relativePath := range projectFiles {
+		if _, ok := centralFiles[relativePath]; !ok {
+			relativePaths = append(relativePaths, relativePath)
+		}
+	}
+	sort.Strings(relativePaths)
+
+	report := &models.StatusReport{
+		ProjectDir: scope.projectRulesDir,
+		CentralDir: scope.rulesDir,
+		InSync:     true,
+		Files:      []models.FileStatus{},
+	}
+
+	for _, relativePath := range relativePaths {
+		centralFile, inCentral := centralFiles[relativePath]
+		projectFile, inProject := projectFiles[relativePath]
+
+		var drift models.DriftType
+		switch {
+		case !inCentral:
+			drift = models.DriftOnlyInProject
+		case !inProject:
+			drift = models.DriftOnlyInCentral
+		default:
+			drift, err = s.compareForStatus(centralFile, projectFile, relativePath, statusOptions)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compare %s: %w", relativePath, err)
+			}
+		}
+
+		if drift == "" {
+			continue
+		}
+		report.Files = append(report.Files, models.FileStatus{RelativePath: relativePath, Drift: drift})
+		_, overridden := scope.ruleOverrides[relativePath]
+		if drift != models.DriftHeaderOnly || options.OverwriteHeaders || options.MergeHeaders || overridden {
+			report.InSync = false
+		}
+	}
+
+	return report, nil
+}
+
+// findScopedFiles lists files in dir that pass the scope's patterns and ignore rules, keyed by relative path
+func (s *SyncService) findScopedFiles(dir string, scope *syncScope) (map[string]string, error) {
+	files, err := s.findSourceFiles(dir, scope.patterns)
+	if err != nil {
+		return nil, err
+	}
+	files = s.fileFilterService.FilterIgnoredFiles(files, dir, scope.ignorePatterns)
+
+	filesByPath := make(map[string]string, len(files))
+	for _, file := range files {
+		relativePath, err := s.GetRelativePath(file, dir)
+		if err != nil {
+			relativePath = filepath.Base(file)
+		}
+		filesByPath[relativePath] = file
+	}
+	return filesByPath, nil
+}
+
+// compareForStatus classifies a file present on both sides, an empty result means no drift.
+// When headers are merged or the file has overrides only headers a pull would change count as header drift.
+func (s *SyncService) compareForStatus(centralFile, projectFile, relativePath string, options models.SyncOptions) (models.DriftType, error) {
+	bodiesEqual, err := s.filesAreEqualBasedOnExtension(centralFile, projectFile, relativePath, models.SyncOptions{LineEndings: options.LineEndings})
+	if err != nil {
+		return "", err
+	}
+	if !bodiesEqual {
+		return models.DriftModified, nil
+	}
+
+	headerOptions := models.SyncOptions{OverwriteHeaders: true, LineEndings: options.LineEndings}
+	if _, overridden := options.RuleOverrides[relativePath]; overridden || options.MergeHeaders {
+		headerOptions = options
+	}
+	filesEqual, err := s.filesAreEqualBasedOnExtension(centralFile, projectFile, relativePath, headerOptions)
+	if err != nil {
+		return "", err
+	}
+	if !filesEqual {
+		return models.DriftHeaderOnly, nil
+	}
+	return "", nil
+}
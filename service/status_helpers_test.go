@@ -0,0 +1,92 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestCompareForStatus(t *testing.T) {
+	outputService := NewOutputService()
+	syncService := NewSyncService(outputService, NewExecGitClient(), NewOSFileSystem())
+
+	tests := []struct {
+		fileName      string
+		central       string
+		project       string
+		options       models.SyncOptions
+		expectedDrift models.DriftType
+		description   string
+	}{
+		{
+			fileName:      "same.mdc",
+			central:       "---\ndescription: rule\n---\nbody\n",
+			project:       "---\ndescription: rule\n---\nbody\n",
+			expectedDrift: "",
+			description:   "Identical files should have no drift",
+		},
+		{
+			fileName:      "header.mdc",
+			central:       "---\ndescription: central\n---\nbody\n",
+			project:       "---\ndescription: project\n---\nbody\n",
+			expectedDrift: models.DriftHeaderOnly,
+			description:   "Files differing only in header should be header-only",
+		},
+		{
+			fileName:      "body.mdc",
+			central:       "---\ndescription: rule\n---\ncentral body\n",
+			project:       "---\ndescription: rule\n---\nproject body\n",
+			expectedDrift: models.DriftModified,
+			description:   "Files with different bodies should be modified",
+		},
+		{
+			fileName:      "plain.md",
+			central:       "central\n",
+			project:       "project\n",
+			expectedDrift: models.DriftModified,
+			description:   "Non-mdc files should be compared in full",
+		},
+		{
+			fileName:      "crlf.md",
+			central:       "line\r\n",
+			project:       "line\n",
+			expectedDrift: models.DriftModified,
+			description:   "Line ending differences of other files than rules should count, they are copied byte for byte",
+		},
+		{
+			fileName:      "kept.mdc",
+			central:       "---\ndescription: rule\nowner: central\n---\nbody\n",
+			project:       "---\ndescription: rule\nowner: project\n---\nbody\n",
+			options:       models.SyncOptions{MergeHeaders: true},
+			expectedDrift: "",
+			description:   "Header differences kept by a merge should not be drift",
+		},
+		{
+			fileName:      "merged.mdc",
+			central:       "---\ndescription: central\nowner: central\n---\nbody\n",
+			project:       "---\ndescription: project\nowner: project\n---\nbody\n",
+			options:       models.SyncOptions{MergeHeaders: true},
+			expectedDrift: models.DriftHeaderOnly,
+			description:   "Header differences a merge would take should be header-only",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			centralDir, projectDir := t.TempDir(), t.TempDir()
+			writeFileSystemFiles(t, NewOSFileSystem(), centralDir, map[string]string{test.fileName: test.central})
+			writeFileSystemFiles(t, NewOSFileSystem(), projectDir, map[string]string{test.fileName: test.project})
+			centralFile := filepath.Join(centralDir, test.fileName)
+			projectFile := filepath.Join(projectDir, test.fileName)
+
+			drift, err := syncService.compareForStatus(centralFile, projectFile, test.fileName, test.options)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if drift != test.expectedDrift {
+				t.Errorf("Expected drift %q, got %q", test.expectedDrift, drift)
+			}
+		})
+	}
+}
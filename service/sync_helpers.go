@@ -1,11 +1,13 @@
 package service
 
 import (
+	"bytes"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
 )
 
 const (
@@ -15,6 +17,8 @@ const (
 	rulesDirName         = "rules"
 	headerSeparator      = "---"
 	ruleignoreFileName   = ".ruleignore"
+	syncStateFileName    = ".sync-state.json"
+	gitDirName           = ".git"
 )
 
 // GetRulesSourceDir retrieves the path to the rules directory from flag or environment variable.
@@ -30,46 +34,71 @@ func (s *SyncService) GetRulesSourceDir(flagValue string) (string, error) {
 	return rulesDir, nil
 }
 
-// getGitRootDir finds the root of the Git repository starting from the given directory.
-func (s *SyncService) getGitRootDir(startDir string) (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	cmd.Dir = startDir
-	output, err := cmd.Output()
+// getIgnorePatterns merges .ruleignore files with patterns from --ignore-files or CURSOR_RULES_IGNORE.
+// Project rules are read first, then central rules, then command line patterns, so later sources take precedence.
+func (s *SyncService) getIgnorePatterns(projectRulesDir, centralRulesDir, ignoreFiles string) ([]models.IgnorePattern, error) {
+	patterns, err := s.fileFilterService.LoadIgnorePatterns(projectRulesDir, centralRulesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ignoreFilePatterns, err := s.fileFilterService.GetFilePatterns(ignoreFiles, cursorRulesIgnoreEnvVar)
 	if err != nil {
-		return "", fmt.Errorf("error finding git repository root: %w", err)
+		return nil, fmt.Errorf("failed to get ignore files: %w", err)
 	}
-	return strings.TrimSpace(string(output)), nil
+
+	flagPatterns, err := s.fileFilterService.CompileIgnorePatterns(ignoreFilePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ignore files: %w", err)
+	}
+
+	return append(patterns, flagPatterns...), nil
 }
 
-// findAllFiles finds all files in the specified directory recursively.
-func (s *SyncService) findAllFiles(dir string) ([]string, error) {
-	var allFiles []string
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// checkIgnoreConflicts returns an IgnoreConflictError when ignored source files also exist in the destination
+func (s *SyncService) checkIgnoreConflicts(srcFiles []string, srcBase, dstBase string, ignorePatterns []models.IgnorePattern) error {
+	var conflicts []string
+	for _, srcFile := range srcFiles {
+		relativePath, err := s.GetRelativePath(srcFile, srcBase)
+		if err != nil || isReservedFile(filepath.ToSlash(relativePath)) {
+			continue
 		}
-		if !info.IsDir() {
-			allFiles = append(allFiles, path)
+
+		if !s.fileFilterService.IsIgnored(relativePath, ignorePatterns) {
+			continue
 		}
-		return nil
-	})
+
+		if _, statErr := s.fileSystem.Stat(filepath.Join(dstBase, relativePath)); statErr == nil {
+			conflicts = append(conflicts, relativePath)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return &IgnoreConflictError{Files: conflicts}
+	}
+	return nil
+}
+
+// GetDestinationPath maps a source file to its path under the destination base without touching disk
+func (s *SyncService) GetDestinationPath(srcPath, srcBase, dstBase string) (string, error) {
+	relativePath, err := filepath.Rel(srcBase, srcPath)
 	if err != nil {
-		return nil, fmt.Errorf("error finding files in %s: %w", dir, err)
+		return "", fmt.Errorf("cannot determine relative path: %w", err)
 	}
-	return allFiles, nil
+
+	return filepath.Join(dstBase, relativePath), nil
 }
 
 // RecreateDirectoryStructure recreates the directory structure for the destination file
 func (s *SyncService) RecreateDirectoryStructure(srcPath, srcBase, dstBase string) (string, error) {
-	relativePath, err := filepath.Rel(srcBase, srcPath)
+	dstPath, err := s.GetDestinationPath(srcPath, srcBase, dstBase)
 	if err != nil {
-		return "", fmt.Errorf("cannot determine relative path: %w", err)
+		return "", err
 	}
 
-	dstPath := filepath.Join(dstBase, relativePath)
 	dstDir := filepath.Dir(dstPath)
 
-	if err := os.MkdirAll(dstDir, os.ModePerm); err != nil {
+	if err := s.fileSystem.MkdirAll(dstDir, os.ModePerm); err != nil {
 		return "", fmt.Errorf("cannot create directory %s: %w", dstDir, err)
 	}
 
@@ -81,241 +110,168 @@ func (s *SyncService) GetRelativePath(filePath, baseDir string) (string, error)
 	return filepath.Rel(baseDir, filePath)
 }
 
-// cleanupExtraFiles removes files that exist in destination but not in source
-func (s *SyncService) cleanupExtraFiles(srcFiles []string, srcBase, dstBase string, patterns interface{}) error {
-	// Build map of source files by relative path
-	srcFilesMap := make(map[string]bool)
-	for _, srcFile := range srcFiles {
-		relativePath, err := s.GetRelativePath(srcFile, srcBase)
-		if err != nil {
-			continue
+// stageOperation stages the write or deletion of an operation in the transaction
+func (s *SyncService) stageOperation(transaction *syncTransaction, operation models.FileOperation, options models.SyncOptions) error {
+	switch operation.Type {
+	case models.OperationDelete:
+		transaction.stageDelete(operation.TargetPath)
+		return nil
+	case models.OperationAdd, models.OperationUpdate:
+		if operation.Merged {
+			return s.writeMergedFile(transaction, operation, options)
 		}
-		srcFilesMap[relativePath] = true
+		return s.copyFileBasedOnExtension(transaction, operation, options)
+	default:
+		return fmt.Errorf("unknown operation type %q", operation.Type)
 	}
+}
 
-	// Find all destination files
-	var destFiles []string
-	err := filepath.Walk(dstBase, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			destFiles = append(destFiles, path)
-		}
-		return nil
-	})
+// syncedContent returns the content syncing srcPath writes to dstPath: .mdc files get the header chosen by
+// destinationHeader and line endings follow the line ending policy. Binary files, and other files than .mdc
+// rules when no policy is set, are copied unchanged.
+func (s *SyncService) syncedContent(srcPath, dstPath, relativePath string, options models.SyncOptions) ([]byte, error) {
+	srcContent, err := s.fileSystem.ReadFile(srcPath)
 	if err != nil {
-		return fmt.Errorf("error walking destination directory: %w", err)
+		return nil, fmt.Errorf("failed to read source file %s: %w", srcPath, err)
 	}
-
-	// Remove files that don't exist in source
-	for _, destFile := range destFiles {
-		relativePath, err := s.GetRelativePath(destFile, dstBase)
-		if err != nil {
-			continue
-		}
-
-		if !srcFilesMap[relativePath] {
-			if err := os.Remove(destFile); err != nil {
-				s.outputService.PrintErrorf("Error deleting file %s: %v\n", relativePath, err)
-			} else {
-				s.outputService.PrintOperation("delete", relativePath)
-			}
-		}
+	isRule := filepath.Ext(srcPath) == mdcExtension
+	if isBinary(srcContent) || (!isRule && options.LineEndings == "") {
+		return srcContent, nil
 	}
 
-	return nil
-}
-
-// copyFile copies a file, optionally preserving headers
-func (s *SyncService) copyFile(srcPath, dstPath string, preserveHeaders bool) error {
-	// Read source file content completely
-	srcContent, err := os.ReadFile(srcPath)
+	format, err := s.lineEndingFormat(srcContent, dstPath, options.LineEndings)
 	if err != nil {
-		return fmt.Errorf("failed to read source file %s: %w", srcPath, err)
+		return nil, err
 	}
+	content := normalizeLineEndings(string(srcContent))
 
-	// Normalize line endings in source content
-	srcContentStr := strings.ReplaceAll(string(srcContent), "\r\n", "\n")
-	srcContentStr = strings.ReplaceAll(srcContentStr, "\r", "\n")
-
-	var finalContent string
+	// Only apply header logic for .mdc files
+	if isRule {
+		srcHeader, srcContentWithoutHeader, err := splitFrontmatter(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid header in %s: %w", srcPath, err)
+		}
 
-	if preserveHeaders {
 		// Check destination file existence and extract header
 		existingHeader, err := s.ExtractExistingHeader(dstPath)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		// If there's an existing header in destination, extract content from source without its header
-		if existingHeader != "" {
-			srcContentWithoutHeader := s.RemoveHeaderFromContent(srcContentStr)
-			finalContent = existingHeader + srcContentWithoutHeader
-		} else {
-			finalContent = srcContentStr
+		header, err := s.destinationHeader(srcHeader, existingHeader, relativePath, options)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build header of %s: %w", relativePath, err)
+		}
+
+		// The source is copied as is when it keeps its own header
+		if header != srcHeader {
+			if format.preserve {
+				// Only the header is rewritten, the body keeps its original bytes
+				bodyLines := strings.Count(content, "\n") - strings.Count(srcContentWithoutHeader, "\n")
+				srcContent = append([]byte(strings.ReplaceAll(header, "\n", format.eol)), skipLines(srcContent, bodyLines)...)
+			}
+			content = header + srcContentWithoutHeader
 		}
-	} else {
-		// Simply overwrite without preserving headers
-		finalContent = srcContentStr
 	}
 
-	// Ensure file ends with newline
-	if !strings.HasSuffix(finalContent, "\n") {
-		finalContent += "\n"
+	if format.preserve {
+		return preserveLineEndings(srcContent, format), nil
 	}
+	return applyLineEndings(content, format), nil
+}
 
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(dstPath), os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create directory for %s: %w", dstPath, err)
+// writeMergedFile stages a merged body below the header a regular copy would have produced
+func (s *SyncService) writeMergedFile(transaction *syncTransaction, operation models.FileOperation, options models.SyncOptions) error {
+	header := ""
+	if filepath.Ext(operation.SourcePath) == mdcExtension {
+		srcHeader, err := s.ExtractExistingHeader(operation.SourcePath)
+		if err != nil {
+			return err
+		}
+		dstHeader, err := s.ExtractExistingHeader(operation.TargetPath)
+		if err != nil {
+			return err
+		}
+		header, err = s.destinationHeader(srcHeader, dstHeader, operation.RelativePath, options)
+		if err != nil {
+			return fmt.Errorf("failed to build header of %s: %w", operation.RelativePath, err)
+		}
 	}
 
-	// Write final content
-	err = os.WriteFile(dstPath, []byte(finalContent), 0644)
+	srcContent, err := s.fileSystem.ReadFile(operation.SourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read source file %s: %w", operation.SourcePath, err)
+	}
+	format, err := s.lineEndingFormat(srcContent, operation.TargetPath, options.LineEndings)
 	if err != nil {
-		return fmt.Errorf("failed to write destination file %s: %w", dstPath, err)
+		return err
 	}
 
-	return nil
+	return transaction.stageWrite(operation.TargetPath, applyLineEndings(header+operation.MergedBody, format))
 }
 
-// RemoveHeaderFromContent removes the YAML header from markdown content
+// RemoveHeaderFromContent removes the YAML header from markdown content.
+// Content whose header is never closed is returned as is.
 func (s *SyncService) RemoveHeaderFromContent(content string) string {
-	lines := strings.Split(content, "\n")
-	if len(lines) == 0 {
+	_, body, err := splitFrontmatter(content)
+	if err != nil {
 		return content
 	}
-
-	// Check if it starts with header separator
-	if lines[0] != headerSeparator {
-		return content // No header, return as is
-	}
-
-	// Look for closing separator
-	for i := 1; i < len(lines); i++ {
-		if lines[i] == headerSeparator {
-			// Found closing separator, return content after it
-			if i+1 < len(lines) {
-				remainingLines := lines[i+1:]
-				// Remove leading empty lines
-				for len(remainingLines) > 0 && strings.TrimSpace(remainingLines[0]) == "" {
-					remainingLines = remainingLines[1:]
-				}
-				return strings.Join(remainingLines, "\n")
-			}
-			return "" // Header takes up entire file
-		}
-		// Limit header search to reasonable number of lines
-		if i > 20 {
-			break
-		}
-	}
-
-	// No closing separator found, return entire content
-	return content
+	return body
 }
 
-// ExtractHeaderFromContent extracts the YAML header from markdown content
+// ExtractHeaderFromContent extracts the YAML header from markdown content, empty when it has none or it is never closed
 func (s *SyncService) ExtractHeaderFromContent(content string) string {
-	// Normalize line endings
-	content = strings.ReplaceAll(content, "\r\n", "\n")
-	content = strings.ReplaceAll(content, "\r", "\n")
-
-	lines := strings.Split(content, "\n")
-	if len(lines) == 0 || lines[0] != headerSeparator {
-		return "" // No header
-	}
-
-	var headerLines []string
-	headerLines = append(headerLines, lines[0]) // Add first separator
-
-	// Look for closing separator
-	for i := 1; i < len(lines) && i <= 20; i++ { // Limit search to 20 lines
-		headerLines = append(headerLines, lines[i])
-		if lines[i] == headerSeparator {
-			// Found closing separator, return header with newline
-			return strings.Join(headerLines, "\n") + "\n"
-		}
+	header, _, err := splitFrontmatter(normalizeLineEndings(content))
+	if err != nil {
+		return ""
 	}
-
-	return "" // No proper header found
+	return header
 }
 
-// extractExistingHeader extracts the YAML header from an existing file
+// ExtractExistingHeader extracts the YAML header from an existing file, a header that is never closed is an error
 func (s *SyncService) ExtractExistingHeader(dstPath string) (string, error) {
-	if _, statErr := os.Stat(dstPath); statErr != nil {
+	if _, statErr := s.fileSystem.Stat(dstPath); statErr != nil {
 		return "", nil // File doesn't exist, no header to preserve
 	}
 
-	content, err := os.ReadFile(dstPath)
+	content, err := s.readFileNormalized(dstPath)
 	if err != nil {
 		return "", nil // Can't read file, continue without header
 	}
 
-	return s.ExtractHeaderFromContent(string(content)), nil
-}
-
-// copyFileBasedOnExtension copies a file, applying header preservation only for .mdc files
-func (s *SyncService) copyFileBasedOnExtension(srcPath, dstPath string, overwriteHeaders bool) error {
-	// Only apply header logic for .mdc files
-	if filepath.Ext(srcPath) == mdcExtension {
-		return s.copyFile(srcPath, dstPath, !overwriteHeaders)
-	}
-
-	// For non-.mdc files, just copy directly without header processing
-	return s.copyFileDirectly(srcPath, dstPath)
-}
-
-// copyFileDirectly copies a file from source to destination
-func (s *SyncService) copyFileDirectly(srcPath, dstPath string) error {
-	// Read source file
-	srcContent, err := os.ReadFile(srcPath)
+	header, _, err := splitFrontmatter(content)
 	if err != nil {
-		return fmt.Errorf("failed to read source file %s: %w", srcPath, err)
+		return "", fmt.Errorf("invalid header in %s: %w", dstPath, err)
 	}
-
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(dstPath), os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create directory for %s: %w", dstPath, err)
-	}
-
-	// Write file
-	err = os.WriteFile(dstPath, srcContent, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write destination file %s: %w", dstPath, err)
-	}
-
-	return nil
+	return header, nil
 }
 
-// filesAreEqualBasedOnExtension compares files, using header-aware comparison only for .mdc files
-func (s *SyncService) filesAreEqualBasedOnExtension(file1, file2 string, overwriteHeaders bool) (bool, error) {
-	// Only apply header logic for .mdc files
-	if filepath.Ext(file1) == mdcExtension && filepath.Ext(file2) == mdcExtension {
-		if overwriteHeaders {
-			return s.filesAreEqualNormalized(file1, file2)
-		} else {
-			return s.filesAreEqualNormalizedWithoutHeaders(file1, file2)
-		}
+// copyFileBasedOnExtension stages a copy of a file, applying header handling only for .mdc files
+func (s *SyncService) copyFileBasedOnExtension(transaction *syncTransaction, operation models.FileOperation, options models.SyncOptions) error {
+	content, err := s.syncedContent(operation.SourcePath, operation.TargetPath, operation.RelativePath, options)
+	if err != nil {
+		return err
 	}
 
-	// For non-.mdc files, use simple comparison
-	return s.filesAreEqualNormalized(file1, file2)
+	return transaction.stageWrite(operation.TargetPath, content)
 }
 
-// filesAreEqualNormalized compares two files after normalizing their content
-func (s *SyncService) filesAreEqualNormalized(file1, file2 string) (bool, error) {
-	content1, err := s.readFileNormalized(file1)
+// filesAreEqualBasedOnExtension reports whether syncing srcPath would leave dstPath unchanged.
+// The destination is compared byte for byte with what copyFileBasedOnExtension would write, so line endings
+// and trailing whitespace count as differences.
+func (s *SyncService) filesAreEqualBasedOnExtension(srcPath, dstPath, relativePath string, options models.SyncOptions) (bool, error) {
+	expected, err := s.syncedContent(srcPath, dstPath, relativePath, options)
 	if err != nil {
 		return false, err
 	}
 
-	content2, err := s.readFileNormalized(file2)
+	actual, err := s.fileSystem.ReadFile(dstPath)
 	if err != nil {
 		return false, err
 	}
 
-	return content1 == content2, nil
+	return bytes.Equal(expected, actual), nil
 }
 
 // filesAreEqualNormalizedWithoutHeaders compares two files ignoring YAML headers
@@ -331,22 +287,32 @@ func (s *SyncService) filesAreEqualNormalizedWithoutHeaders(file1, file2 string)
 	}
 
 	// Remove headers from both files before comparison
-	contentWithoutHeader1 := s.RemoveHeaderFromContent(content1)
-	contentWithoutHeader2 := s.RemoveHeaderFromContent(content2)
+	_, contentWithoutHeader1, err := splitFrontmatter(content1)
+	if err != nil {
+		return false, fmt.Errorf("invalid header in %s: %w", file1, err)
+	}
+	_, contentWithoutHeader2, err := splitFrontmatter(content2)
+	if err != nil {
+		return false, fmt.Errorf("invalid header in %s: %w", file2, err)
+	}
 
 	return contentWithoutHeader1 == contentWithoutHeader2, nil
 }
 
-// readFileNormalized reads a file and normalizes line endings
+// readFileNormalized reads a file and normalizes its content with normalizeContent
 func (s *SyncService) readFileNormalized(filePath string) (string, error) {
-	content, err := os.ReadFile(filePath)
+	content, err := s.fileSystem.ReadFile(filePath)
 	if err != nil {
 		return "", err
 	}
 
-	// Normalize line endings - convert to LF
-	normalized := strings.ReplaceAll(string(content), "\r\n", "\n")
-	normalized = strings.ReplaceAll(normalized, "\r", "\n")
+	return normalizeContent(string(content)), nil
+}
+
+// normalizeContent converts line endings to LF and ends non-empty content with exactly one newline,
+// trailing whitespace of the last line is dropped
+func normalizeContent(content string) string {
+	normalized := normalizeLineEndings(content)
 
 	// Remove trailing whitespace except newlines, then normalize newlines at the end
 	normalized = strings.TrimRight(normalized, " \t")
@@ -357,71 +323,51 @@ func (s *SyncService) readFileNormalized(filePath string) (string, error) {
 		normalized += "\n"
 	}
 
-	return normalized, nil
+	return normalized
 }
 
-// checkGitRemoteOrigin checks if a git repository has a remote origin
-func (s *SyncService) checkGitRemoteOrigin(repoDir string) (bool, error) {
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	cmd.Dir = repoDir
-	if err := cmd.Run(); err != nil {
-		if _, ok := err.(*exec.ExitError); ok {
-			return false, nil
-		}
-		return false, fmt.Errorf("error checking git remote origin: %w", err)
+// commitChanges stages and commits the given paths, relative to repoDir, and pushes the commit.
+// Changes to other files are neither staged nor committed, even when they were staged before.
+// The commit is only pushed if 'origin' remote exists and GitWithoutPush is false.
+// Author, committer, signoff and signing settings are taken from options.
+// It returns the SHA of the new commit, empty when nothing was committed, and whether the commit was pushed.
+func (s *SyncService) commitChanges(repoDir string, commitMessage string, paths []string, options models.SyncOptions) (string, bool, error) {
+	if err := s.git.Add(repoDir, paths); err != nil {
+		return "", false, err
 	}
-	return true, nil
-}
 
-// commitChanges performs git add ., git commit -m "message", and git push in the specified directory.
-// Git push is only attempted if 'origin' remote exists and gitWithoutPush is false. Output is minimal, only errors or specific statuses.
-func (s *SyncService) commitChanges(repoDir string, commitMessage string, gitWithoutPush bool) error {
-	// Use git add -A instead of git add . to add all changes including deletions
-	addCmd := exec.Command("git", "add", "-A")
-	addCmd.Dir = repoDir
-	output, err := addCmd.CombinedOutput()
+	committed, err := s.git.Commit(repoDir, commitMessage, paths, commitOptions(options))
 	if err != nil {
-		return fmt.Errorf("error running 'git add -A' in %s: %s\n%v\n", repoDir, string(output), err)
-	}
-
-	// Check status for debugging
-	statusCmd := exec.Command("git", "status", "--porcelain")
-	statusCmd.Dir = repoDir
-	statusOutput, statusErr := statusCmd.CombinedOutput()
-	if statusErr != nil {
-		s.outputService.PrintWarningf("Warning: could not check git status in %s: %v\n", repoDir, statusErr)
-	} else {
-		statusLines := strings.TrimSpace(string(statusOutput))
-		if statusLines == "" {
-			return nil // No changes to commit
+		// Files left staged would count as unrelated changes on the next push
+		if unstageErr := s.git.Unstage(repoDir, paths); unstageErr != nil {
+			s.outputService.PrintWarningf("Could not unstage synced files in %s: %v", repoDir, unstageErr)
 		}
+		return "", false, err
+	}
+	if !committed {
+		return "", false, nil
 	}
 
-	commitCmd := exec.Command("git", "commit", "-m", commitMessage)
-	commitCmd.Dir = repoDir
-	output, err = commitCmd.CombinedOutput()
+	commitSHA, err := s.git.HeadSHA(repoDir)
 	if err != nil {
-		if strings.Contains(string(output), "nothing to commit") || strings.Contains(string(output), "no changes added to commit") {
-			return nil // Not an error, just nothing to do for commit
-		}
-		return fmt.Errorf("error running 'git commit' in %s: %s\n%v\n", repoDir, string(output), err)
+		s.outputService.PrintWarningf("Could not read commit SHA in %s: %v\n", repoDir, err)
 	}
 
-	if !gitWithoutPush {
-		originExists, err := s.checkGitRemoteOrigin(repoDir)
-		if err != nil {
-			s.outputService.PrintWarningf("Could not verify remote 'origin' in %s: %v. Skipping push.\n", repoDir, err)
-			// Not returning error here, as push is optional
-		}
+	if options.GitWithoutPush {
+		return commitSHA, false, nil
+	}
 
-		if originExists {
-			pushCmd := exec.Command("git", "push")
-			pushCmd.Dir = repoDir
-			output, err = pushCmd.CombinedOutput()
-			if err != nil {
-				return fmt.Errorf("error running 'git push' in %s: %s\n%v\n", repoDir, string(output), err)
-			}
-		}
+	originURL, err := s.git.RemoteURL(repoDir)
+	if err != nil {
+		s.outputService.PrintWarningf("Could not verify remote 'origin' in %s: %v. Skipping push.\n", repoDir, err)
+		// Not returning error here, as push is optional
 	}
-	return nil
+	if originURL == "" {
+		return commitSHA, false, nil
+	}
+
+	if err := s.git.Push(repoDir); err != nil {
+		return commitSHA, false, err
+	}
+	return commitSHA, true, nil
 }
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckIgnoreConflicts(t *testing.T) {
+	outputService := NewOutputService()
+	syncService := NewSyncService(outputService, NewExecGitClient(), NewOSFileSystem())
+
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	writeFileSystemFiles(t, NewOSFileSystem(), srcDir, map[string]string{
+		"rule.mdc":       "content",
+		"drafts/wip.mdc": "content",
+		"secret.mdc":     "content",
+	})
+	writeFileSystemFiles(t, NewOSFileSystem(), dstDir, map[string]string{
+		"rule.mdc":       "content",
+		"drafts/wip.mdc": "content",
+	})
+
+	srcFiles, err := findAllFiles(syncService.fileSystem, srcDir)
+	if err != nil {
+		t.Fatalf("Failed to list source files: %v", err)
+	}
+
+	patterns, err := syncService.fileFilterService.CompileIgnorePatterns([]string{"drafts/", "secret.mdc"})
+	if err != nil {
+		t.Fatalf("Failed to compile patterns: %v", err)
+	}
+
+	err = syncService.checkIgnoreConflicts(srcFiles, srcDir, dstDir, patterns)
+
+	var conflictErr *IgnoreConflictError
+	if !errors.As(err, &conflictErr) {
+		t.Fatalf("Expected IgnoreConflictError, got %v", err)
+	}
+	if len(conflictErr.Files) != 1 || conflictErr.Files[0] != filepath.Join("drafts", "wip.mdc") {
+		t.Errorf("Expected only drafts/wip.mdc to conflict, got %v", conflictErr.Files)
+	}
+}
@@ -3,287 +3,252 @@ package service
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
 )
 
-const cursorRulesPatternsEnvVar = "CURSOR_RULES_PATTERNS"
+const (
+	cursorRulesPatternsEnvVar = "CURSOR_RULES_PATTERNS"
+	cursorRulesIgnoreEnvVar   = "CURSOR_RULES_IGNORE"
+)
 
 // SyncService handles all sync operations
 type SyncService struct {
 	outputService     *OutputService
 	fileFilterService *FileFilterService
+	git               GitClient
+	fileSystem        FileSystem
 }
 
-// NewSyncService creates a new SyncService
-func NewSyncService(outputService *OutputService) *SyncService {
+// NewSyncService creates a new SyncService running git operations through gitClient and file IO through fileSystem
+func NewSyncService(outputService *OutputService, gitClient GitClient, fileSystem FileSystem) *SyncService {
 	return &SyncService{
 		outputService:     outputService,
-		fileFilterService: NewFileFilterService(outputService),
+		fileFilterService: NewFileFilterService(outputService, fileSystem),
+		git:               gitClient,
+		fileSystem:        fileSystem,
 	}
 }
 
-// PullRules pulls rules from source directory to project .cursor/rules directory
+// PullRules pulls rules from source directory to project .cursor/rules directory and records them in rules.lock.
+// With Locked the revision pinned in rules.lock is pulled instead.
 func (s *SyncService) PullRules(options *models.SyncOptions) (*models.SyncResult, error) {
-	rulesSourceDir, err := s.GetRulesSourceDir(options.RulesDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get rules source dir: %w", err)
+	if options.Locked {
+		return s.PullLocked(options)
 	}
 
-	currentDir, err := os.Getwd()
+	plan, err := s.Plan(models.DirectionPull, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current directory: %w", err)
+		return nil, err
 	}
 
-	gitRoot, err := s.getGitRootDir(currentDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find git root: %w", err)
+	result, err := s.execute(plan)
+	if result == nil {
+		return nil, err
 	}
+	s.recordRulesLock(plan, result)
+	return result, err
+}
 
-	destRulesDir := filepath.Join(gitRoot, cursorDirName, rulesDirName)
-
-	// Get file patterns for filtering
-	filePatterns, err := s.fileFilterService.GetFilePatterns(options.FilePatterns, cursorRulesPatternsEnvVar)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get file patterns: %w", err)
+// PushRules pushes rules from project .cursor/rules directory to source directory.
+// With Branch the rules are committed to a new branch instead of the checked out one.
+// Uncommitted changes in the rules repository that the sync would not touch abort the push unless Force is set.
+// With Lint the project rules are linted first and lint errors abort the push.
+func (s *SyncService) PushRules(options *models.SyncOptions) (*models.SyncResult, error) {
+	if options.Lint {
+		if err := s.lintBeforePush(options); err != nil {
+			return nil, err
+		}
 	}
-
-	if mkdirErr := os.MkdirAll(destRulesDir, os.ModePerm); mkdirErr != nil {
-		return nil, fmt.Errorf("failed to create destination directory %s: %w", destRulesDir, mkdirErr)
+	if options.Branch != "" {
+		return s.pushToBranch(options)
 	}
 
-	// Find source files with pattern filtering
-	var sourceFiles []string
-	if len(filePatterns) == 0 {
-		// No patterns specified - get all files
-		sourceFiles, err = s.findAllFiles(rulesSourceDir)
-		if err != nil {
-			return nil, fmt.Errorf("failed to find source files in %s: %w", rulesSourceDir, err)
-		}
-	} else {
-		// Use pattern filtering
-		sourceFiles, err = s.fileFilterService.FindFilesByPatterns(rulesSourceDir, filePatterns)
-		if err != nil {
-			return nil, fmt.Errorf("failed to find files by patterns in %s: %w", rulesSourceDir, err)
-		}
+	plan, err := s.Plan(models.DirectionPush, options)
+	if err != nil {
+		return nil, err
 	}
-
-	// Clean up extra files in destination that don't exist in source
-	// Use pattern-aware cleanup
-	effectivePatterns := s.fileFilterService.GetEffectivePatterns(filePatterns)
-	if len(effectivePatterns) == 0 {
-		// No patterns - cleanup all extra files
-		if err := s.cleanupExtraFiles(sourceFiles, rulesSourceDir, destRulesDir, nil); err != nil {
-			return nil, fmt.Errorf("failed to cleanup extra files: %w", err)
-		}
-	} else {
-		// Use pattern-aware cleanup
-		if err := s.fileFilterService.CleanupExtraFilesByPatterns(sourceFiles, rulesSourceDir, destRulesDir, effectivePatterns); err != nil {
-			return nil, fmt.Errorf("failed to cleanup extra files: %w", err)
+	if !options.Force {
+		if err := s.checkUnrelatedChanges(plan); err != nil {
+			return nil, err
 		}
 	}
+	return s.execute(plan)
+}
 
-	// Copy files with proper directory structure
-	result := &models.SyncResult{
-		Operations: []models.FileOperation{},
-		HasChanges: false,
+// execute applies a plan unless a dry run is requested, in which case operations are only printed and recorded.
+// Files that failed to plan or apply are returned as a SyncError together with the result.
+func (s *SyncService) execute(plan *models.SyncPlan) (*models.SyncResult, error) {
+	// Diffs are computed up front, applying the plan changes the files they compare
+	if plan.Options.ShowDiff {
+		s.attachDiffs(plan)
 	}
 
-	for _, srcFileFullPath := range sourceFiles {
-		dstFileFullPath, err := s.RecreateDirectoryStructure(srcFileFullPath, rulesSourceDir, destRulesDir)
-		if err != nil {
-			s.outputService.PrintErrorf("Error recreating directory structure for %s: %v\n", srcFileFullPath, err)
-			continue
-		}
-
-		// Get relative path for display
-		relativePath, err := s.GetRelativePath(srcFileFullPath, rulesSourceDir)
-		if err != nil {
-			relativePath = filepath.Base(srcFileFullPath)
-		}
-
-		fileExistedBeforeCopy := true
-		if _, err := os.Stat(dstFileFullPath); os.IsNotExist(err) {
-			fileExistedBeforeCopy = false
-		} else if err != nil {
-			s.outputService.PrintErrorf("Error checking destination file %s: %v\n", relativePath, err)
-			continue
-		}
-
-		// Check if files are different before copying
-		shouldCopy := true
-		if fileExistedBeforeCopy {
-			equal, err := s.filesAreEqualBasedOnExtension(srcFileFullPath, dstFileFullPath, options.OverwriteHeaders)
-			if err != nil {
-				s.outputService.PrintErrorf("Error comparing files %s: %v\n", relativePath, err)
-				// Continue with copying in case of comparison error
-			} else if equal {
-				shouldCopy = false // Files are identical, no need to copy
-			}
-		}
-
-		if shouldCopy {
-			copyErr := s.copyFileBasedOnExtension(srcFileFullPath, dstFileFullPath, options.OverwriteHeaders)
-			if copyErr != nil {
-				s.outputService.PrintErrorf("Error synchronizing file %s: %v\n", relativePath, copyErr)
-			} else {
-				operationType := "update"
-				if !fileExistedBeforeCopy {
-					operationType = "add"
-				}
-				s.outputService.PrintOperation(operationType, relativePath)
-
-				result.Operations = append(result.Operations, models.FileOperation{
-					Type:         models.OperationType(operationType),
-					SourcePath:   srcFileFullPath,
-					TargetPath:   dstFileFullPath,
-					RelativePath: relativePath,
-				})
-				result.HasChanges = true
-			}
+	if plan.Options.DryRun {
+		result := s.newSyncResult(plan)
+		for _, operation := range plan.Operations {
+			s.printOperation(plan, operation)
+			s.recordOperation(result, operation)
 		}
+		return result, newSyncError(plan.Direction, planFailures(plan), nil)
 	}
 
-	return result, nil
+	return s.Apply(plan)
 }
 
-// PushRules pushes rules from project .cursor/rules directory to source directory
-func (s *SyncService) PushRules(options *models.SyncOptions) (*models.SyncResult, error) {
-	rulesEnvDir, err := s.GetRulesSourceDir(options.RulesDir)
+// Plan computes every add, update and delete a sync in the given direction would perform.
+// Nothing is written to disk and no git commands that modify state are run.
+func (s *SyncService) Plan(direction models.SyncDirection, options *models.SyncOptions) (*models.SyncPlan, error) {
+	// Dry runs must not move the user's checkout of the rules repository
+	scope, err := s.resolveSyncScope(options, !options.DryRun)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rules source dir: %w", err)
+		return nil, err
 	}
 
-	currentDir, err := os.Getwd()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	plan := &models.SyncPlan{
+		Direction:   direction,
+		ProjectRoot: scope.projectRoot,
+		Operations:  []models.FileOperation{},
+		Errors:      []models.FileError{},
+		Options:     *options,
 	}
 
-	projectGitRoot, err := s.getGitRootDir(currentDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find git root for project: %w", err)
-	}
-
-	rulesSourceDirInProject := filepath.Join(projectGitRoot, cursorDirName, rulesDirName)
-
-	if _, statErr := os.Stat(rulesSourceDirInProject); os.IsNotExist(statErr) {
-		return nil, fmt.Errorf("project rules directory %s not found. Nothing to push", rulesSourceDirInProject)
+	switch direction {
+	case models.DirectionPull:
+		plan.SourceDir, plan.TargetDir = scope.rulesDir, scope.projectRulesDir
+		plan.Options.RuleOverrides = scope.ruleOverrides
+	case models.DirectionPush:
+		plan.SourceDir, plan.TargetDir = scope.projectRulesDir, scope.rulesDir
+		if _, statErr := s.fileSystem.Stat(scope.projectRulesDir); os.IsNotExist(statErr) {
+			return nil, fmt.Errorf("project rules directory %s not found. Nothing to push", scope.projectRulesDir)
+		}
+		if err := validateCommitOptions(options); err != nil {
+			return nil, err
+		}
+		if scope.remote != nil && !scope.remote.onBranch {
+			return nil, fmt.Errorf("cannot push to %s: ref %s is not a branch", scope.remote.url, scope.remote.ref)
+		}
+	default:
+		return nil, fmt.Errorf("unknown sync direction %q", direction)
 	}
 
-	// Get file patterns for filtering
-	filePatterns, err := s.fileFilterService.GetFilePatterns(options.FilePatterns, cursorRulesPatternsEnvVar)
+	// Find source files with pattern filtering
+	sourceFiles, err := s.findSourceFiles(plan.SourceDir, scope.patterns)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get file patterns: %w", err)
+		return nil, err
 	}
 
-	// Find project files with pattern filtering
-	var projectFiles []string
-	if len(filePatterns) == 0 {
-		// No patterns specified - get all files
-		projectFiles, err = s.findAllFiles(rulesSourceDirInProject)
+	if direction == models.DirectionPull {
+		// Refuse to pull when files ignored centrally or via flags already exist in the project.
+		// Project-only .ruleignore entries are not conflicts: they mark files the project keeps for itself.
+		conflictPatterns, err := s.getIgnorePatterns("", scope.rulesDir, options.IgnoreFiles)
 		if err != nil {
-			return nil, fmt.Errorf("failed to find files in project rules directory %s: %w", rulesSourceDirInProject, err)
+			return nil, fmt.Errorf("failed to load ignore patterns: %w", err)
 		}
-	} else {
-		// Use pattern filtering
-		projectFiles, err = s.fileFilterService.FindFilesByPatterns(rulesSourceDirInProject, filePatterns)
-		if err != nil {
-			return nil, fmt.Errorf("failed to find files by patterns in project rules directory %s: %w", rulesSourceDirInProject, err)
+		if err := s.checkIgnoreConflicts(sourceFiles, plan.SourceDir, plan.TargetDir, conflictPatterns); err != nil {
+			return nil, err
 		}
 	}
+	sourceFiles = s.fileFilterService.FilterIgnoredFiles(sourceFiles, plan.SourceDir, scope.ignorePatterns)
 
-	if mkdirErr := os.MkdirAll(rulesEnvDir, os.ModePerm); mkdirErr != nil {
-		return nil, fmt.Errorf("failed to create destination directory %s: %w", rulesEnvDir, mkdirErr)
+	if direction == models.DirectionPull {
+		plan.SourceCommit, plan.SourceHashes, err = s.describeSource(plan.SourceDir, sourceFiles)
+		if err != nil {
+			return nil, err
+		}
+		s.warnUnmatchedOverrides(plan, sourceFiles)
 	}
 
-	// Clean up extra files in destination that don't exist in source
-	// Use pattern-aware cleanup
-	effectivePatterns := s.fileFilterService.GetEffectivePatterns(filePatterns)
-	if len(effectivePatterns) == 0 {
-		// No patterns - cleanup all extra files
-		if err := s.cleanupExtraFiles(projectFiles, rulesSourceDirInProject, rulesEnvDir, nil); err != nil {
-			return nil, fmt.Errorf("failed to cleanup extra files: %w", err)
-		}
-	} else {
-		// Use pattern-aware cleanup
-		if err := s.fileFilterService.CleanupExtraFilesByPatterns(projectFiles, rulesSourceDirInProject, rulesEnvDir, effectivePatterns); err != nil {
-			return nil, fmt.Errorf("failed to cleanup extra files: %w", err)
-		}
+	// The state manifest records bodies at the last sync to tell one-sided changes from conflicts
+	state, err := s.loadSyncState(scope.projectRulesDir)
+	if err != nil {
+		return nil, err
 	}
+	plan.NextState = copySyncState(state)
 
-	result := &models.SyncResult{
-		Operations: []models.FileOperation{},
-		HasChanges: false,
+	// Deletions come first so that the plan mirrors the order of execution
+	if err := s.planDeletes(plan, sourceFiles, scope.patterns, scope.ignorePatterns, state); err != nil {
+		return nil, fmt.Errorf("failed to plan deletions: %w", err)
 	}
+	s.planCopies(plan, sourceFiles, state)
+
+	return plan, nil
+}
 
-	if len(projectFiles) == 0 {
-		// No files to process, but we might have deleted some files above
-		return result, nil
+// Apply executes a plan produced by Plan and commits the result when pushing.
+// All writes are staged as temp files and renamed into place together with the deletions. If any operation fails,
+// the destination is restored to its state before the sync and the failures are recorded in the result errors.
+// When any file failed, including files that failed to plan, or the commit failed, a SyncError is returned with the result.
+func (s *SyncService) Apply(plan *models.SyncPlan) (*models.SyncResult, error) {
+	if mkdirErr := s.fileSystem.MkdirAll(plan.TargetDir, os.ModePerm); mkdirErr != nil {
+		return nil, fmt.Errorf("failed to create destination directory %s: %w", plan.TargetDir, mkdirErr)
 	}
 
-	// Copy files with proper directory structure
-	for _, srcFileFullPath := range projectFiles {
-		dstFileFullPath, err := s.RecreateDirectoryStructure(srcFileFullPath, rulesSourceDirInProject, rulesEnvDir)
-		if err != nil {
-			s.outputService.PrintErrorf("Error recreating directory structure for %s: %v\n", srcFileFullPath, err)
-			continue
-		}
+	result := s.newSyncResult(plan)
+	failures := planFailures(plan)
 
-		// Get relative path for display
-		relativePath, err := s.GetRelativePath(srcFileFullPath, rulesSourceDirInProject)
-		if err != nil {
-			relativePath = filepath.Base(srcFileFullPath)
+	// Every operation is staged before the destination is touched, so all failures are reported
+	transaction := newSyncTransaction(s.fileSystem)
+	if err := transaction.removeStaleTempFiles(plan.TargetDir); err != nil {
+		s.outputService.PrintWarningf("Failed to remove temp files left in %s by an interrupted sync: %v", plan.TargetDir, err)
+	}
+	applyFailed := false
+	for _, operation := range plan.Operations {
+		if err := s.stageOperation(transaction, operation, plan.Options); err != nil {
+			failures = append(failures, s.recordFailure(result, operation, err))
+			applyFailed = true
 		}
-
-		fileExists := true
-		if _, statErr := os.Stat(dstFileFullPath); os.IsNotExist(statErr) {
-			fileExists = false
-		} else if statErr != nil {
-			s.outputService.PrintErrorf("Error checking destination file %s in %s: %v\n", relativePath, rulesEnvDir, statErr)
-			continue
+	}
+	if !applyFailed {
+		if index, err := transaction.commit(); err != nil {
+			failures = append(failures, s.recordFailure(result, plan.Operations[index], err))
+			applyFailed = true
 		}
-
-		// Check if files are different before copying
-		shouldCopy := true
-		if fileExists {
-			equal, err := s.filesAreEqualBasedOnExtension(srcFileFullPath, dstFileFullPath, options.OverwriteHeaders)
-			if err != nil {
-				s.outputService.PrintErrorf("Error comparing files %s: %v\n", relativePath, err)
-				// Continue with copying in case of comparison error
-			} else if equal {
-				shouldCopy = false // Files are identical, no need to copy
-			}
+	}
+	if applyFailed {
+		if err := transaction.rollback(); err != nil {
+			s.outputService.PrintErrorf("Failed to restore %s to its state before the sync: %v\n", plan.TargetDir, err)
+		} else {
+			s.outputService.PrintWarningf("Sync failed, %s was restored to its state before the sync", plan.TargetDir)
 		}
+		return result, newSyncError(plan.Direction, failures, nil)
+	}
+
+	for _, operation := range plan.Operations {
+		s.printOperation(plan, operation)
+		s.recordOperation(result, operation)
+	}
 
-		if shouldCopy {
-			copyErr := s.copyFileBasedOnExtension(srcFileFullPath, dstFileFullPath, options.OverwriteHeaders)
-			if copyErr != nil {
-				s.outputService.PrintErrorf("Error synchronizing file %s to %s: %v\n", relativePath, rulesEnvDir, copyErr)
-			} else {
-				operationType := "update"
-				if !fileExists {
-					operationType = "add"
-				}
-				s.outputService.PrintOperationWithTarget(operationType, relativePath, filepath.Base(rulesEnvDir))
+	// Only commit if we have changes, deletions alone are changes too
+	var commitErr error
+	if plan.Direction == models.DirectionPush && result.HasChanges {
+		var commitMessage string
+		commitMessage, commitErr = s.buildCommitMessage(plan, result.Operations)
+		if commitErr == nil {
+			result.CommitSHA, result.Pushed, commitErr = s.commitChanges(plan.TargetDir, commitMessage, syncedPaths(result.Operations), plan.Options)
+		}
+		if commitErr != nil {
+			s.outputService.PrintErrorf("Commit failed for %s: %v\n", plan.TargetDir, commitErr)
+			result.CommitError = commitErr.Error()
+		}
+	}
 
-				result.Operations = append(result.Operations, models.FileOperation{
-					Type:         models.OperationType(operationType),
-					SourcePath:   srcFileFullPath,
-					TargetPath:   dstFileFullPath,
-					RelativePath: relativePath,
-				})
-				result.HasChanges = true
-			}
+	// Without a commit the synced files would be left as uncommitted changes the next push can't tell from
+	// unrelated ones, so the rules directory is restored and the push can simply be retried
+	if commitErr != nil && result.CommitSHA == "" {
+		if err := transaction.rollback(); err != nil {
+			s.outputService.PrintErrorf("Failed to restore %s to its state before the sync: %v\n", plan.TargetDir, err)
+		} else {
+			s.outputService.PrintWarningf("Commit failed, %s was restored to its state before the sync", plan.TargetDir)
 		}
 	}
 
-	// Only commit if we have changes
-	if result.HasChanges {
-		if err := s.commitChanges(rulesEnvDir, "Sync cursor rules: updated from project "+filepath.Base(projectGitRoot), options.GitWithoutPush); err != nil {
-			s.outputService.PrintErrorf("Commit failed for %s: %v\n", rulesEnvDir, err)
+	// The state records what both sides hold, so a push that failed to commit or push keeps the previous state
+	if plan.NextState != nil && commitErr == nil {
+		if err := s.saveSyncState(projectRulesDir(plan), plan.NextState); err != nil {
+			s.outputService.PrintWarningf("Could not save sync state: %v", err)
 		}
 	}
 
-	return result, nil
+	return result, newSyncError(plan.Direction, failures, commitErr)
 }
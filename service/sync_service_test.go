@@ -0,0 +1,588 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+func TestApplyPushCommitsDeletions(t *testing.T) {
+	repoDir := initTestRepo(t)
+	writeFileSystemFiles(t, NewOSFileSystem(), repoDir, map[string]string{
+		"keep.mdc":  "keep\n",
+		"stale.mdc": "stale\n",
+	})
+	runTestGit(t, repoDir, "add", "-A")
+	runTestGit(t, repoDir, "commit", "-m", "initial")
+
+	var stdout bytes.Buffer
+	outputService := NewOutputServiceWithWriters(&stdout, &stdout)
+	syncService := NewSyncService(outputService, NewExecGitClient(), NewOSFileSystem())
+
+	plan := &models.SyncPlan{
+		Direction:   models.DirectionPush,
+		SourceDir:   t.TempDir(),
+		TargetDir:   repoDir,
+		ProjectRoot: "/projects/demo",
+		Operations: []models.FileOperation{
+			{
+				Type:         models.OperationDelete,
+				TargetPath:   filepath.Join(repoDir, "stale.mdc"),
+				RelativePath: "stale.mdc",
+				Reason:       reasonNotInSource,
+			},
+		},
+		Options: models.SyncOptions{GitWithoutPush: true},
+	}
+
+	result, err := syncService.Apply(plan)
+	if err != nil {
+		t.Fatalf("Unexpected error applying plan: %v", err)
+	}
+
+	if !result.HasChanges {
+		t.Errorf("Expected a delete-only sync to report changes")
+	}
+	if result.Summary.Deleted != 1 || len(result.Operations) != 1 {
+		t.Errorf("Expected one recorded deletion, got %+v", result)
+	}
+
+	if head := runTestGit(t, repoDir, "rev-parse", "HEAD"); result.CommitSHA != head {
+		t.Errorf("Expected commit SHA %s, got %q", head, result.CommitSHA)
+	}
+	if result.Pushed {
+		t.Errorf("Expected no push with GitWithoutPush")
+	}
+
+	status := runTestGit(t, repoDir, "status", "--porcelain")
+	if status != "" {
+		t.Errorf("Expected deletion to be committed, got status %q", status)
+	}
+
+	subject := runTestGit(t, repoDir, "log", "-1", "--format=%s")
+	if subject != "Sync cursor rules: updated from project demo" {
+		t.Errorf("Unexpected commit subject %q", subject)
+	}
+}
+
+func TestApplyPushWithFakeGitClient(t *testing.T) {
+	tests := []struct {
+		originURL      string
+		gitWithoutPush bool
+		pushErr        error
+		expectedPushed bool
+		expectedErr    bool
+		description    string
+	}{
+		{
+			originURL:      "git@example.com:org/rules.git",
+			expectedPushed: true,
+			description:    "Commit should be pushed when origin exists",
+		},
+		{
+			originURL:      "git@example.com:org/rules.git",
+			gitWithoutPush: true,
+			description:    "Commit should not be pushed with GitWithoutPush",
+		},
+		{
+			description: "Commit should not be pushed without origin",
+		},
+		{
+			originURL:   "git@example.com:org/rules.git",
+			pushErr:     errors.New("rejected"),
+			expectedErr: true,
+			description: "Push failure should be recorded as commit error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			projectDir := t.TempDir()
+			rulesDir := t.TempDir()
+			writeFileSystemFiles(t, NewOSFileSystem(), projectDir, map[string]string{"rule.mdc": "v2\n"})
+			writeFileSystemFiles(t, NewOSFileSystem(), rulesDir, map[string]string{
+				"rule.mdc":  "v1\n",
+				"stale.mdc": "stale\n",
+				"notes.txt": "notes\n",
+			})
+
+			fileSystem := NewOSFileSystem()
+			git, err := newFakeGitClient(fileSystem, rulesDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo := git.repos[rulesDir]
+			repo.originURL = test.originURL
+			repo.pushErr = test.pushErr
+			writeFileSystemFiles(t, NewOSFileSystem(), rulesDir, map[string]string{"notes.txt": "unrelated edit\n"})
+
+			var stdout bytes.Buffer
+			syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), git, fileSystem)
+			plan := &models.SyncPlan{
+				Direction:   models.DirectionPush,
+				SourceDir:   projectDir,
+				TargetDir:   rulesDir,
+				ProjectRoot: "/projects/demo",
+				Operations: []models.FileOperation{
+					{
+						Type:         models.OperationDelete,
+						TargetPath:   filepath.Join(rulesDir, "stale.mdc"),
+						RelativePath: "stale.mdc",
+					},
+					{
+						Type:         models.OperationUpdate,
+						SourcePath:   filepath.Join(projectDir, "rule.mdc"),
+						TargetPath:   filepath.Join(rulesDir, "rule.mdc"),
+						RelativePath: "rule.mdc",
+					},
+				},
+				Options: models.SyncOptions{
+					GitWithoutPush: test.gitWithoutPush,
+					CommitMessage:  "rules: sync {{.Project}}",
+					Author:         "Rules Bot <bot@example.com>",
+				},
+			}
+
+			result, err := syncService.Apply(plan)
+			var syncErr *SyncError
+			if test.expectedErr {
+				if !errors.As(err, &syncErr) || !errors.Is(err, test.pushErr) || len(syncErr.Files) != 0 {
+					t.Fatalf("Expected a SyncError wrapping the push failure, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error applying plan: %v", err)
+			}
+
+			if len(repo.commits) != 2 {
+				t.Fatalf("Expected one new commit, got %+v", repo.commits)
+			}
+			commit := repo.commits[1]
+			if result.CommitSHA != commit.sha {
+				t.Errorf("Expected commit SHA %s, got %q", commit.sha, result.CommitSHA)
+			}
+			if commit.message != "rules: sync demo" {
+				t.Errorf("Unexpected commit message %q", commit.message)
+			}
+			if commit.options.Author != "Rules Bot <bot@example.com>" {
+				t.Errorf("Expected author override to be passed, got %+v", commit.options)
+			}
+			if strings.Join(commit.paths, ",") != "stale.mdc,rule.mdc" {
+				t.Errorf("Expected only synced paths to be committed, got %v", commit.paths)
+			}
+			if status, _ := git.Status(rulesDir); strings.Join(status, ",") != "notes.txt" {
+				t.Errorf("Expected unrelated edit to stay uncommitted, got status %v", status)
+			}
+
+			if result.Pushed != test.expectedPushed {
+				t.Errorf("Expected pushed=%v, got %v", test.expectedPushed, result.Pushed)
+			}
+			if (result.CommitError != "") != test.expectedErr {
+				t.Errorf("Unexpected commit error %q", result.CommitError)
+			}
+		})
+	}
+}
+
+func TestApplyReturnsSyncErrorForFailedFiles(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	bundleDir := filepath.Join(string(filepath.Separator), "bundle")
+	base := NewMemoryFileSystem()
+	writeFileSystemFiles(t, base, projectDir, map[string]string{"rule.mdc": "v2\n", "new.mdc": "new\n"})
+	fileSystem := NewMountedFileSystem(base, bundleDir, fstest.MapFS{"rule.mdc": {Data: []byte("v1\n")}})
+
+	var stdout bytes.Buffer
+	syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), NewExecGitClient(), fileSystem)
+	plan := &models.SyncPlan{
+		Direction: models.DirectionPull,
+		SourceDir: projectDir,
+		TargetDir: bundleDir,
+		Operations: []models.FileOperation{
+			{
+				Type:         models.OperationUpdate,
+				SourcePath:   filepath.Join(projectDir, "rule.mdc"),
+				TargetPath:   filepath.Join(bundleDir, "rule.mdc"),
+				RelativePath: "rule.mdc",
+			},
+		},
+		Errors: []models.FileError{{Type: models.OperationAdd, RelativePath: "new.mdc", Error: "unreadable"}},
+	}
+
+	result, err := syncService.Apply(plan)
+	if result == nil {
+		t.Fatalf("Expected a result alongside the error, got %v", err)
+	}
+	var syncErr *SyncError
+	if !errors.As(err, &syncErr) {
+		t.Fatalf("Expected SyncError, got %v", err)
+	}
+	if len(syncErr.Files) != 2 || syncErr.Files[0].RelativePath != "new.mdc" || syncErr.Files[1].RelativePath != "rule.mdc" {
+		t.Errorf("Expected the planning and the write failure, got %v", syncErr.Files)
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Expected the write failure to unwrap to ErrPermission, got %v", err)
+	}
+	if len(result.Errors) != 2 {
+		t.Errorf("Expected both failures in the result, got %+v", result.Errors)
+	}
+}
+
+func TestSyncRulesInMemory(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	projectRulesDir := filepath.Join(projectDir, cursorDirName, rulesDirName)
+	centralDir := filepath.Join(string(filepath.Separator), "central")
+	rulesDir := filepath.Join(centralDir, rulesDirName)
+
+	tests := []struct {
+		direction       models.SyncDirection
+		project         map[string]string // Project rules before the sync
+		central         map[string]string // Central rules before the sync
+		unrelated       map[string]string // Uncommitted files of the central repository written after its initial commit
+		force           bool
+		expectedProject map[string]string
+		expectedCentral map[string]string
+		expectedCommit  []string // Paths of the new central commit, nil when nothing is committed
+		expectedErr     bool
+		description     string
+	}{
+		{
+			direction:       models.DirectionPull,
+			project:         map[string]string{"old.mdc": "old\n"},
+			central:         map[string]string{"a.mdc": "a\n", "lang/go.mdc": "go\n"},
+			expectedProject: map[string]string{"a.mdc": "a\n", "lang/go.mdc": "go\n"},
+			expectedCentral: map[string]string{"a.mdc": "a\n", "lang/go.mdc": "go\n"},
+			description:     "Pull should copy central rules and delete project-only rules",
+		},
+		{
+			direction:       models.DirectionPull,
+			project:         map[string]string{"rule.mdc": "---\ndescription: local\n---\nv1\n"},
+			central:         map[string]string{"rule.mdc": "---\ndescription: central\n---\nv2\n"},
+			expectedProject: map[string]string{"rule.mdc": "---\ndescription: local\n---\nv2\n"},
+			expectedCentral: map[string]string{"rule.mdc": "---\ndescription: central\n---\nv2\n"},
+			description:     "Pull should keep project headers",
+		},
+		{
+			direction:       models.DirectionPull,
+			central:         map[string]string{".ruleignore": "drafts/\n", "a.mdc": "a\n", "drafts/wip.mdc": "wip\n"},
+			expectedProject: map[string]string{"a.mdc": "a\n"},
+			expectedCentral: map[string]string{".ruleignore": "drafts/\n", "a.mdc": "a\n", "drafts/wip.mdc": "wip\n"},
+			description:     "Pull should skip centrally ignored rules",
+		},
+		{
+			direction:       models.DirectionPush,
+			project:         map[string]string{"a.mdc": "a v2\n", "new.mdc": "new\n"},
+			central:         map[string]string{"a.mdc": "a\n", "stale.mdc": "stale\n"},
+			expectedProject: map[string]string{"a.mdc": "a v2\n", "new.mdc": "new\n"},
+			expectedCentral: map[string]string{"a.mdc": "a v2\n", "new.mdc": "new\n"},
+			expectedCommit:  []string{"rules/stale.mdc", "rules/a.mdc", "rules/new.mdc"},
+			description:     "Push should mirror project rules and commit them",
+		},
+		{
+			direction:       models.DirectionPush,
+			project:         map[string]string{"a.mdc": "a v2\n"},
+			central:         map[string]string{"a.mdc": "a\n"},
+			unrelated:       map[string]string{"README.md": "readme\n"},
+			expectedProject: map[string]string{"a.mdc": "a v2\n"},
+			expectedCentral: map[string]string{"a.mdc": "a\n"},
+			expectedErr:     true,
+			description:     "Push should refuse unrelated central changes",
+		},
+		{
+			direction:       models.DirectionPush,
+			project:         map[string]string{"a.mdc": "a v2\n"},
+			central:         map[string]string{"a.mdc": "a\n"},
+			unrelated:       map[string]string{"README.md": "readme\n"},
+			force:           true,
+			expectedProject: map[string]string{"a.mdc": "a v2\n"},
+			expectedCentral: map[string]string{"a.mdc": "a v2\n"},
+			expectedCommit:  []string{"rules/a.mdc"},
+			description:     "Forced push should commit only synced rules",
+		},
+		{
+			direction:       models.DirectionPush,
+			project:         map[string]string{"a.mdc": "a v2\n"},
+			central:         map[string]string{"a.mdc": "a\n", "stale.mdc": "stale\n"},
+			unrelated:       map[string]string{"rules/junk.txt": "junk\n", "rules/stale.mdc": "edited\n"},
+			expectedProject: map[string]string{"a.mdc": "a v2\n"},
+			expectedCentral: map[string]string{"a.mdc": "a\n", "junk.txt": "junk\n", "stale.mdc": "edited\n"},
+			expectedErr:     true,
+			description:     "Push should refuse to delete untracked or modified central files",
+		},
+		{
+			direction:       models.DirectionPush,
+			project:         map[string]string{"a.mdc": "a v2\n"},
+			central:         map[string]string{"a.mdc": "a\n"},
+			unrelated:       map[string]string{"rules/junk.txt": "junk\n"},
+			force:           true,
+			expectedProject: map[string]string{"a.mdc": "a v2\n"},
+			expectedCentral: map[string]string{"a.mdc": "a v2\n"},
+			expectedCommit:  []string{"rules/a.mdc"},
+			description:     "Forced push should delete untracked central files",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			fileSystem := NewMemoryFileSystem()
+			if err := fileSystem.MkdirAll(projectRulesDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			writeFileSystemFiles(t, fileSystem, projectRulesDir, test.project)
+			writeFileSystemFiles(t, fileSystem, rulesDir, test.central)
+
+			git, err := newFakeGitClient(fileSystem, projectDir, centralDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeFileSystemFiles(t, fileSystem, centralDir, test.unrelated)
+
+			var stdout bytes.Buffer
+			syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), git, fileSystem)
+			options := &models.SyncOptions{
+				RulesDir:       rulesDir,
+				ProjectDir:     projectDir,
+				GitWithoutPush: true,
+				Force:          test.force,
+			}
+
+			if test.direction == models.DirectionPull {
+				_, err = syncService.PullRules(options)
+			} else {
+				_, err = syncService.PushRules(options)
+			}
+			if test.expectedErr {
+				if err == nil {
+					t.Errorf("Expected an error")
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			projectFiles := readFileSystemFiles(t, fileSystem, projectRulesDir)
+			delete(projectFiles, syncStateFileName)
+			assertFiles(t, "project", test.expectedProject, projectFiles)
+			assertFiles(t, "central", test.expectedCentral, readFileSystemFiles(t, fileSystem, rulesDir))
+
+			commits := git.repos[centralDir].commits
+			switch {
+			case test.expectedCommit == nil && len(commits) > 1:
+				t.Errorf("Expected no central commit, got %+v", commits[1:])
+			case test.expectedCommit != nil && len(commits) != 2:
+				t.Errorf("Expected one central commit, got %+v", commits)
+			case test.expectedCommit != nil && strings.Join(commits[1].paths, ",") != strings.Join(test.expectedCommit, ","):
+				t.Errorf("Expected commit of %v, got %v", test.expectedCommit, commits[1].paths)
+			}
+		})
+	}
+}
+
+func TestPushKeepsSyncStateWhenPushFails(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	projectRulesDir := filepath.Join(projectDir, cursorDirName, rulesDirName)
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+
+	fileSystem := NewMemoryFileSystem()
+	writeFileSystemFiles(t, fileSystem, projectRulesDir, map[string]string{"a.mdc": "a v2\n"})
+	writeFileSystemFiles(t, fileSystem, rulesDir, map[string]string{"a.mdc": "a\n"})
+	git, err := newFakeGitClient(fileSystem, projectDir, rulesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := git.repos[rulesDir]
+	repo.originURL = "https://example.com/rules.git"
+	repo.pushErr = errors.New("remote rejected")
+
+	var stdout bytes.Buffer
+	syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), git, fileSystem)
+	options := &models.SyncOptions{RulesDir: rulesDir, ProjectDir: projectDir}
+	if _, err := syncService.PushRules(options); !errors.Is(err, repo.pushErr) {
+		t.Fatalf("Expected the push failure, got %v", err)
+	}
+	if _, err := fileSystem.Stat(filepath.Join(projectRulesDir, syncStateFileName)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected no sync state after a failed push, got %v", err)
+	}
+
+	repo.pushErr = nil
+	if _, err := syncService.PushRules(options); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := fileSystem.Stat(filepath.Join(projectRulesDir, syncStateFileName)); err != nil {
+		t.Errorf("Expected sync state after a successful push, got %v", err)
+	}
+}
+
+func TestPushRestoresRulesWhenCommitFails(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	projectRulesDir := filepath.Join(projectDir, cursorDirName, rulesDirName)
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+
+	fileSystem := NewMemoryFileSystem()
+	writeFileSystemFiles(t, fileSystem, projectRulesDir, map[string]string{"a.mdc": "a v2\n", "new.mdc": "new\n"})
+	writeFileSystemFiles(t, fileSystem, rulesDir, map[string]string{"a.mdc": "a\n", "stale.mdc": "stale\n"})
+	git, err := newFakeGitClient(fileSystem, projectDir, rulesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := git.repos[rulesDir]
+	repo.commitErr = errors.New("gpg failed to sign the data")
+
+	var stdout bytes.Buffer
+	syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), git, fileSystem)
+	options := &models.SyncOptions{RulesDir: rulesDir, ProjectDir: projectDir, GitWithoutPush: true}
+	if _, err := syncService.PushRules(options); !errors.Is(err, repo.commitErr) {
+		t.Fatalf("Expected the commit failure, got %v", err)
+	}
+	assertFiles(t, "central", map[string]string{"a.mdc": "a\n", "stale.mdc": "stale\n"}, readFileSystemFiles(t, fileSystem, rulesDir))
+	if changed, _ := git.Status(rulesDir); len(changed) != 0 {
+		t.Errorf("Expected no uncommitted changes after a failed commit, got %v", changed)
+	}
+	if _, err := fileSystem.Stat(filepath.Join(projectRulesDir, syncStateFileName)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected no sync state after a failed commit, got %v", err)
+	}
+
+	// The push can be retried once the commit works
+	repo.commitErr = nil
+	if _, err := syncService.PushRules(options); err != nil {
+		t.Fatalf("Unexpected error retrying: %v", err)
+	}
+	assertFiles(t, "central", map[string]string{"a.mdc": "a v2\n", "new.mdc": "new\n"}, readFileSystemFiles(t, fileSystem, rulesDir))
+	if len(repo.commits) != 2 {
+		t.Errorf("Expected the retry to commit, got %+v", repo.commits)
+	}
+}
+
+func assertFiles(t *testing.T, side string, expected, actual map[string]string) {
+	t.Helper()
+	if len(expected) != len(actual) {
+		t.Errorf("Expected %s files %v, got %v", side, expected, actual)
+		return
+	}
+	for relativePath, content := range expected {
+		if actual[relativePath] != content {
+			t.Errorf("Expected %s file %s to be %q, got %q", side, relativePath, content, actual[relativePath])
+		}
+	}
+}
+
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	repoDir := t.TempDir()
+	runTestGit(t, repoDir, "init", "-q")
+	return repoDir
+}
+
+func runTestGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func TestDryRunChangesNothing(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	projectRulesDir := filepath.Join(projectDir, cursorDirName, rulesDirName)
+	centralDir := filepath.Join(string(filepath.Separator), "central")
+	rulesDir := filepath.Join(string(filepath.Separator), "clone")
+	url := "https://example.com/rules.git"
+
+	tests := []struct {
+		run         func(syncService *SyncService, options *models.SyncOptions) (*models.SyncResult, error)
+		branch      string
+		description string
+	}{
+		{
+			run:         (*SyncService).PullRules,
+			description: "Dry run pull should not write the project rules, the sync state or rules.lock",
+		},
+		{
+			run:         (*SyncService).PushRules,
+			description: "Dry run push should not write, commit or push the rules",
+		},
+		{
+			run:         (*SyncService).PushRules,
+			branch:      "rules/<project>",
+			description: "Dry run push to a branch should not create the branch or a worktree",
+		},
+		{
+			run:         (*SyncService).UpdateRules,
+			description: "Dry run update should not move the pin in rules.lock or update the rules repository",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			fileSystem := NewMemoryFileSystem()
+			if err := fileSystem.MkdirAll(projectRulesDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			writeFileSystemFiles(t, fileSystem, centralDir, map[string]string{"rule.mdc": "v1\n", "old.mdc": "old\n"})
+			git, err := newFakeGitClient(fileSystem, projectDir, centralDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			git.serve(url, centralDir)
+			if err := git.Clone(url, rulesDir); err != nil {
+				t.Fatal(err)
+			}
+
+			var stdout bytes.Buffer
+			syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), git, fileSystem)
+			options := models.SyncOptions{RulesDir: rulesDir, ProjectDir: projectDir}
+			if _, err := syncService.PullRules(&options); err != nil {
+				t.Fatalf("Unexpected error pulling: %v", err)
+			}
+
+			// Every entry point now has something to sync: a local commit in the clone, a commit on origin
+			// the clone has not fetched and changed project rules
+			git.commitFiles(t, rulesDir, "local", map[string]string{"rule.mdc": "v2\n", "new.mdc": "new\n"})
+			git.commitFiles(t, centralDir, "upstream", map[string]string{"upstream.mdc": "upstream\n"})
+			writeFileSystemFiles(t, fileSystem, projectRulesDir, map[string]string{"old.mdc": "changed\n", "extra.mdc": "extra\n"})
+
+			filesBefore := readFileSystemFiles(t, fileSystem, string(filepath.Separator))
+			gitBefore := git.snapshot()
+
+			dryRunOptions := options
+			dryRunOptions.DryRun = true
+			dryRunOptions.Branch = test.branch
+			result, err := test.run(syncService, &dryRunOptions)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !result.DryRun || !result.HasChanges {
+				t.Errorf("Expected a dry run with changes, got %+v", result)
+			}
+
+			assertFiles(t, "file system", filesBefore, readFileSystemFiles(t, fileSystem, string(filepath.Separator)))
+			gitAfter := git.snapshot()
+			if len(gitAfter) != len(gitBefore) {
+				t.Errorf("Expected repositories %v, got %v", gitBefore, gitAfter)
+			}
+			for root, state := range gitBefore {
+				if gitAfter[root] != state {
+					t.Errorf("Expected %s to stay\n%s\ngot\n%s", root, state, gitAfter[root])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempFileSuffix marks the files a sync writes next to their destination before renaming them into place
+const tempFileSuffix = ".sync-tmp"
+
+// syncTransaction stages the file changes of a sync so they are applied together.
+// Writes go to temp files next to their destination until commit renames them into place and makes
+// the deletions, keeping the original of every replaced or deleted file so rollback can restore it.
+type syncTransaction struct {
+	fileSystem  FileSystem
+	changes     []stagedChange
+	backups     []fileBackup // Originals of the committed changes, in commit order
+	createdDirs []string     // Directories created for staged files, parents first
+}
+
+// stagedChange is a write or deletion waiting for commit
+type stagedChange struct {
+	path     string
+	tempPath string // Empty for deletions
+}
+
+// fileBackup is the state of a path before the transaction changed it
+type fileBackup struct {
+	path    string
+	existed bool
+	content []byte
+	mode    fs.FileMode
+}
+
+func newSyncTransaction(fileSystem FileSystem) *syncTransaction {
+	return &syncTransaction{fileSystem: fileSystem}
+}
+
+// removeStaleTempFiles deletes the temp files a killed sync left behind under dir, skipping the .git directory
+func (t *syncTransaction) removeStaleTempFiles(dir string) error {
+	var errs []error
+	err := t.fileSystem.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == gitDirName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isTempFileName(entry.Name()) {
+			if err := t.fileSystem.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+		return nil
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+// stageWrite writes content to a temp file next to path, creating missing parent directories.
+// The temp file takes the permissions of the file it replaces so an update keeps them.
+func (t *syncTransaction) stageWrite(path string, content []byte) error {
+	perm := fs.FileMode(0644)
+	if info, err := t.fileSystem.Stat(path); err == nil && info.Mode().IsRegular() {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	var missingDirs []string
+	for missing := dir; ; missing = filepath.Dir(missing) {
+		if _, err := t.fileSystem.Stat(missing); err == nil || filepath.Dir(missing) == missing {
+			break
+		}
+		missingDirs = append([]string{missing}, missingDirs...)
+	}
+	if err := t.fileSystem.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	t.createdDirs = append(t.createdDirs, missingDirs...)
+
+	tempPath := tempFilePath(path)
+	if err := t.fileSystem.WriteFile(tempPath, content, perm); err != nil {
+		return fmt.Errorf("failed to write destination file %s: %w", path, err)
+	}
+	t.changes = append(t.changes, stagedChange{path: path, tempPath: tempPath})
+	return nil
+}
+
+// stageDelete schedules the deletion of path
+func (t *syncTransaction) stageDelete(path string) {
+	t.changes = append(t.changes, stagedChange{path: path})
+}
+
+// commit applies the staged changes in order. On failure it returns the index of the failing change,
+// the changes before it stay applied until rollback is called.
+func (t *syncTransaction) commit() (int, error) {
+	for i, change := range t.changes {
+		backup, err := t.backup(change.path)
+		if err != nil {
+			return i, err
+		}
+
+		if change.tempPath != "" {
+			if err := t.fileSystem.Rename(change.tempPath, change.path); err != nil {
+				return i, fmt.Errorf("failed to replace destination file %s: %w", change.path, err)
+			}
+		} else if err := t.fileSystem.Remove(change.path); err != nil {
+			return i, err
+		}
+		t.backups = append(t.backups, backup)
+	}
+	return len(t.changes), nil
+}
+
+// backup reads the current state of path
+func (t *syncTransaction) backup(path string) (fileBackup, error) {
+	info, err := t.fileSystem.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fileBackup{path: path}, nil
+	}
+	if err != nil {
+		return fileBackup{}, fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	content, err := t.fileSystem.ReadFile(path)
+	if err != nil {
+		return fileBackup{}, fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	return fileBackup{path: path, existed: true, content: content, mode: info.Mode().Perm()}, nil
+}
+
+// rollback removes the temp files of uncommitted changes and restores the originals of committed ones
+// in reverse order, then removes the directories created for staged files if they are empty
+func (t *syncTransaction) rollback() error {
+	var errs []error
+	for _, change := range t.changes[len(t.backups):] {
+		if change.tempPath == "" {
+			continue
+		}
+		if err := t.fileSystem.Remove(change.tempPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	for i := len(t.backups) - 1; i >= 0; i-- {
+		backup := t.backups[i]
+		var err error
+		if backup.existed {
+			err = writeFileAtomic(t.fileSystem, backup.path, backup.content, backup.mode)
+		} else if err = t.fileSystem.Remove(backup.path); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore %s: %w", backup.path, err))
+		}
+	}
+
+	// Directories still holding other files are kept
+	for i := len(t.createdDirs) - 1; i >= 0; i-- {
+		_ = t.fileSystem.Remove(t.createdDirs[i])
+	}
+
+	t.changes, t.backups, t.createdDirs = nil, nil, nil
+	return errors.Join(errs...)
+}
+
+// writeFileAtomic writes data to a temp file next to name and renames it over name,
+// so an interrupted write never leaves a partially written file behind
+func writeFileAtomic(fileSystem FileSystem, name string, data []byte, perm fs.FileMode) error {
+	tempPath := tempFilePath(name)
+	if err := fileSystem.WriteFile(tempPath, data, perm); err != nil {
+		return err
+	}
+	if err := fileSystem.Rename(tempPath, name); err != nil {
+		_ = fileSystem.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// isTempFileName reports whether a file name is one of the temp files written by tempFilePath
+func isTempFileName(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, tempFileSuffix)
+}
+
+// tempFilePath returns the hidden temp file used while writing path
+func tempFilePath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+tempFileSuffix)
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/yanodintsovmercuryo/cursor-rules-syncer/models"
+)
+
+// failingRenameFileSystem fails renames onto one path
+type failingRenameFileSystem struct {
+	FileSystem
+	failPath string
+}
+
+func (f *failingRenameFileSystem) Rename(oldname, newname string) error {
+	if newname == f.failPath {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrPermission}
+	}
+	return f.FileSystem.Rename(oldname, newname)
+}
+
+func TestApplyRollsBackOnFailure(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+	targetFiles := map[string]string{
+		"rule.mdc":  "old rule\n",
+		"stale.mdc": "stale\n",
+		"z.mdc":     "z1\n",
+	}
+
+	tests := []struct {
+		sourceFiles map[string]string
+		failPath    string
+		expectedErr error
+		description string
+	}{
+		{
+			sourceFiles: map[string]string{"rule.mdc": "new rule\n", "nested/new.mdc": "new\n", "z.mdc": "z2\n"},
+			failPath:    filepath.Join(projectDir, "z.mdc"),
+			expectedErr: fs.ErrPermission,
+			description: "Failure while renaming into place should restore replaced, deleted and added files",
+		},
+		{
+			sourceFiles: map[string]string{"rule.mdc": "new rule\n", "nested/new.mdc": "new\n"},
+			expectedErr: fs.ErrNotExist,
+			description: "Failure while staging should leave the destination untouched",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			memoryFileSystem := NewMemoryFileSystem()
+			writeFileSystemFiles(t, memoryFileSystem, rulesDir, test.sourceFiles)
+			writeFileSystemFiles(t, memoryFileSystem, projectDir, targetFiles)
+			fileSystem := &failingRenameFileSystem{FileSystem: memoryFileSystem, failPath: test.failPath}
+
+			var stdout bytes.Buffer
+			syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), NewExecGitClient(), fileSystem)
+			operation := func(operationType models.OperationType, relativePath string) models.FileOperation {
+				return models.FileOperation{
+					Type:         operationType,
+					SourcePath:   filepath.Join(rulesDir, filepath.FromSlash(relativePath)),
+					TargetPath:   filepath.Join(projectDir, filepath.FromSlash(relativePath)),
+					RelativePath: relativePath,
+				}
+			}
+			plan := &models.SyncPlan{
+				Direction: models.DirectionPull,
+				SourceDir: rulesDir,
+				TargetDir: projectDir,
+				Operations: []models.FileOperation{
+					operation(models.OperationUpdate, "rule.mdc"),
+					operation(models.OperationAdd, "nested/new.mdc"),
+					operation(models.OperationDelete, "stale.mdc"),
+					operation(models.OperationUpdate, "z.mdc"),
+				},
+			}
+
+			result, err := syncService.Apply(plan)
+			var syncErr *SyncError
+			if !errors.As(err, &syncErr) || !errors.Is(err, test.expectedErr) {
+				t.Fatalf("Expected a SyncError wrapping %v, got %v", test.expectedErr, err)
+			}
+			if len(syncErr.Files) != 1 || syncErr.Files[0].RelativePath != "z.mdc" {
+				t.Errorf("Expected only z.mdc to fail, got %v", syncErr.Files)
+			}
+			if len(result.Operations) != 0 || result.HasChanges {
+				t.Errorf("Expected no operations to be reported as applied, got %+v", result.Operations)
+			}
+
+			assertFiles(t, "project", targetFiles, readFileSystemFiles(t, memoryFileSystem, projectDir))
+			if _, err := memoryFileSystem.Stat(filepath.Join(projectDir, "nested")); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Expected the directory created for the new file to be removed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyKeepsFileMode(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+
+	tests := []struct {
+		targetMode   fs.FileMode // Zero when the target does not exist
+		expectedMode fs.FileMode
+		description  string
+	}{
+		{
+			targetMode:   0755,
+			expectedMode: 0755,
+			description:  "Updated file should keep its permissions",
+		},
+		{
+			targetMode:   0600,
+			expectedMode: 0600,
+			description:  "Updated private file should stay private",
+		},
+		{
+			expectedMode: 0644,
+			description:  "Added file should get the default permissions",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			fileSystem := NewMemoryFileSystem()
+			writeFileSystemFiles(t, fileSystem, rulesDir, map[string]string{"rule.mdc": "new rule\n"})
+			if err := fileSystem.MkdirAll(projectDir, os.ModePerm); err != nil {
+				t.Fatalf("Failed to create project dir: %v", err)
+			}
+			targetPath := filepath.Join(projectDir, "rule.mdc")
+			operationType := models.OperationAdd
+			if test.targetMode != 0 {
+				if err := fileSystem.WriteFile(targetPath, []byte("old rule\n"), test.targetMode); err != nil {
+					t.Fatalf("Failed to write target: %v", err)
+				}
+				operationType = models.OperationUpdate
+			}
+
+			var stdout bytes.Buffer
+			syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), NewExecGitClient(), fileSystem)
+			plan := &models.SyncPlan{
+				Direction: models.DirectionPull,
+				SourceDir: rulesDir,
+				TargetDir: projectDir,
+				Operations: []models.FileOperation{{
+					Type:         operationType,
+					SourcePath:   filepath.Join(rulesDir, "rule.mdc"),
+					TargetPath:   targetPath,
+					RelativePath: "rule.mdc",
+				}},
+			}
+			if _, err := syncService.Apply(plan); err != nil {
+				t.Fatalf("Apply failed: %v", err)
+			}
+
+			info, err := fileSystem.Stat(targetPath)
+			if err != nil {
+				t.Fatalf("Failed to stat target: %v", err)
+			}
+			if info.Mode().Perm() != test.expectedMode {
+				t.Errorf("Expected mode %v, got %v", test.expectedMode, info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestApplyRemovesStaleTempFiles(t *testing.T) {
+	projectDir := filepath.Join(string(filepath.Separator), "project")
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+
+	fileSystem := NewMemoryFileSystem()
+	writeFileSystemFiles(t, fileSystem, rulesDir, map[string]string{"rule.mdc": "new rule\n"})
+	writeFileSystemFiles(t, fileSystem, projectDir, map[string]string{
+		"rule.mdc":                   "old rule\n",
+		".rule.mdc.sync-tmp":         "partial\n",
+		"nested/.other.mdc.sync-tmp": "partial\n",
+		"nested/other.mdc":           "other\n",
+		".git/.index.sync-tmp":       "git\n",
+		".sync-state.json.sync-tmp":  "{}\n",
+		"not-temp.sync-tmp":          "kept\n",
+	})
+
+	var stdout bytes.Buffer
+	syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), NewExecGitClient(), fileSystem)
+	plan := &models.SyncPlan{
+		Direction: models.DirectionPull,
+		SourceDir: rulesDir,
+		TargetDir: projectDir,
+		Operations: []models.FileOperation{{
+			Type:         models.OperationUpdate,
+			SourcePath:   filepath.Join(rulesDir, "rule.mdc"),
+			TargetPath:   filepath.Join(projectDir, "rule.mdc"),
+			RelativePath: "rule.mdc",
+		}},
+	}
+	if _, err := syncService.Apply(plan); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"rule.mdc":             "new rule\n",
+		"nested/other.mdc":     "other\n",
+		".git/.index.sync-tmp": "git\n",
+		"not-temp.sync-tmp":    "kept\n",
+	}
+	assertFiles(t, "project", expected, readFileSystemFiles(t, fileSystem, projectDir))
+}
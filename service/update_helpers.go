@@ -0,0 +1,64 @@
+package service
+
+import (
+	"fmt"
+)
+
+// updateRulesRepo fetches origin of the repository containing rulesDir and brings the checked out branch up to date.
+// Directories outside git and repositories without origin are left as they are.
+func (s *SyncService) updateRulesRepo(rulesDir string) error {
+	if _, err := s.git.Root(rulesDir); err != nil {
+		return nil
+	}
+
+	originURL, err := s.git.RemoteURL(rulesDir)
+	if err != nil {
+		return err
+	}
+	if originURL == "" {
+		return nil
+	}
+
+	s.outputService.PrintInfo(fmt.Sprintf("Fetching rules from origin in %s", rulesDir))
+	if err := s.git.Fetch(rulesDir); err != nil {
+		return fmt.Errorf("failed to fetch origin, use --no-fetch to sync offline: %w", err)
+	}
+	return s.fastForwardOrRebase(rulesDir)
+}
+
+// fastForwardOrRebase moves the current branch to its upstream.
+// Without local commits the branch is fast-forwarded, otherwise the local commits are rebased and
+// a conflicting rebase is aborted so the repository is left as it was.
+func (s *SyncService) fastForwardOrRebase(repoDir string) error {
+	upstream, err := s.git.Upstream(repoDir)
+	if err != nil {
+		return err
+	}
+	if upstream == "" {
+		s.outputService.PrintWarningf("No upstream branch in %s, skipping update", repoDir)
+		return nil
+	}
+
+	ahead, behind, err := s.git.AheadBehind(repoDir, upstream)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case behind == 0:
+		return nil
+	case ahead == 0:
+		if err := s.git.MergeFastForward(repoDir, upstream); err != nil {
+			return fmt.Errorf("failed to fast-forward %s to %s: %w", repoDir, upstream, err)
+		}
+		return nil
+	}
+
+	if err := s.git.Rebase(repoDir, upstream); err != nil {
+		if abortErr := s.git.AbortRebase(repoDir); abortErr != nil {
+			return fmt.Errorf("failed to rebase %s onto %s: %w", repoDir, upstream, err)
+		}
+		return &RebaseConflictError{RepoDir: repoDir, Upstream: upstream}
+	}
+	return nil
+}
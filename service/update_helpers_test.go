@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateRulesRepo(t *testing.T) {
+	tests := []struct {
+		localChange      string
+		expectedContent  string
+		expectedConflict bool
+		description      string
+	}{
+		{
+			expectedContent: "line one\ncentral\n",
+			description:     "Branch without local commits should be fast-forwarded",
+		},
+		{
+			localChange:     "local.mdc",
+			expectedContent: "line one\ncentral\n",
+			description:     "Local commits should be rebased onto upstream",
+		},
+		{
+			localChange:      "rule.mdc",
+			expectedContent:  "line one\nlocal\n",
+			expectedConflict: true,
+			description:      "Conflicting rebase should be aborted with a clear error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			seedDir := initTestRepo(t)
+			t.Setenv("GIT_CONFIG_GLOBAL", "/dev/null")
+			writeFileSystemFiles(t, NewOSFileSystem(), seedDir, map[string]string{"rule.mdc": "line one\n"})
+			runTestGit(t, seedDir, "add", "-A")
+			runTestGit(t, seedDir, "commit", "-q", "-m", "initial")
+
+			bareDir := filepath.Join(t.TempDir(), "rules.git")
+			runTestGit(t, seedDir, "clone", "-q", "--bare", seedDir, bareDir)
+			rulesDir := filepath.Join(t.TempDir(), "rules")
+			runTestGit(t, seedDir, "clone", "-q", bareDir, rulesDir)
+			otherDir := filepath.Join(t.TempDir(), "other")
+			runTestGit(t, seedDir, "clone", "-q", bareDir, otherDir)
+
+			writeFileSystemFiles(t, NewOSFileSystem(), otherDir, map[string]string{"rule.mdc": "line one\ncentral\n"})
+			runTestGit(t, otherDir, "commit", "-q", "-am", "central change")
+			runTestGit(t, otherDir, "push", "-q")
+
+			if test.localChange != "" {
+				writeFileSystemFiles(t, NewOSFileSystem(), rulesDir, map[string]string{test.localChange: "line one\nlocal\n"})
+				runTestGit(t, rulesDir, "add", "-A")
+				runTestGit(t, rulesDir, "commit", "-q", "-m", "local change")
+			}
+			headBefore := runTestGit(t, rulesDir, "rev-parse", "HEAD")
+
+			syncService := NewSyncService(NewOutputService(), NewExecGitClient(), NewOSFileSystem())
+			err := syncService.updateRulesRepo(rulesDir)
+
+			var conflictErr *RebaseConflictError
+			if test.expectedConflict {
+				if !errors.As(err, &conflictErr) {
+					t.Fatalf("Expected a RebaseConflictError, got %v", err)
+				}
+				if head := runTestGit(t, rulesDir, "rev-parse", "HEAD"); head != headBefore {
+					t.Errorf("Expected HEAD to stay at %s after aborted rebase, got %s", headBefore, head)
+				}
+				if _, statErr := os.Stat(filepath.Join(rulesDir, ".git", "rebase-merge")); statErr == nil {
+					t.Errorf("Expected no rebase in progress")
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			assertFileContent(t, filepath.Join(rulesDir, "rule.mdc"), test.expectedContent)
+			if test.localChange == "local.mdc" {
+				assertFileContent(t, filepath.Join(rulesDir, "local.mdc"), "line one\nlocal\n")
+			}
+		})
+	}
+}
+
+func TestUpdateRulesRepoWithFakeGit(t *testing.T) {
+	centralDir := filepath.Join(string(filepath.Separator), "central")
+	rulesDir := filepath.Join(string(filepath.Separator), "rules")
+	url := "https://example.com/rules.git"
+
+	tests := []struct {
+		localChange      string
+		expectedContent  string
+		expectedConflict bool
+		description      string
+	}{
+		{
+			expectedContent: "line one\ncentral\n",
+			description:     "Branch without local commits should be fast-forwarded",
+		},
+		{
+			localChange:     "local.mdc",
+			expectedContent: "line one\ncentral\n",
+			description:     "Local commits should be rebased onto upstream",
+		},
+		{
+			localChange:      "rule.mdc",
+			expectedContent:  "line one\nlocal\n",
+			expectedConflict: true,
+			description:      "Conflicting rebase should be aborted with a clear error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			fileSystem := NewMemoryFileSystem()
+			writeFileSystemFiles(t, fileSystem, centralDir, map[string]string{"rule.mdc": "line one\n"})
+			git, err := newFakeGitClient(fileSystem, centralDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			git.serve(url, centralDir)
+			if err := git.Clone(url, rulesDir); err != nil {
+				t.Fatal(err)
+			}
+
+			git.commitFiles(t, centralDir, "central change", map[string]string{"rule.mdc": "line one\ncentral\n"})
+			if test.localChange != "" {
+				git.commitFiles(t, rulesDir, "local change", map[string]string{test.localChange: "line one\nlocal\n"})
+			}
+			headBefore, _ := git.HeadSHA(rulesDir)
+
+			var stdout bytes.Buffer
+			syncService := NewSyncService(NewOutputServiceWithWriters(&stdout, &stdout), git, fileSystem)
+			err = syncService.updateRulesRepo(rulesDir)
+
+			repo := git.repos[rulesDir]
+			var conflictErr *RebaseConflictError
+			if test.expectedConflict {
+				if !errors.As(err, &conflictErr) || conflictErr.Upstream != "origin/main" {
+					t.Fatalf("Expected a RebaseConflictError, got %v", err)
+				}
+				if head, _ := git.HeadSHA(rulesDir); head != headBefore {
+					t.Errorf("Expected HEAD to stay at %s after aborted rebase, got %s", headBefore, head)
+				}
+				if repo.rebasing {
+					t.Errorf("Expected no rebase in progress")
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if repo.fetches != 1 {
+				t.Errorf("Expected one fetch, got %d", repo.fetches)
+			}
+			expected := map[string]string{"rule.mdc": test.expectedContent}
+			if test.localChange == "local.mdc" {
+				expected["local.mdc"] = "line one\nlocal\n"
+			}
+			files := readFileSystemFiles(t, fileSystem, rulesDir)
+			assertFiles(t, "rules", expected, files)
+			if !test.expectedConflict && len(repo.commits) != len(expected)+1 {
+				t.Errorf("Expected the local commits on top of upstream, got %+v", repo.commits)
+			}
+		})
+	}
+}